@@ -16,9 +16,23 @@ type ServerConfig struct {
 	ReadTimeoutSec  int    `json:"read_timeout_sec"`
 	WriteTimeoutSec int    `json:"write_timeout_sec"`
 	IdleTimeoutSec  int    `json:"idle_timeout_sec"`
+	// AdminToken enables the /api/v1/admin routes for requests bearing it.
+	AdminToken string `json:"admin_token"`
+	// RequireIfMatch makes If-Match mandatory on the routes that change a sensor.
+	RequireIfMatch bool `json:"require_if_match"`
+	// IdempotencyWindowSec is how long the response to a POST with an
+	// Idempotency-Key is replayed to retries; 0 ignores the header.
+	IdempotencyWindowSec int `json:"idempotency_window_sec"`
+	// EventStreamSec is how long a stream of sensor changes lasts before its
+	// client has to reconnect.
+	EventStreamSec int `json:"event_stream_sec"`
 }
 
 type DBConfig struct {
+	// Driver selects the storage backend: "postgres" (default), "sqlite" or "memory".
+	Driver string `json:"driver"`
+	// Path is the database file used by the sqlite driver.
+	Path       string `json:"path"`
 	Host       string `json:"host"`
 	Port       string `json:"port"`
 	User       string `json:"user"`
@@ -26,6 +40,7 @@ type DBConfig struct {
 	DBName     string `json:"db_name"`
 	SchemaName string `json:"schema_name"`
 	SSLMode    string `json:"ssl_mode"`
+	SeedData   bool   `json:"seed_data"`
 }
 
 // InitConfig initializes and returns the configuration data based on defaults and config.json file.
@@ -63,10 +78,16 @@ func loadConfig(baseConfig string) (*Configuration, error) {
 func defaultConfig() *Configuration {
 	return &Configuration{
 		ServerConfig: &ServerConfig{
-			Addr:            ":8080",
-			ReadTimeoutSec:  90,
-			WriteTimeoutSec: 90,
-			IdleTimeoutSec:  0,
+			Addr:                 ":8080",
+			ReadTimeoutSec:       90,
+			WriteTimeoutSec:      90,
+			IdleTimeoutSec:       0,
+			IdempotencyWindowSec: 24 * 60 * 60,
+			EventStreamSec:       5 * 60,
+		},
+		DBConfig: &DBConfig{
+			Driver: "postgres",
+			Path:   "sensor-metadata.db",
 		},
 	}
 }
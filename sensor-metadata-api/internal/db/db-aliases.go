@@ -0,0 +1,155 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNameReserved is returned when a sensor would take a name that is still
+	// an alias of another sensor.
+	ErrNameReserved = errors.New("sensor name is reserved as an alias of another sensor")
+	// ErrDuplicateName is returned when a sensor would take the name of another
+	// one. It is a gorm.ErrDuplicatedKey, told apart from the other unique
+	// constraints by the name being checked first.
+	ErrDuplicateName = fmt.Errorf("sensor name is taken by another sensor: %w", gorm.ErrDuplicatedKey)
+)
+
+// SensorAlias is a former name of a sensor. It keeps pointing at the sensor, and
+// cannot be taken by another one, until it is released.
+type SensorAlias struct {
+	Name      string    `gorm:"type:varchar(255);primaryKey" json:"name"`
+	NameKey   string    `gorm:"type:varchar(255)" json:"-"`
+	SensorID  uuid.UUID `gorm:"type:uuid;not null" json:"sensor_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BeforeCreate assigns the key the alias is looked up by.
+func (a *SensorAlias) BeforeCreate(*gorm.DB) error {
+	a.NameKey = canonicalName(a.Name)
+	return nil
+}
+
+// sameName reports whether two names are the same sensor name.
+func sameName(a, b string) bool {
+	return canonicalName(a) == canonicalName(b)
+}
+
+// canonicalName is the key names are compared by, without regard to case. All
+// backends compare names by it, and the sql ones store it as name_key, as the
+// lower() of SQLite only folds ASCII letters. Going through the upper case
+// also folds letters with more than one lower case, like the Greek sigma.
+func canonicalName(name string) string {
+	return strings.ToLower(strings.ToUpper(name))
+}
+
+func (d *SensorMetadataDBImpl) GetSensorMetadataByID(id uuid.UUID) (*SensorMetadata, error) {
+	var sensor SensorMetadata
+	if err := d.db.Where("id = ?", id).First(&sensor).Error; err != nil {
+		return nil, err
+	}
+
+	return &sensor, nil
+}
+
+// GetSensorMetadataByIDUnscoped also returns a soft-deleted sensor.
+func (d *SensorMetadataDBImpl) GetSensorMetadataByIDUnscoped(id uuid.UUID) (*SensorMetadata, error) {
+	var sensor SensorMetadata
+	if err := d.db.Unscoped().Where("id = ?", id).First(&sensor).Error; err != nil {
+		return nil, err
+	}
+
+	return &sensor, nil
+}
+
+// ResolveSensorAlias returns the sensor, soft-deleted or not, that name is an
+// alias of.
+func (d *SensorMetadataDBImpl) ResolveSensorAlias(name string) (*SensorMetadata, error) {
+	var alias SensorAlias
+	if err := d.db.Where("name_key = ?", canonicalName(name)).First(&alias).Error; err != nil {
+		return nil, err
+	}
+
+	return d.GetSensorMetadataByIDUnscoped(alias.SensorID)
+}
+
+func (d *SensorMetadataDBImpl) ListSensorAliases(sensorID uuid.UUID) ([]SensorAlias, error) {
+	aliases := make([]SensorAlias, 0)
+	err := d.db.Where("sensor_id = ?", sensorID).Order("created_at").Order("name").Find(&aliases).Error
+	return aliases, err
+}
+
+// ReleaseSensorAlias removes an alias of the sensor, freeing the name for
+// other sensors.
+func (d *SensorMetadataDBImpl) ReleaseSensorAlias(sensorID uuid.UUID, name string) error {
+	res := d.db.Where("sensor_id = ? AND name_key = ?", sensorID, canonicalName(name)).Delete(&SensorAlias{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// checkNameFree fails with ErrDuplicateName when a sensor has name, and with
+// ErrNameReserved when name is an alias of a sensor.
+func checkNameFree(tx *gorm.DB, name string) error {
+	if err := checkNameUnused(tx, name, uuid.Nil); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := tx.Model(&SensorAlias{}).Where("name_key = ?", canonicalName(name)).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrNameReserved
+	}
+	return nil
+}
+
+// checkNameUnused fails with ErrDuplicateName when a sensor other than the one
+// with id, soft-deleted or not, has name. Writes are serialized, see
+// writeTransaction, so the name is still free when the write follows.
+func checkNameUnused(tx *gorm.DB, name string, id uuid.UUID) error {
+	var count int64
+	err := tx.Unscoped().Model(&SensorMetadata{}).Where("name_key = ? AND id <> ?", canonicalName(name), id).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDuplicateName
+	}
+	return nil
+}
+
+// renameAliases keeps the aliases of a sensor renamed from oldName in step:
+// the old name becomes an alias, and an alias of the sensor it takes back is
+// dropped. Renaming onto another sensor's alias fails with ErrNameReserved.
+func renameAliases(tx *gorm.DB, oldName string, sensor *SensorMetadata) error {
+	if sameName(oldName, sensor.Name) {
+		return nil
+	}
+
+	var taken SensorAlias
+	res := tx.Where("name_key = ?", canonicalName(sensor.Name)).Limit(1).Find(&taken)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		if taken.SensorID != sensor.ID {
+			return ErrNameReserved
+		}
+		if err := tx.Delete(&taken).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(&SensorAlias{Name: oldName, SensorID: sensor.ID, CreatedAt: time.Now()}).Error
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func aliasNames(t *testing.T, database SensorMetadataDB, sensor *SensorMetadata) []string {
+	aliases, err := database.ListSensorAliases(sensor.ID)
+	require.NoError(t, err)
+	names := make([]string, 0, len(aliases))
+	for _, a := range aliases {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+func TestCaseInsensitiveNames(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			sensor := &SensorMetadata{Name: "Roof-Sensor", Description: "roof"}
+			require.NoError(t, database.CreateSensorMetadata(sensor))
+
+			got, err := database.GetSensorMetadataByName("roof-sensor")
+			require.NoError(t, err)
+			assert.Equal(t, "Roof-Sensor", got.Name, "names are kept as written")
+
+			got, err = database.GetSensorMetadataByID(sensor.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "Roof-Sensor", got.Name)
+
+			err = database.CreateSensorMetadata(&SensorMetadata{Name: "ROOF-SENSOR", Description: "other"})
+			assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+			// changing the case only is not a rename
+			got.Name = "roof-sensor"
+			require.NoError(t, database.UpdateSensorMetadata(got))
+			assert.Empty(t, aliasNames(t, database, sensor))
+
+			require.NoError(t, database.DeleteSensorMetadata("ROOF-sensor", 0))
+			_, err = database.GetSensorMetadataByID(sensor.ID)
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			_, err = database.GetSensorMetadataByIDUnscoped(sensor.ID)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCaseInsensitiveNames_NonASCII(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			sensor := &SensorMetadata{Name: "Ärger-Süd", Description: "south"}
+			require.NoError(t, database.CreateSensorMetadata(sensor))
+
+			for _, lookup := range []string{"ärger-süd", "ÄRGER-SÜD"} {
+				got, err := database.GetSensorMetadataByName(lookup)
+				require.NoError(t, err, lookup)
+				assert.Equal(t, sensor.ID, got.ID)
+			}
+			err := database.CreateSensorMetadata(&SensorMetadata{Name: "ärger-süd", Description: "other"})
+			assert.ErrorIs(t, err, ErrDuplicateName)
+
+			// the final sigma folds like the other lower case sigma
+			require.NoError(t, database.CreateSensorMetadata(&SensorMetadata{Name: "ΟΔΟΣ", Description: "street"}))
+			err = database.CreateSensorMetadata(&SensorMetadata{Name: "οδος", Description: "street again"})
+			assert.ErrorIs(t, err, ErrDuplicateName)
+			// with a latin o it is another name
+			_, err = database.GetSensorMetadataByName("οδoς")
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			got, err := database.GetSensorMetadataByName("οδος")
+			require.NoError(t, err)
+			assert.Equal(t, "ΟΔΟΣ", got.Name)
+
+			// the former name is held as an alias, whatever its case
+			sensor.Name = "Öl-Nord"
+			require.NoError(t, database.UpdateSensorMetadata(sensor))
+			resolved, err := database.ResolveSensorAlias("ÄRGER-süd")
+			require.NoError(t, err)
+			assert.Equal(t, sensor.ID, resolved.ID)
+			err = database.CreateSensorMetadata(&SensorMetadata{Name: "ärger-SÜD", Description: "taken"})
+			assert.ErrorIs(t, err, ErrNameReserved)
+
+			// changing the case only is not a rename
+			sensor.Name = "öl-nord"
+			require.NoError(t, database.UpdateSensorMetadata(sensor))
+			assert.Equal(t, []string{"Ärger-Süd"}, aliasNames(t, database, sensor))
+		})
+	}
+}
+
+func TestSensorAliases(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedSensors(t, database, 2)
+			sensor, err := database.GetSensorMetadataByName("sensor-00")
+			require.NoError(t, err)
+
+			sensor.Name = "renamed"
+			require.NoError(t, database.UpdateSensorMetadata(sensor))
+			assert.Equal(t, []string{"sensor-00"}, aliasNames(t, database, sensor))
+
+			resolved, err := database.ResolveSensorAlias("SENSOR-00")
+			require.NoError(t, err)
+			assert.Equal(t, sensor.ID, resolved.ID)
+			assert.Equal(t, "renamed", resolved.Name)
+
+			// an alias is reserved for its sensor
+			err = database.CreateSensorMetadata(&SensorMetadata{Name: "Sensor-00", Description: "taken"})
+			assert.ErrorIs(t, err, ErrNameReserved)
+			other, err := database.GetSensorMetadataByName("sensor-01")
+			require.NoError(t, err)
+			other.Name = "sensor-00"
+			assert.ErrorIs(t, database.UpdateSensorMetadata(other), ErrNameReserved)
+			results, err := database.BulkWriteSensorMetadata([]SensorMetadata{{Name: "sensor-00", Location: Location{Latitude: 1}}}, BulkOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, []string{OutcomeFailed}, outcomes(results))
+
+			// every former name is kept, and taking one back drops it
+			sensor.Name = "renamed-again"
+			require.NoError(t, database.UpdateSensorMetadata(sensor))
+			assert.ElementsMatch(t, []string{"sensor-00", "renamed"}, aliasNames(t, database, sensor))
+			sensor.Name = "Sensor-00"
+			require.NoError(t, database.UpdateSensorMetadata(sensor))
+			assert.ElementsMatch(t, []string{"renamed", "renamed-again"}, aliasNames(t, database, sensor))
+
+			assert.ErrorIs(t, database.ReleaseSensorAlias(other.ID, "renamed"), gorm.ErrRecordNotFound)
+			require.NoError(t, database.ReleaseSensorAlias(sensor.ID, "RENAMED"))
+			_, err = database.ResolveSensorAlias("renamed")
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			require.NoError(t, database.CreateSensorMetadata(&SensorMetadata{Name: "renamed", Description: "free again"}))
+
+			// purging a sensor releases its aliases
+			require.NoError(t, database.PurgeSensorMetadata("sensor-00"))
+			_, err = database.ResolveSensorAlias("renamed-again")
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Bulk write modes
+const (
+	// BulkInsert creates every sensor and fails the ones whose name is taken.
+	BulkInsert = "insert"
+	// BulkUpsert creates new sensors and updates the non-empty fields of existing ones.
+	BulkUpsert = "upsert"
+	// BulkReplace creates new sensors and overwrites every field of existing ones.
+	BulkReplace = "replace"
+
+	MaxBulkItems = 1000
+)
+
+// Bulk item outcomes
+const (
+	OutcomeCreated    = "created"
+	OutcomeUpdated    = "updated"
+	OutcomeReplaced   = "replaced"
+	OutcomeFailed     = "failed"
+	OutcomeRolledBack = "rolled_back"
+)
+
+var (
+	ErrInvalidBulkMode  = errors.New("invalid bulk mode")
+	ErrTooManyItems     = fmt.Errorf("a bulk request holds at most %d sensors", MaxBulkItems)
+	ErrSensorDeleted    = errors.New("sensor metadata is soft-deleted, restore or purge it first")
+	ErrIncompleteSensor = errors.New("sensor name and location are required")
+)
+
+// IncompleteSensorError names the fields a sensor of a bulk write needs but
+// left out. It is an ErrIncompleteSensor.
+type IncompleteSensorError struct {
+	Fields []string
+}
+
+func (e *IncompleteSensorError) Error() string {
+	return "sensor " + strings.Join(e.Fields, " and ") + " missing"
+}
+
+func (e *IncompleteSensorError) Is(target error) bool {
+	return target == ErrIncompleteSensor
+}
+
+// BulkOptions controls a bulk write. Atomic writes all sensors or none of them;
+// otherwise every sensor that can be written is. DryRun reports the outcomes
+// without keeping any of the writes. Located[i] tells that the i-th sensor sets
+// its location, so a zero Location is the point (0, 0) rather than a missing
+// one; sensors past its end are taken as not set.
+type BulkOptions struct {
+	Mode    string
+	Atomic  bool
+	DryRun  bool
+	Located []bool
+}
+
+// BulkResult is the outcome of one sensor of a bulk write, by its position in
+// the request. Err is why a failed sensor was not written; the API reports it
+// as Code and Error, as it would for a single write.
+type BulkResult struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name"`
+	Outcome string `json:"outcome"`
+	Version int    `json:"version,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Err     error  `json:"-"`
+}
+
+// located reports whether the i-th sensor of the batch sets its location.
+func (o *BulkOptions) located(i int) bool {
+	return i < len(o.Located) && o.Located[i]
+}
+
+func (o *BulkOptions) validate(n int) error {
+	if o.Mode == "" {
+		o.Mode = BulkInsert
+	}
+	switch o.Mode {
+	case BulkInsert, BulkUpsert, BulkReplace:
+	default:
+		return ErrInvalidBulkMode
+	}
+	if n > MaxBulkItems {
+		return ErrTooManyItems
+	}
+	return nil
+}
+
+// mergeSensor applies the fields of src to the stored sensor dst: all of them in
+// replace mode, only the non-empty ones in upsert mode, where located tells a
+// location of (0, 0) from a missing one.
+func mergeSensor(dst, src *SensorMetadata, opts BulkOptions, located bool) {
+	if opts.Mode == BulkReplace {
+		dst.Description = src.Description
+		dst.Location = src.Location
+		dst.Tags = src.Tags
+		return
+	}
+
+	if src.Description != "" {
+		dst.Description = src.Description
+	}
+	if src.Location != (Location{}) || located {
+		dst.Location = src.Location
+	}
+	if len(src.Tags) > 0 {
+		dst.Tags = src.Tags
+	}
+}
+
+// checkBulkItem rejects a sensor missing the fields needed to store it with an
+// IncompleteSensorError. The location may only be left out when upserting onto
+// an existing sensor.
+func checkBulkItem(sensor *SensorMetadata, exists bool, opts BulkOptions, located bool) error {
+	var missing []string
+	if sensor.Name == "" {
+		missing = append(missing, "name")
+	}
+	if !located && sensor.Location == (Location{}) && (!exists || opts.Mode == BulkReplace) {
+		missing = append(missing, "location")
+	}
+	if len(missing) > 0 {
+		return &IncompleteSensorError{Fields: missing}
+	}
+	return nil
+}
+
+// finishBulk marks the written sensors of an atomic batch as rolled back once
+// any of them failed, and reports whether that happened.
+func finishBulk(results []BulkResult, atomic bool) bool {
+	if !atomic {
+		return false
+	}
+	failed := false
+	for _, r := range results {
+		if r.Outcome == OutcomeFailed {
+			failed = true
+			break
+		}
+	}
+	if failed {
+		for i := range results {
+			if results[i].Outcome != OutcomeFailed {
+				results[i].Outcome = OutcomeRolledBack
+				results[i].Version = 0
+			}
+		}
+	}
+	return failed
+}
+
+// errBulkRolledBack aborts the transaction of a failed atomic batch or a dry run.
+var errBulkRolledBack = errors.New("bulk write rolled back")
+
+// BulkWriteSensorMetadata writes sensors in a single transaction, each one in
+// its own savepoint so a failing sensor does not abort the others. The returned
+// error is only set when the batch could not be processed at all.
+func (d *SensorMetadataDBImpl) BulkWriteSensorMetadata(sensors []SensorMetadata, opts BulkOptions) ([]BulkResult, error) {
+	if err := opts.validate(len(sensors)); err != nil {
+		return nil, err
+	}
+
+	results := make([]BulkResult, len(sensors))
+	err := d.writeTransaction(func(tx *gorm.DB) error {
+		for i := range sensors {
+			sp := fmt.Sprintf("bulk_%d", i)
+			if err := tx.SavePoint(sp).Error; err != nil {
+				return err
+			}
+
+			results[i] = BulkResult{Index: i, Name: sensors[i].Name}
+			outcome, err := bulkWrite(tx, &sensors[i], opts, opts.located(i))
+			if err != nil {
+				if err := tx.RollbackTo(sp).Error; err != nil {
+					return err
+				}
+				results[i].Outcome = OutcomeFailed
+				results[i].Err = err
+				continue
+			}
+			results[i].Outcome = outcome
+			results[i].Version = sensors[i].Version
+		}
+
+		if finishBulk(results, opts.Atomic) || opts.DryRun {
+			return errBulkRolledBack
+		}
+		return nil
+	})
+	if err != nil && err != errBulkRolledBack {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// bulkWrite creates or updates one sensor of a batch within tx.
+func bulkWrite(tx *gorm.DB, sensor *SensorMetadata, opts BulkOptions, located bool) (string, error) {
+	var stored SensorMetadata
+	res := tx.Unscoped().Where("name_key = ?", canonicalName(sensor.Name)).Limit(1).Find(&stored)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	exists := res.RowsAffected > 0
+	if err := checkBulkItem(sensor, exists, opts, located); err != nil {
+		return "", err
+	}
+
+	switch {
+	case !exists:
+		if err := checkNameFree(tx, sensor.Name); err != nil {
+			return "", err
+		}
+		sensor.Version = 1
+		if err := tx.Create(sensor).Error; err != nil {
+			return "", err
+		}
+		return OutcomeCreated, recordRevision(tx, sensor, OpCreate)
+	case opts.Mode == BulkInsert:
+		return "", ErrDuplicateName
+	case stored.DeletedAt.Valid:
+		return "", ErrSensorDeleted
+	}
+
+	mergeSensor(&stored, sensor, opts, located)
+	if err := compareAndSwap(tx, &stored); err != nil {
+		return "", err
+	}
+	if err := recordRevision(tx, &stored, OpUpdate); err != nil {
+		return "", err
+	}
+	*sensor = stored
+
+	if opts.Mode == BulkReplace {
+		return OutcomeReplaced, nil
+	}
+	return OutcomeUpdated, nil
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func outcomes(results []BulkResult) []string {
+	out := make([]string, 0, len(results))
+	for _, r := range results {
+		out = append(out, r.Outcome)
+	}
+	return out
+}
+
+func TestBulkWrite(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedSensors(t, database, 3)
+			require.NoError(t, database.DeleteSensorMetadata("sensor-02", 1))
+
+			results, err := database.BulkWriteSensorMetadata([]SensorMetadata{
+				{Name: "new-1", Description: "new 1", Location: Location{Latitude: 1, Longitude: 1}},
+				{Name: "sensor-01", Description: "taken"},
+				{Name: "new-1", Description: "twice", Location: Location{Latitude: 1, Longitude: 1}},
+				{Name: "no-location", Description: "incomplete"},
+			}, BulkOptions{Mode: BulkInsert})
+			require.NoError(t, err)
+			assert.Equal(t, []string{OutcomeCreated, OutcomeFailed, OutcomeFailed, OutcomeFailed}, outcomes(results))
+			assert.Equal(t, 1, results[0].Version)
+			assert.ErrorIs(t, results[3].Err, ErrIncompleteSensor)
+			var incomplete *IncompleteSensorError
+			require.ErrorAs(t, results[3].Err, &incomplete)
+			assert.Equal(t, []string{"location"}, incomplete.Fields)
+
+			results, err = database.BulkWriteSensorMetadata([]SensorMetadata{
+				{Name: "sensor-01", Description: "upserted"},
+				{Name: "new-2", Description: "new 2", Location: Location{Latitude: 2, Longitude: 2}},
+				{Name: "sensor-02", Description: "deleted"},
+			}, BulkOptions{Mode: BulkUpsert})
+			require.NoError(t, err)
+			assert.Equal(t, []string{OutcomeUpdated, OutcomeCreated, OutcomeFailed}, outcomes(results))
+			assert.ErrorIs(t, results[2].Err, ErrSensorDeleted)
+
+			// upsert keeps the fields it was not given
+			got, err := database.GetSensorMetadataByName("sensor-01")
+			require.NoError(t, err)
+			assert.Equal(t, "upserted", got.Description)
+			assert.Equal(t, Location{Latitude: 1, Longitude: -1}, got.Location)
+			assert.Equal(t, 2, got.Version)
+
+			// replace overwrites them
+			results, err = database.BulkWriteSensorMetadata([]SensorMetadata{
+				{Name: "sensor-01", Description: "replaced", Location: Location{Latitude: 5, Longitude: 5}},
+			}, BulkOptions{Mode: BulkReplace})
+			require.NoError(t, err)
+			assert.Equal(t, []string{OutcomeReplaced}, outcomes(results))
+
+			got, err = database.GetSensorMetadataByName("sensor-01")
+			require.NoError(t, err)
+			assert.Empty(t, got.Tags)
+			assert.Equal(t, 3, got.Version)
+
+			revisions, err := database.ListSensorRevisions(got.ID)
+			require.NoError(t, err)
+			assert.Len(t, revisions, 3)
+		})
+	}
+}
+
+func TestBulkWrite_Atomic(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedSensors(t, database, 2)
+
+			results, err := database.BulkWriteSensorMetadata([]SensorMetadata{
+				{Name: "new-1", Description: "new 1", Location: Location{Latitude: 1, Longitude: 1}},
+				{Name: "sensor-01", Description: "changed"},
+				{Name: "sensor-00", Description: "taken", Location: Location{Latitude: 1, Longitude: 1}},
+			}, BulkOptions{Mode: BulkInsert, Atomic: true})
+			require.NoError(t, err)
+			assert.Equal(t, []string{OutcomeRolledBack, OutcomeFailed, OutcomeFailed}, outcomes(results))
+
+			// nothing was written
+			_, err = database.GetSensorMetadataByName("new-1")
+			assert.Error(t, err)
+			assert.Equal(t, []string{"sensor-00", "sensor-01"}, listAll(t, database, ListOptions{Limit: 10}))
+
+			results, err = database.BulkWriteSensorMetadata([]SensorMetadata{
+				{Name: "new-1", Description: "new 1", Location: Location{Latitude: 1, Longitude: 1}},
+				{Name: "sensor-01", Description: "changed"},
+			}, BulkOptions{Mode: BulkUpsert, Atomic: true})
+			require.NoError(t, err)
+			assert.Equal(t, []string{OutcomeCreated, OutcomeUpdated}, outcomes(results))
+			assert.Equal(t, []string{"new-1", "sensor-00", "sensor-01"}, listAll(t, database, ListOptions{Limit: 10}))
+		})
+	}
+}
+
+func TestBulkWrite_Located(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedSensors(t, database, 3)
+			before, err := database.GetSensorMetadataByName("sensor-02")
+			require.NoError(t, err)
+
+			// (0, 0) is a position for the sensors the caller vouches set one,
+			// and a missing location for the others of the same batch
+			results, err := database.BulkWriteSensorMetadata([]SensorMetadata{
+				{Name: "null-island", Description: "null island"},
+				{Name: "sensor-01", Description: "moved"},
+				{Name: "sensor-02", Description: "kept"},
+				{Name: "nowhere", Description: "nowhere"},
+			}, BulkOptions{Mode: BulkUpsert, Located: []bool{true, true, false, false}})
+			require.NoError(t, err)
+			assert.Equal(t, []string{OutcomeCreated, OutcomeUpdated, OutcomeUpdated, OutcomeFailed}, outcomes(results))
+
+			got, err := database.GetSensorMetadataByName("sensor-01")
+			require.NoError(t, err)
+			assert.Equal(t, Location{}, got.Location)
+			got, err = database.GetSensorMetadataByName("sensor-02")
+			require.NoError(t, err)
+			assert.Equal(t, before.Location, got.Location)
+			assert.Equal(t, "kept", got.Description)
+		})
+	}
+}
+
+func TestBulkWrite_Invalid(t *testing.T) {
+	database := NewMemorySensorMetadataDB()
+
+	_, err := database.BulkWriteSensorMetadata(nil, BulkOptions{Mode: "merge"})
+	assert.ErrorIs(t, err, ErrInvalidBulkMode)
+	_, err = database.BulkWriteSensorMetadata(make([]SensorMetadata, MaxBulkItems+1), BulkOptions{})
+	assert.ErrorIs(t, err, ErrTooManyItems)
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"sort"
+
+	"sensor-metadata-api/internal/geohash"
+)
+
+const (
+	// MaxZoom is the deepest zoom level of web maps.
+	MaxZoom = 22
+
+	DefaultClusterSample = 3
+	MaxClusterSample     = 20
+)
+
+// SensorCluster is the sensors of a geohash cell, located at their centroid.
+// Sample holds the first names of the sensors, in alphabetical order.
+type SensorCluster struct {
+	Cell     string   `json:"cell"`
+	Centroid Location `json:"centroid"`
+	Count    int      `json:"count"`
+	Sample   []string `json:"sample"`
+}
+
+// SensorClusters is a map view: the cells holding several sensors as clusters,
+// and the sensors alone in their cell as themselves.
+type SensorClusters struct {
+	Zoom      int              `json:"zoom"`
+	Precision int              `json:"precision"`
+	Clusters  []SensorCluster  `json:"clusters"`
+	Sensors   []SensorMetadata `json:"sensors"`
+}
+
+// ClusterPrecision is the geohash length clustering a map at zoom: the
+// shortest whose cells are at most a quarter of a map tile wide, so a tile
+// shows a few clusters across.
+func ClusterPrecision(zoom int) int {
+	for p := 1; p < geohash.MaxPrecision; p++ {
+		// a hash of p characters halves the longitudes ceil(5p/2) times, a
+		// tile at zoom z spans 360/2^z degrees
+		if (5*p+1)/2 >= zoom+2 {
+			return p
+		}
+	}
+	return geohash.MaxPrecision
+}
+
+// ClusterSensors groups sensors by the geohash cells of zoom, keeping up to
+// sample names per cluster. Clusters are listed by decreasing count, sensors
+// by name.
+func ClusterSensors(sensors []SensorMetadata, zoom, sample int) *SensorClusters {
+	precision := ClusterPrecision(zoom)
+
+	cells := make(map[string][]int)
+	for i, s := range sensors {
+		cell := geohash.Encode(s.Location.Latitude, s.Location.Longitude, precision)
+		cells[cell] = append(cells[cell], i)
+	}
+
+	result := &SensorClusters{
+		Zoom:      zoom,
+		Precision: precision,
+		Clusters:  make([]SensorCluster, 0),
+		Sensors:   make([]SensorMetadata, 0),
+	}
+	for cell, members := range cells {
+		if len(members) == 1 {
+			result.Sensors = append(result.Sensors, sensors[members[0]])
+			continue
+		}
+
+		// a cell never crosses the antimeridian, so the mean is its centroid
+		cluster := SensorCluster{Cell: cell, Count: len(members)}
+		names := make([]string, 0, len(members))
+		for _, i := range members {
+			cluster.Centroid.Latitude += sensors[i].Location.Latitude
+			cluster.Centroid.Longitude += sensors[i].Location.Longitude
+			names = append(names, sensors[i].Name)
+		}
+		cluster.Centroid.Latitude /= float64(len(members))
+		cluster.Centroid.Longitude /= float64(len(members))
+
+		sort.Strings(names)
+		if len(names) > sample {
+			names = names[:sample]
+		}
+		cluster.Sample = names
+		result.Clusters = append(result.Clusters, cluster)
+	}
+
+	sort.Slice(result.Clusters, func(i, j int) bool {
+		a, b := result.Clusters[i], result.Clusters[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Cell < b.Cell
+	})
+	sort.Slice(result.Sensors, func(i, j int) bool {
+		return result.Sensors[i].Name < result.Sensors[j].Name
+	})
+	return result
+}
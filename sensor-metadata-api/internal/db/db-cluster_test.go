@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterPrecision(t *testing.T) {
+	assert.Equal(t, 1, ClusterPrecision(0))
+	assert.Equal(t, 2, ClusterPrecision(3))
+	assert.Equal(t, 4, ClusterPrecision(8))
+	assert.Equal(t, 10, ClusterPrecision(MaxZoom))
+}
+
+func TestClusterSensors(t *testing.T) {
+	sensors := []SensorMetadata{
+		{Name: "philly-c", Location: Location{Latitude: 39.95, Longitude: -75.17}},
+		{Name: "philly-a", Location: Location{Latitude: 39.96, Longitude: -75.16}},
+		{Name: "philly-b", Location: Location{Latitude: 39.94, Longitude: -75.15}},
+		{Name: "pittsburgh", Location: Location{Latitude: 40.44, Longitude: -80.0}},
+		{Name: "harrisburg-1", Location: Location{Latitude: 40.27, Longitude: -76.89}},
+		{Name: "harrisburg-2", Location: Location{Latitude: 40.26, Longitude: -76.88}},
+	}
+
+	result := ClusterSensors(sensors, 8, 2)
+	assert.Equal(t, 8, result.Zoom)
+	assert.Equal(t, 4, result.Precision)
+	require.Len(t, result.Clusters, 2)
+
+	philly := result.Clusters[0]
+	assert.Equal(t, "dr4e", philly.Cell)
+	assert.Equal(t, 3, philly.Count)
+	assert.InDelta(t, 39.95, philly.Centroid.Latitude, 1e-9)
+	assert.InDelta(t, -75.16, philly.Centroid.Longitude, 1e-9)
+	assert.Equal(t, []string{"philly-a", "philly-b"}, philly.Sample)
+
+	assert.Equal(t, 2, result.Clusters[1].Count)
+	assert.Equal(t, []string{"harrisburg-1", "harrisburg-2"}, result.Clusters[1].Sample)
+
+	require.Len(t, result.Sensors, 1)
+	assert.Equal(t, "pittsburgh", result.Sensors[0].Name)
+
+	// zoomed in, every sensor stands alone
+	result = ClusterSensors(sensors, MaxZoom, 2)
+	assert.Empty(t, result.Clusters)
+	assert.Len(t, result.Sensors, len(sensors))
+
+	// zoomed out, all in one cell
+	result = ClusterSensors(sensors, 0, 10)
+	require.Len(t, result.Clusters, 1)
+	assert.Equal(t, "d", result.Clusters[0].Cell)
+	assert.Len(t, result.Clusters[0].Sample, len(sensors))
+}
@@ -7,7 +7,22 @@ import (
 	"sensor-metadata-api/config"
 )
 
-func InitDb(cfg *config.DBConfig) (*SensorMetadataDBImpl, error) {
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// Connect opens a connection pool to the configured database without touching the schema.
+func Connect(cfg *config.DBConfig) (*gorm.DB, error) {
+	switch cfg.Driver {
+	case DriverPostgres, "":
+	case DriverSQLite:
+		return openSQLite(cfg.Path)
+	default:
+		return nil, fmt.Errorf("driver %q has no sql connection", cfg.Driver)
+	}
+
 	dsn := fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
 		cfg.Host,
@@ -17,28 +32,47 @@ func InitDb(cfg *config.DBConfig) (*SensorMetadataDBImpl, error) {
 		cfg.Port,
 		cfg.SSLMode,
 	)
-	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		return nil, err
-	}
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+}
 
-	// Enable UUID Generator V4
-	conn.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`)
+// InitDb builds the storage backend selected by cfg.Driver, applying schema
+// migrations and seeding sample data when configured.
+func InitDb(cfg *config.DBConfig) (SensorMetadataDB, error) {
+	var db SensorMetadataDB
 
-	// Auto-migrate the table
-	_ = conn.Migrator().DropTable(&SensorMetadata{})
-	err = conn.AutoMigrate(&SensorMetadata{})
-	if err != nil {
-		return nil, err
-	}
+	switch cfg.Driver {
+	case DriverMemory:
+		db = NewMemorySensorMetadataDB()
+
+	case DriverPostgres, DriverSQLite, "":
+		conn, err := Connect(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		// Apply pending schema migrations
+		err = NewMigrator(conn).Up()
+		if err != nil {
+			return nil, err
+		}
 
-	// Initialize the database instance
-	db := &SensorMetadataDBImpl{db: conn}
+		// Initialize the database instance
+		if cfg.Driver == DriverSQLite {
+			db = NewSQLiteSensorMetadataDB(conn)
+		} else {
+			db = NewSensorMetadataDB(conn)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown db driver %q", cfg.Driver)
+	}
 
 	// Initialize initial data
-	err = initData(db)
-	if err != nil {
-		return nil, err // Return error if initialization fails
+	if cfg.SeedData {
+		err := initData(db)
+		if err != nil {
+			return nil, err // Return error if initialization fails
+		}
 	}
 
 	return db, nil
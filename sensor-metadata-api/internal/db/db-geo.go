@@ -0,0 +1,222 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// EarthRadiusMeters is the mean earth radius used for great-circle distances.
+	EarthRadiusMeters = 6_371_008.8
+
+	// MaxNearest caps the number of sensors a nearest-k query returns.
+	MaxNearest = MaxListLimit
+)
+
+var ErrInvalidGeoQuery = errors.New("invalid geospatial query")
+
+// BoundingBox is an area between two parallels and two meridians. A box with
+// MinLongitude > MaxLongitude crosses the antimeridian.
+type BoundingBox struct {
+	MinLatitude  float64 `json:"min_latitude"`
+	MinLongitude float64 `json:"min_longitude"`
+	MaxLatitude  float64 `json:"max_latitude"`
+	MaxLongitude float64 `json:"max_longitude"`
+}
+
+// SensorDistance is a sensor along with its distance to the queried point.
+type SensorDistance struct {
+	SensorMetadata
+	DistanceMeters float64 `json:"distance_meters"`
+}
+
+// Valid reports whether l is a position on earth.
+func (l Location) Valid() bool {
+	return l.Latitude >= -90 && l.Latitude <= 90 && l.Longitude >= -180 && l.Longitude <= 180
+}
+
+// DistanceTo returns the great-circle distance to other in meters.
+func (l Location) DistanceTo(other Location) float64 {
+	lat1, lat2 := radians(l.Latitude), radians(other.Latitude)
+	dLat := lat2 - lat1
+	dLon := radians(other.Longitude - l.Longitude)
+
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * EarthRadiusMeters * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+func (b BoundingBox) validate() error {
+	if !(Location{b.MinLatitude, b.MinLongitude}).Valid() || !(Location{b.MaxLatitude, b.MaxLongitude}).Valid() {
+		return ErrInvalidGeoQuery
+	}
+	if b.MinLatitude > b.MaxLatitude {
+		return ErrInvalidGeoQuery
+	}
+	return nil
+}
+
+// Contains reports whether l lies inside the box, edges included.
+func (b BoundingBox) Contains(l Location) bool {
+	if l.Latitude < b.MinLatitude || l.Latitude > b.MaxLatitude {
+		return false
+	}
+	if b.MinLongitude <= b.MaxLongitude {
+		return l.Longitude >= b.MinLongitude && l.Longitude <= b.MaxLongitude
+	}
+	return l.Longitude >= b.MinLongitude || l.Longitude <= b.MaxLongitude
+}
+
+// boundsAround returns a box holding every point within radius meters of
+// center, used to narrow a radius search before measuring distances.
+func boundsAround(center Location, radius float64) BoundingBox {
+	angle := radius / EarthRadiusMeters
+	dLat := degrees(angle)
+
+	box := BoundingBox{
+		MinLatitude:  center.Latitude - dLat,
+		MaxLatitude:  center.Latitude + dLat,
+		MinLongitude: -180,
+		MaxLongitude: 180,
+	}
+	// a circle over a pole covers every longitude
+	if box.MinLatitude <= -90 || box.MaxLatitude >= 90 || angle >= math.Pi/2 {
+		box.MinLatitude = math.Max(box.MinLatitude, -90)
+		box.MaxLatitude = math.Min(box.MaxLatitude, 90)
+		return box
+	}
+
+	dLon := degrees(math.Asin(math.Sin(angle) / math.Cos(radians(center.Latitude))))
+	if dLon >= 180 {
+		return box
+	}
+	box.MinLongitude = center.Longitude - dLon
+	box.MaxLongitude = center.Longitude + dLon
+	if box.MinLongitude < -180 {
+		box.MinLongitude += 360
+	}
+	if box.MaxLongitude > 180 {
+		box.MaxLongitude -= 360
+	}
+	return box
+}
+
+// sortByDistance orders results nearest first, by name on ties.
+func sortByDistance(results []SensorDistance) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].DistanceMeters != results[j].DistanceMeters {
+			return results[i].DistanceMeters < results[j].DistanceMeters
+		}
+		return strings.Compare(results[i].Name, results[j].Name) < 0
+	})
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
+
+// distanceSQL is the haversine distance in meters between the sensor location
+// and a point bound as (latitude, latitude, longitude). Both postgres and the
+// bundled sqlite provide the math functions it needs; %s is their scalar minimum,
+// clamping rounding errors that would push asin out of its domain.
+const distanceSQL = "2 * 6371008.8 * asin(sqrt(%s(1.0, " +
+	"power(sin(radians(latitude - ?) / 2), 2) + " +
+	"cos(radians(?)) * cos(radians(latitude)) * power(sin(radians(longitude - ?) / 2), 2))))"
+
+// distanceExpr renders distanceSQL for the connected dialect.
+func (d *SensorMetadataDBImpl) distanceExpr() string {
+	if d.db.Dialector.Name() == DriverSQLite {
+		return fmt.Sprintf(distanceSQL, "min")
+	}
+	return fmt.Sprintf(distanceSQL, "least")
+}
+
+// whereInBox restricts q to sensors located inside box.
+func whereInBox(q *gorm.DB, box BoundingBox) *gorm.DB {
+	q = q.Where("latitude BETWEEN ? AND ?", box.MinLatitude, box.MaxLatitude)
+	if box.MinLongitude <= box.MaxLongitude {
+		return q.Where("longitude BETWEEN ? AND ?", box.MinLongitude, box.MaxLongitude)
+	}
+	return q.Where("longitude >= ? OR longitude <= ?", box.MinLongitude, box.MaxLongitude)
+}
+
+// FindSensorMetadataInBox returns the live sensors inside box, ordered by name.
+func (d *SensorMetadataDBImpl) FindSensorMetadataInBox(box BoundingBox) ([]SensorMetadata, error) {
+	if err := box.validate(); err != nil {
+		return nil, err
+	}
+
+	q := d.db.Model(&SensorMetadata{})
+	if d.geography {
+		q = whereInBoxGeography(q, box)
+	} else {
+		q = whereInBox(q, box)
+	}
+
+	sensors := make([]SensorMetadata, 0)
+	err := q.Order("name").Find(&sensors).Error
+	return sensors, err
+}
+
+// FindSensorMetadataWithinRadius returns the live sensors at most radius meters
+// from center, nearest first.
+func (d *SensorMetadataDBImpl) FindSensorMetadataWithinRadius(center Location, radius float64) ([]SensorDistance, error) {
+	if !center.Valid() || radius <= 0 {
+		return nil, ErrInvalidGeoQuery
+	}
+
+	q := d.db.Model(&SensorMetadata{})
+	if d.geography {
+		q = whereWithinGeography(q, center, radius)
+	} else {
+		q = whereInBox(q, boundsAround(center, radius)).
+			Where(d.distanceExpr()+" <= ?", center.Latitude, center.Latitude, center.Longitude, radius)
+	}
+
+	var sensors []SensorMetadata
+	if err := q.Find(&sensors).Error; err != nil {
+		return nil, err
+	}
+
+	return withDistances(sensors, center), nil
+}
+
+// FindNearestSensorMetadata returns the k live sensors closest to center,
+// nearest first.
+func (d *SensorMetadataDBImpl) FindNearestSensorMetadata(center Location, k int) ([]SensorDistance, error) {
+	if !center.Valid() || k < 1 || k > MaxNearest {
+		return nil, ErrInvalidGeoQuery
+	}
+
+	q := d.db.Model(&SensorMetadata{})
+	if d.geography {
+		q = orderByGeographyDistance(q, center)
+	} else {
+		q = q.Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL:                d.distanceExpr() + ", name",
+			Vars:               []interface{}{center.Latitude, center.Latitude, center.Longitude},
+			WithoutParentheses: true,
+		}})
+	}
+
+	var sensors []SensorMetadata
+	if err := q.Limit(k).Find(&sensors).Error; err != nil {
+		return nil, err
+	}
+
+	return withDistances(sensors, center), nil
+}
+
+// withDistances measures the distance of each sensor to center and sorts them.
+func withDistances(sensors []SensorMetadata, center Location) []SensorDistance {
+	results := make([]SensorDistance, 0, len(sensors))
+	for _, s := range sensors {
+		results = append(results, SensorDistance{SensorMetadata: s, DistanceMeters: center.DistanceTo(s.Location)})
+	}
+	sortByDistance(results)
+	return results
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var geoSensors = map[string]Location{
+	"pittsburgh":   {Latitude: 40.4406, Longitude: -79.9959},
+	"philadelphia": {Latitude: 39.9526, Longitude: -75.1652},
+	"new-york":     {Latitude: 40.7128, Longitude: -74.0060},
+	"suva":         {Latitude: -18.1248, Longitude: 178.4501},
+	"apia":         {Latitude: -13.8333, Longitude: -171.7667},
+}
+
+func seedGeoSensors(t *testing.T, database SensorMetadataDB) {
+	t.Helper()
+	for name, loc := range geoSensors {
+		require.NoError(t, database.CreateSensorMetadata(&SensorMetadata{Name: name, Description: name, Location: loc}))
+	}
+}
+
+func distanceNames(results []SensorDistance) []string {
+	names := make([]string, 0, len(results))
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestLocation_DistanceTo(t *testing.T) {
+	d := geoSensors["pittsburgh"].DistanceTo(geoSensors["philadelphia"])
+	assert.InDelta(t, 412_000, d, 2_000)
+	assert.Zero(t, geoSensors["suva"].DistanceTo(geoSensors["suva"]))
+}
+
+func TestGeoQueries(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedGeoSensors(t, database)
+
+			sensors, err := database.FindSensorMetadataInBox(BoundingBox{
+				MinLatitude: 39, MinLongitude: -81, MaxLatitude: 42, MaxLongitude: -74.5,
+			})
+			require.NoError(t, err)
+			require.Len(t, sensors, 2)
+			assert.Equal(t, "philadelphia", sensors[0].Name)
+			assert.Equal(t, "pittsburgh", sensors[1].Name)
+
+			// a box across the antimeridian
+			sensors, err = database.FindSensorMetadataInBox(BoundingBox{
+				MinLatitude: -20, MinLongitude: 170, MaxLatitude: -10, MaxLongitude: -170,
+			})
+			require.NoError(t, err)
+			assert.Len(t, sensors, 2)
+
+			within, err := database.FindSensorMetadataWithinRadius(geoSensors["philadelphia"], 150_000)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"philadelphia", "new-york"}, distanceNames(within))
+			assert.Zero(t, within[0].DistanceMeters)
+			assert.InDelta(t, 130_000, within[1].DistanceMeters, 2_000)
+
+			within, err = database.FindSensorMetadataWithinRadius(geoSensors["suva"], 1_200_000)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"suva", "apia"}, distanceNames(within))
+
+			nearest, err := database.FindNearestSensorMetadata(geoSensors["pittsburgh"], 3)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"pittsburgh", "philadelphia", "new-york"}, distanceNames(nearest))
+
+			// deleted sensors are not found
+			require.NoError(t, database.DeleteSensorMetadata("philadelphia", 1))
+			nearest, err = database.FindNearestSensorMetadata(geoSensors["philadelphia"], 1)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"new-york"}, distanceNames(nearest))
+		})
+	}
+}
+
+func TestGeoQueries_Invalid(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := database.FindSensorMetadataInBox(BoundingBox{MinLatitude: 10, MaxLatitude: 0})
+			assert.ErrorIs(t, err, ErrInvalidGeoQuery)
+			_, err = database.FindSensorMetadataInBox(BoundingBox{MaxLatitude: 91})
+			assert.ErrorIs(t, err, ErrInvalidGeoQuery)
+			_, err = database.FindSensorMetadataWithinRadius(Location{}, 0)
+			assert.ErrorIs(t, err, ErrInvalidGeoQuery)
+			_, err = database.FindNearestSensorMetadata(Location{Longitude: 181}, 1)
+			assert.ErrorIs(t, err, ErrInvalidGeoQuery)
+			_, err = database.FindNearestSensorMetadata(Location{}, 0)
+			assert.ErrorIs(t, err, ErrInvalidGeoQuery)
+		})
+	}
+}
+
+func TestBoundsAround(t *testing.T) {
+	// near the antimeridian the box wraps
+	box := boundsAround(geoSensors["suva"], 1_200_000)
+	assert.Greater(t, box.MinLongitude, box.MaxLongitude)
+	assert.True(t, box.Contains(geoSensors["apia"]))
+
+	// over a pole it spans every longitude
+	box = boundsAround(Location{Latitude: 89, Longitude: 10}, 500_000)
+	assert.Equal(t, float64(-180), box.MinLongitude)
+	assert.Equal(t, float64(180), box.MaxLongitude)
+	assert.Equal(t, float64(90), box.MaxLatitude)
+}
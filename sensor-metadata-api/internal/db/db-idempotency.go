@@ -0,0 +1,72 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyRecord is the response to a request sent with an Idempotency-Key,
+// kept so a retry of the request gets the same response instead of running it
+// again. Fingerprint identifies the request the key was first used with.
+type IdempotencyRecord struct {
+	Key         string `gorm:"type:varchar(255);primaryKey"`
+	Fingerprint string `gorm:"type:varchar(64);not null"`
+	// Status is 0 while the first request is still being processed.
+	Status    int               `gorm:"not null;default:0"`
+	Header    map[string]string `gorm:"serializer:json"`
+	Body      []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_keys"
+}
+
+// IdempotencyStore keeps the responses of idempotent requests until they expire.
+// Every backend implements it.
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey claims record.Key for a new request until
+	// record.ExpiresAt. When the key is already claimed and has not expired,
+	// the stored record is returned and nothing is written.
+	ReserveIdempotencyKey(record *IdempotencyRecord) (*IdempotencyRecord, error)
+	// SaveIdempotencyResponse stores the response of a reserved key.
+	SaveIdempotencyResponse(record *IdempotencyRecord) error
+	// ReleaseIdempotencyKey forgets a key, so the request can be run again.
+	ReleaseIdempotencyKey(key string) error
+}
+
+func (d *SensorMetadataDBImpl) ReserveIdempotencyKey(record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	var stored *IdempotencyRecord
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		// expired keys are dropped as new ones come in
+		if err := tx.Where("expires_at <= ?", time.Now()).Delete(&IdempotencyRecord{}).Error; err != nil {
+			return err
+		}
+
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if res.Error != nil || res.RowsAffected == 1 {
+			return res.Error
+		}
+
+		stored = &IdempotencyRecord{}
+		return tx.Where("key = ?", record.Key).First(stored).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+func (d *SensorMetadataDBImpl) SaveIdempotencyResponse(record *IdempotencyRecord) error {
+	return d.db.Model(record).
+		Select("status", "header", "body", "expires_at").
+		Updates(record).Error
+}
+
+func (d *SensorMetadataDBImpl) ReleaseIdempotencyKey(key string) error {
+	return d.db.Where("key = ?", key).Delete(&IdempotencyRecord{}).Error
+}
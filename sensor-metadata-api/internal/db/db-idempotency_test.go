@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			store := database.(IdempotencyStore)
+			reserve := func(key, fingerprint string, ttl time.Duration) *IdempotencyRecord {
+				stored, err := store.ReserveIdempotencyKey(&IdempotencyRecord{
+					Key:         key,
+					Fingerprint: fingerprint,
+					ExpiresAt:   time.Now().Add(ttl),
+				})
+				require.NoError(t, err)
+				return stored
+			}
+
+			require.Nil(t, reserve("key-1", "a", time.Minute))
+
+			// claimed but not answered yet
+			stored := reserve("key-1", "b", time.Minute)
+			require.NotNil(t, stored)
+			assert.Equal(t, "a", stored.Fingerprint)
+			assert.Equal(t, 0, stored.Status)
+
+			require.NoError(t, store.SaveIdempotencyResponse(&IdempotencyRecord{
+				Key:         "key-1",
+				Fingerprint: "a",
+				Status:      201,
+				Header:      map[string]string{"Content-Type": "application/json"},
+				Body:        []byte(`{"code":201}`),
+				ExpiresAt:   time.Now().Add(time.Hour),
+			}))
+			stored = reserve("key-1", "a", time.Minute)
+			require.NotNil(t, stored)
+			assert.Equal(t, 201, stored.Status)
+			assert.Equal(t, "application/json", stored.Header["Content-Type"])
+			assert.Equal(t, `{"code":201}`, string(stored.Body))
+
+			// released and expired keys can be claimed again
+			require.NoError(t, store.ReleaseIdempotencyKey("key-1"))
+			assert.Nil(t, reserve("key-1", "c", time.Minute))
+
+			require.Nil(t, reserve("key-2", "a", -time.Second))
+			assert.Nil(t, reserve("key-2", "b", time.Minute))
+		})
+	}
+}
@@ -1,30 +1,351 @@
 package db
 
 import (
+	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sensor-metadata-api/internal/tagquery"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type SensorMetadataDBImpl struct {
 	db *gorm.DB
+	// geography is set when the PostGIS column is present, see hasGeography.
+	geography bool
+	// search indexes the sensors where the database has no full-text search
+	search searchIndex
 }
 
 func NewSensorMetadataDB(db *gorm.DB) *SensorMetadataDBImpl {
-	return &SensorMetadataDBImpl{db: db}
+	return &SensorMetadataDBImpl{db: db, geography: hasGeography(db)}
 }
 
 func (d *SensorMetadataDBImpl) CreateSensorMetadata(sensor *SensorMetadata) error {
-	return d.db.Create(sensor).Error
+	sensor.Version = 1
+	return d.writeTransaction(func(tx *gorm.DB) error {
+		if err := checkNameFree(tx, sensor.Name); err != nil {
+			return err
+		}
+		if err := tx.Create(sensor).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, sensor, OpCreate)
+	})
 }
 
 func (d *SensorMetadataDBImpl) GetSensorMetadataByName(name string) (*SensorMetadata, error) {
 	var sensor SensorMetadata
-	if err := d.db.Where("name = ?", name).First(&sensor).Error; err != nil {
+	if err := d.db.Where("name_key = ?", canonicalName(name)).First(&sensor).Error; err != nil {
 		return nil, err
 	}
 
 	return &sensor, nil
 }
 
+// GetSensorMetadataByNameUnscoped also returns a soft-deleted sensor.
+func (d *SensorMetadataDBImpl) GetSensorMetadataByNameUnscoped(name string) (*SensorMetadata, error) {
+	var sensor SensorMetadata
+	if err := d.db.Unscoped().Where("name_key = ?", canonicalName(name)).First(&sensor).Error; err != nil {
+		return nil, err
+	}
+
+	return &sensor, nil
+}
+
+// UpdateSensorMetadata stores sensor if its Version is still the stored one, and
+// increments the version; otherwise it fails with ErrVersionConflict.
 func (d *SensorMetadataDBImpl) UpdateSensorMetadata(sensor *SensorMetadata) error {
-	return d.db.Save(sensor).Error
+	return d.writeTransaction(func(tx *gorm.DB) error {
+		if err := compareAndSwap(tx, sensor); err != nil {
+			return err
+		}
+		return recordRevision(tx, sensor, OpUpdate)
+	})
+}
+
+// DeleteSensorMetadata soft-deletes a sensor, hiding it from reads until it is
+// restored. A non-zero version must match the stored one.
+func (d *SensorMetadataDBImpl) DeleteSensorMetadata(name string, version int) error {
+	return d.writeTransaction(func(tx *gorm.DB) error {
+		var sensor SensorMetadata
+		if err := tx.Where("name_key = ?", canonicalName(name)).First(&sensor).Error; err != nil {
+			return err
+		}
+		if version != 0 && version != sensor.Version {
+			return ErrVersionConflict
+		}
+
+		now := time.Now()
+		res := tx.Model(&sensor).
+			Where("version = ?", sensor.Version).
+			UpdateColumns(map[string]interface{}{
+				"deleted_at": gorm.DeletedAt{Time: now, Valid: true},
+				"updated_at": now,
+				"version":    sensor.Version + 1,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		// the revision holds the row as stored
+		if err := tx.Unscoped().Where("id = ?", sensor.ID).Take(&sensor).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, &sensor, OpDelete)
+	})
+}
+
+// RestoreSensorMetadata brings back a soft-deleted sensor. A non-zero version
+// must match the stored one.
+func (d *SensorMetadataDBImpl) RestoreSensorMetadata(name string, version int) (*SensorMetadata, error) {
+	var sensor SensorMetadata
+	err := d.writeTransaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("name_key = ? AND deleted_at IS NOT NULL", canonicalName(name)).First(&sensor).Error
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != sensor.Version {
+			return ErrVersionConflict
+		}
+
+		err = tx.Unscoped().Model(&sensor).
+			UpdateColumns(map[string]interface{}{"deleted_at": nil, "updated_at": time.Now(), "version": sensor.Version + 1}).Error
+		if err != nil {
+			return err
+		}
+		// return and record the row as stored
+		if err = tx.Where("id = ?", sensor.ID).Take(&sensor).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, &sensor, OpRestore)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &sensor, nil
+}
+
+// PurgeSensorMetadata permanently removes a sensor, whether soft-deleted or not,
+// along with its aliases. Its revision history is replaced by a single purge
+// revision.
+func (d *SensorMetadataDBImpl) PurgeSensorMetadata(name string) error {
+	return d.writeTransaction(func(tx *gorm.DB) error {
+		var sensor SensorMetadata
+		if err := tx.Unscoped().Where("name_key = ?", canonicalName(name)).First(&sensor).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("sensor_id = ?", sensor.ID).Delete(&SensorRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("sensor_id = ?", sensor.ID).Delete(&SensorAlias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&sensor).Error; err != nil {
+			return err
+		}
+		tombstone(&sensor)
+		return recordRevision(tx, &sensor, OpPurge)
+	})
+}
+
+func (d *SensorMetadataDBImpl) ListSensorMetadata(opts ListOptions) (*SensorMetadataPage, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+	cur, err := opts.cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	q := d.db.Model(&SensorMetadata{})
+	if opts.IncludeDeleted {
+		q = q.Unscoped()
+	}
+	q = d.applyListFilters(q, &opts)
+
+	dir := "ASC"
+	if opts.Descending {
+		dir = "DESC"
+	}
+	cmp := ">"
+	if opts.Descending {
+		cmp = "<"
+	}
+
+	if opts.SortBy == SortByName {
+		if cur != nil {
+			q = q.Where("name "+cmp+" ?", cur.Name)
+		}
+		q = q.Order("name " + dir)
+	} else {
+		col := opts.SortBy
+		if cur != nil {
+			q = q.Where(
+				fmt.Sprintf("(%[1]s %[2]s ?) OR (%[1]s = ? AND name %[2]s ?)", col, cmp),
+				cur.Time, cur.Time, cur.Name,
+			)
+		}
+		q = q.Order(col + " " + dir).Order("name " + dir)
+	}
+
+	var sensors []*SensorMetadata
+	if err = q.Limit(opts.Limit + 1).Find(&sensors).Error; err != nil {
+		return nil, err
+	}
+
+	return opts.toPage(sensors), nil
+}
+
+// compareAndSwap writes the user editable fields of sensor when the stored row
+// is still at sensor.Version, and moves sensor to the next version. A changed
+// name leaves the old one behind as an alias.
+func compareAndSwap(tx *gorm.DB, sensor *SensorMetadata) error {
+	var stored SensorMetadata
+	if err := tx.Select("name", "version").Where("id = ?", sensor.ID).Take(&stored).Error; err != nil {
+		return err
+	}
+	if stored.Version != sensor.Version {
+		return ErrVersionConflict
+	}
+	if !sameName(stored.Name, sensor.Name) {
+		if err := checkNameUnused(tx, sensor.Name, sensor.ID); err != nil {
+			return err
+		}
+	}
+	if err := renameAliases(tx, stored.Name, sensor); err != nil {
+		return err
+	}
+
+	expected := sensor.Version
+	sensor.Version = expected + 1
+	sensor.UpdatedAt = time.Now()
+	sensor.NameKey = canonicalName(sensor.Name)
+
+	res := tx.Model(sensor).
+		Where("version = ?", expected).
+		Select("name", "name_key", "description", "latitude", "longitude", "tags", "updated_at", "version").
+		Updates(sensor)
+	if res.Error != nil {
+		sensor.Version = expected
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		// changed since it was read above
+		sensor.Version = expected
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// applyListFilters adds the WHERE conditions of opts to q.
+func (d *SensorMetadataDBImpl) applyListFilters(q *gorm.DB, opts *ListOptions) *gorm.DB {
+	if opts.NamePrefix != "" {
+		q = q.Where(`name LIKE ? ESCAPE '\'`, escapeLike(opts.NamePrefix)+"%")
+	}
+	if opts.CreatedAfter != nil {
+		q = q.Where("created_at >= ?", *opts.CreatedAfter)
+	}
+	if opts.CreatedBefore != nil {
+		q = q.Where("created_at < ?", *opts.CreatedBefore)
+	}
+	if opts.UpdatedAfter != nil {
+		q = q.Where("updated_at >= ?", *opts.UpdatedAfter)
+	}
+	if opts.UpdatedBefore != nil {
+		q = q.Where("updated_at < ?", *opts.UpdatedBefore)
+	}
+	if len(opts.Tags) > 0 {
+		q = q.Where(d.tagsContainAll(opts.Tags))
+	}
+	if len(opts.TagsAny) > 0 {
+		q = q.Where(d.tagsContainAny(opts.TagsAny))
+	}
+	if len(opts.TagsNone) > 0 {
+		q = q.Where(d.tagsNot(d.tagsContainAny(opts.TagsNone)))
+	}
+	for _, r := range opts.Selector {
+		q = q.Where(d.tagRequirement(r))
+	}
+	return q
+}
+
+// tagsContainAll matches rows carrying every one of tags. Postgres compares the
+// text[] column directly, other databases look into the JSON encoded array.
+func (d *SensorMetadataDBImpl) tagsContainAll(tags []string) clause.Expression {
+	if d.db.Dialector.Name() == DriverPostgres {
+		return clause.Expr{SQL: "tags @> CAST(? AS text[])", Vars: []interface{}{StringArray(tags)}}
+	}
+
+	conds := make([]clause.Expression, 0, len(tags))
+	for _, tag := range tags {
+		conds = append(conds, clause.Expr{
+			SQL:  "EXISTS (SELECT 1 FROM json_each(sensor_metadata.tags) WHERE json_each.value = ?)",
+			Vars: []interface{}{tag},
+		})
+	}
+	return clause.And(conds...)
+}
+
+// tagsContainAny matches rows carrying at least one of tags. On postgres both
+// this and tagsContainAll can use the GIN index on tags.
+func (d *SensorMetadataDBImpl) tagsContainAny(tags []string) clause.Expr {
+	if d.db.Dialector.Name() == DriverPostgres {
+		return clause.Expr{SQL: "tags && CAST(? AS text[])", Vars: []interface{}{StringArray(tags)}}
+	}
+	return clause.Expr{
+		SQL:  "EXISTS (SELECT 1 FROM json_each(sensor_metadata.tags) WHERE json_each.value IN ?)",
+		Vars: []interface{}{tags},
+	}
+}
+
+// tagsHaveKey matches rows carrying the label key, as the tag key or key=value.
+// The prefix is compared with substr, as LIKE ignores case on SQLite.
+func (d *SensorMetadataDBImpl) tagsHaveKey(key string) clause.Expr {
+	prefix := key + "="
+	vars := []interface{}{key, utf8.RuneCountInString(prefix), prefix}
+	if d.db.Dialector.Name() == DriverPostgres {
+		return clause.Expr{
+			SQL:  "EXISTS (SELECT 1 FROM unnest(tags) AS t(tag) WHERE t.tag = ? OR substr(t.tag, 1, ?) = ?)",
+			Vars: vars,
+		}
+	}
+	return clause.Expr{
+		SQL:  "EXISTS (SELECT 1 FROM json_each(sensor_metadata.tags) WHERE json_each.value = ? OR substr(json_each.value, 1, ?) = ?)",
+		Vars: vars,
+	}
+}
+
+// tagsNot negates a tag condition. A postgres row without tags holds NULL,
+// which matches no condition and so matches every negated one.
+func (d *SensorMetadataDBImpl) tagsNot(expr clause.Expr) clause.Expr {
+	if d.db.Dialector.Name() == DriverPostgres {
+		return clause.Expr{SQL: "(tags IS NULL OR NOT (" + expr.SQL + "))", Vars: expr.Vars}
+	}
+	return clause.Expr{SQL: "NOT (" + expr.SQL + ")", Vars: expr.Vars}
+}
+
+// tagRequirement translates a selector requirement, matching as
+// tagquery.Selector.Matches does in memory.
+func (d *SensorMetadataDBImpl) tagRequirement(r tagquery.Requirement) clause.Expr {
+	switch r.Operator {
+	case tagquery.Exists:
+		return d.tagsHaveKey(r.Key)
+	case tagquery.DoesNotExist:
+		return d.tagsNot(d.tagsHaveKey(r.Key))
+	case tagquery.NotEquals, tagquery.NotIn:
+		return d.tagsNot(d.tagsContainAny(r.Tags()))
+	default:
+		return d.tagsContainAny(r.Tags())
+	}
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
 }
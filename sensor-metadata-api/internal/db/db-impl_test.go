@@ -0,0 +1,177 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// testBackends returns an empty instance of every embeddable backend, so the
+// same behaviour can be asserted for each of them.
+func testBackends(t *testing.T) map[string]SensorMetadataDB {
+	return map[string]SensorMetadataDB{
+		DriverMemory: NewMemorySensorMetadataDB(),
+		DriverSQLite: newTestSQLiteDB(t),
+	}
+}
+
+func TestSoftDeleteRestorePurge(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedSensors(t, database, 3)
+
+			require.NoError(t, database.DeleteSensorMetadata("sensor-01", 1))
+			assert.ErrorIs(t, database.DeleteSensorMetadata("sensor-01", 2), gorm.ErrRecordNotFound)
+
+			// hidden from reads
+			_, err := database.GetSensorMetadataByName("sensor-01")
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			assert.Equal(t, []string{"sensor-00", "sensor-02"}, listAll(t, database, ListOptions{Limit: 10}))
+
+			// but visible to auditors
+			deleted, err := database.GetSensorMetadataByNameUnscoped("sensor-01")
+			require.NoError(t, err)
+			assert.True(t, deleted.DeletedAt.Valid)
+			assert.Len(t, listAll(t, database, ListOptions{Limit: 10, IncludeDeleted: true}), 3)
+
+			// a soft-deleted sensor keeps its name
+			err = database.CreateSensorMetadata(&SensorMetadata{Name: "sensor-01", Description: "again"})
+			assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+			_, err = database.RestoreSensorMetadata("sensor-01", deleted.Version-1)
+			assert.ErrorIs(t, err, ErrVersionConflict)
+			restored, err := database.RestoreSensorMetadata("sensor-01", deleted.Version)
+			require.NoError(t, err)
+			assert.False(t, restored.DeletedAt.Valid)
+			_, err = database.RestoreSensorMetadata("sensor-01", 0)
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+			_, err = database.GetSensorMetadataByName("sensor-01")
+			require.NoError(t, err)
+
+			// purge works on live and deleted sensors alike
+			require.NoError(t, database.DeleteSensorMetadata("sensor-02", 1))
+			require.NoError(t, database.PurgeSensorMetadata("sensor-02"))
+			require.NoError(t, database.PurgeSensorMetadata("sensor-00"))
+			assert.ErrorIs(t, database.PurgeSensorMetadata("sensor-00"), gorm.ErrRecordNotFound)
+
+			_, err = database.GetSensorMetadataByNameUnscoped("sensor-02")
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			assert.Equal(t, []string{"sensor-01"}, listAll(t, database, ListOptions{Limit: 10, IncludeDeleted: true}))
+
+			// the purged name is free again
+			require.NoError(t, database.CreateSensorMetadata(&SensorMetadata{Name: "sensor-00", Description: "again"}))
+		})
+	}
+}
+
+func TestDeleteRestoreUpdatedAt(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedSensors(t, database, 1)
+			created, err := database.GetSensorMetadataByName("sensor-00")
+			require.NoError(t, err)
+
+			time.Sleep(time.Millisecond)
+			require.NoError(t, database.DeleteSensorMetadata("sensor-00", 0))
+			deleted, err := database.GetSensorMetadataByNameUnscoped("sensor-00")
+			require.NoError(t, err)
+			assert.True(t, deleted.UpdatedAt.After(created.UpdatedAt))
+
+			time.Sleep(time.Millisecond)
+			restored, err := database.RestoreSensorMetadata("sensor-00", 0)
+			require.NoError(t, err)
+			assert.True(t, restored.UpdatedAt.After(deleted.UpdatedAt))
+
+			// the returned sensor and the revisions are the rows as stored
+			stored, err := database.GetSensorMetadataByName("sensor-00")
+			require.NoError(t, err)
+			assert.True(t, stored.UpdatedAt.Equal(restored.UpdatedAt))
+			assert.Equal(t, stored.Version, restored.Version)
+
+			revisions, err := database.ListSensorRevisions(stored.ID)
+			require.NoError(t, err)
+			require.Len(t, revisions, 3)
+			assert.True(t, revisions[1].Snapshot.UpdatedAt.Equal(deleted.UpdatedAt))
+			assert.True(t, revisions[1].Snapshot.DeletedAt.Valid)
+			assert.True(t, revisions[2].Snapshot.UpdatedAt.Equal(restored.UpdatedAt))
+			assert.Equal(t, restored.Version, revisions[2].Revision)
+		})
+	}
+}
+
+func TestUniqueDescription(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			// descriptions are unique, like the names, soft-deleted sensors included
+			require.NoError(t, database.CreateSensorMetadata(&SensorMetadata{Name: "roof-1", Description: "rooftop"}))
+			err := database.CreateSensorMetadata(&SensorMetadata{Name: "roof-2", Description: "rooftop"})
+			assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+			// which is not taken for a duplicate name
+			assert.NotErrorIs(t, err, ErrDuplicateName)
+			err = database.CreateSensorMetadata(&SensorMetadata{Name: "ROOF-1", Description: "other"})
+			assert.ErrorIs(t, err, ErrDuplicateName)
+
+			sensor := &SensorMetadata{Name: "roof-3", Description: "attic"}
+			require.NoError(t, database.CreateSensorMetadata(sensor))
+			sensor.Description = "rooftop"
+			err = database.UpdateSensorMetadata(sensor)
+			assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+			assert.NotErrorIs(t, err, ErrDuplicateName)
+			sensor.Description, sensor.Name = "attic", "roof-1"
+			assert.ErrorIs(t, database.UpdateSensorMetadata(sensor), ErrDuplicateName)
+
+			require.NoError(t, database.DeleteSensorMetadata("roof-1", 0))
+			err = database.CreateSensorMetadata(&SensorMetadata{Name: "roof-4", Description: "rooftop"})
+			assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+			assert.Equal(t, []string{"roof-3"}, listAll(t, database, ListOptions{Limit: 10}))
+		})
+	}
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedSensors(t, database, 1)
+
+			first, err := database.GetSensorMetadataByName("sensor-00")
+			require.NoError(t, err)
+			second, err := database.GetSensorMetadataByName("sensor-00")
+			require.NoError(t, err)
+			assert.Equal(t, 1, first.Version)
+
+			first.Description = "first"
+			require.NoError(t, database.UpdateSensorMetadata(first))
+			assert.Equal(t, 2, first.Version)
+
+			// the second writer read version 1 and loses
+			second.Description = "second"
+			assert.ErrorIs(t, database.UpdateSensorMetadata(second), ErrVersionConflict)
+			assert.Equal(t, 1, second.Version)
+
+			got, err := database.GetSensorMetadataByName("sensor-00")
+			require.NoError(t, err)
+			assert.Equal(t, "first", got.Description)
+			assert.Equal(t, 2, got.Version)
+
+			assert.ErrorIs(t, database.DeleteSensorMetadata("sensor-00", 1), ErrVersionConflict)
+			require.NoError(t, database.DeleteSensorMetadata("sensor-00", 2))
+
+			deleted, err := database.GetSensorMetadataByNameUnscoped("sensor-00")
+			require.NoError(t, err)
+			assert.Equal(t, 3, deleted.Version)
+
+			// every version is recorded as the revision of the same number
+			revisions, err := database.ListSensorRevisions(deleted.ID)
+			require.NoError(t, err)
+			require.Len(t, revisions, 3)
+			for i, rev := range revisions {
+				assert.Equal(t, i+1, rev.Revision)
+				assert.Equal(t, i+1, rev.Snapshot.Version)
+			}
+		})
+	}
+}
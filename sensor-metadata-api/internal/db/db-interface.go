@@ -1,7 +1,39 @@
 package db
 
+import (
+	"errors"
+	"github.com/google/uuid"
+)
+
+// ErrVersionConflict is returned by writes that expected another version of the
+// sensor than the stored one, i.e. it was changed concurrently.
+var ErrVersionConflict = errors.New("sensor metadata version conflict")
+
+// SensorMetadataDB stores sensor metadata. Sensor names are matched without
+// regard to case, but kept as they were written.
 type SensorMetadataDB interface {
 	CreateSensorMetadata(sensor *SensorMetadata) error
 	GetSensorMetadataByName(name string) (*SensorMetadata, error)
+	GetSensorMetadataByNameUnscoped(name string) (*SensorMetadata, error)
+	GetSensorMetadataByID(id uuid.UUID) (*SensorMetadata, error)
+	GetSensorMetadataByIDUnscoped(id uuid.UUID) (*SensorMetadata, error)
 	UpdateSensorMetadata(sensor *SensorMetadata) error
+	DeleteSensorMetadata(name string, version int) error
+	RestoreSensorMetadata(name string, version int) (*SensorMetadata, error)
+	PurgeSensorMetadata(name string) error
+	ListSensorMetadata(opts ListOptions) (*SensorMetadataPage, error)
+	GetChangeToken() (ChangeToken, error)
+	SearchSensorMetadata(opts SearchOptions) ([]SearchResult, error)
+	GetSensorStats(opts StatsOptions) (*SensorStats, error)
+	ListSensorRevisions(sensorID uuid.UUID) ([]SensorRevision, error)
+	GetSensorRevision(sensorID uuid.UUID, revision int) (*SensorRevision, error)
+	ListRevisionsSince(seq int64, limit int) ([]SensorRevision, error)
+	RollbackSensorMetadata(sensorID uuid.UUID, revision int, version int) (*SensorMetadata, error)
+	FindSensorMetadataInBox(box BoundingBox) ([]SensorMetadata, error)
+	FindSensorMetadataWithinRadius(center Location, radius float64) ([]SensorDistance, error)
+	FindNearestSensorMetadata(center Location, k int) ([]SensorDistance, error)
+	BulkWriteSensorMetadata(sensors []SensorMetadata, opts BulkOptions) ([]BulkResult, error)
+	ResolveSensorAlias(name string) (*SensorMetadata, error)
+	ListSensorAliases(sensorID uuid.UUID) ([]SensorAlias, error)
+	ReleaseSensorAlias(sensorID uuid.UUID, name string) error
 }
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sensor-metadata-api/internal/tagquery"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SortByName      = "name"
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort field")
+)
+
+// ListOptions selects, orders and paginates sensors. The time ranges are
+// half-open: a sensor matches when After <= t < Before.
+type ListOptions struct {
+	Limit      int
+	Cursor     string
+	SortBy     string
+	Descending bool
+
+	// Tags lists tags a sensor must all carry, TagsAny tags it must carry at
+	// least one of and TagsNone tags it must not carry.
+	Tags     []string
+	TagsAny  []string
+	TagsNone []string
+	// Selector filters on the key=value labels among the tags.
+	Selector tagquery.Selector
+
+	NamePrefix    string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+
+	// IncludeDeleted also lists soft-deleted sensors.
+	IncludeDeleted bool
+}
+
+// SensorMetadataPage is one page of a listing. NextCursor is empty on the last page.
+type SensorMetadataPage struct {
+	Items      []SensorMetadata `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// listCursor is the position after the last item of a page. It is tied to the
+// sort order it was issued for, and encoded as opaque base64 JSON.
+type listCursor struct {
+	SortBy     string    `json:"s"`
+	Descending bool      `json:"d,omitempty"`
+	Name       string    `json:"n"`
+	Time       time.Time `json:"t,omitempty"`
+}
+
+// normalize validates opts and fills in defaults.
+func (o *ListOptions) normalize() error {
+	if o.SortBy == "" {
+		o.SortBy = SortByName
+	}
+	switch o.SortBy {
+	case SortByName, SortByCreatedAt, SortByUpdatedAt:
+	default:
+		return ErrInvalidSort
+	}
+
+	if o.Limit <= 0 {
+		o.Limit = DefaultListLimit
+	}
+	if o.Limit > MaxListLimit {
+		o.Limit = MaxListLimit
+	}
+
+	return nil
+}
+
+// cursor decodes opts.Cursor, returning nil for the first page.
+func (o *ListOptions) cursor() (*listCursor, error) {
+	if o.Cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cur listCursor
+	if err = json.Unmarshal(raw, &cur); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cur.SortBy != o.SortBy || cur.Descending != o.Descending {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cur, nil
+}
+
+// nextCursor encodes the position after sensor.
+func (o *ListOptions) nextCursor(sensor *SensorMetadata) string {
+	cur := listCursor{SortBy: o.SortBy, Descending: o.Descending, Name: sensor.Name}
+	switch o.SortBy {
+	case SortByCreatedAt:
+		cur.Time = sensor.CreatedAt
+	case SortByUpdatedAt:
+		cur.Time = sensor.UpdatedAt
+	}
+
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// sortTime returns the timestamp a sensor is ordered by, for time based sorts.
+func (o *ListOptions) sortTime(sensor *SensorMetadata) time.Time {
+	if o.SortBy == SortByUpdatedAt {
+		return sensor.UpdatedAt
+	}
+	return sensor.CreatedAt
+}
+
+// compare orders a before b (<0), after b (>0) or at the same position (0),
+// using the name as tie-breaker.
+func (o *ListOptions) compare(a *SensorMetadata, bTime time.Time, bName string) int {
+	c := 0
+	if o.SortBy != SortByName {
+		c = o.sortTime(a).Compare(bTime)
+	}
+	if c == 0 {
+		c = strings.Compare(a.Name, bName)
+	}
+	if o.Descending {
+		c = -c
+	}
+	return c
+}
+
+// matches evaluates the filters of opts against a sensor in memory.
+func (o *ListOptions) matches(sensor *SensorMetadata) bool {
+	if sensor.DeletedAt.Valid && !o.IncludeDeleted {
+		return false
+	}
+	if o.NamePrefix != "" && !strings.HasPrefix(sensor.Name, o.NamePrefix) {
+		return false
+	}
+	if !inRange(sensor.CreatedAt, o.CreatedAfter, o.CreatedBefore) {
+		return false
+	}
+	if !inRange(sensor.UpdatedAt, o.UpdatedAfter, o.UpdatedBefore) {
+		return false
+	}
+	for _, tag := range o.Tags {
+		if !sensor.Tags.Contains(tag) {
+			return false
+		}
+	}
+	if len(o.TagsAny) > 0 && !sensor.Tags.ContainsAny(o.TagsAny) {
+		return false
+	}
+	if sensor.Tags.ContainsAny(o.TagsNone) {
+		return false
+	}
+	return o.Selector.Matches(sensor.Tags)
+}
+
+// page filters, sorts and paginates sensors in memory.
+func (o *ListOptions) page(sensors []*SensorMetadata) (*SensorMetadataPage, error) {
+	if err := o.normalize(); err != nil {
+		return nil, err
+	}
+	cur, err := o.cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]*SensorMetadata, 0, len(sensors))
+	for _, s := range sensors {
+		if !o.matches(s) {
+			continue
+		}
+		if cur != nil && o.compare(s, cur.Time, cur.Name) <= 0 {
+			continue
+		}
+		matched = append(matched, s)
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return o.compare(matched[i], o.sortTime(matched[j]), matched[j].Name) < 0
+	})
+
+	return o.toPage(matched), nil
+}
+
+// toPage trims a result fetched with one extra row to the page size.
+func (o *ListOptions) toPage(sensors []*SensorMetadata) *SensorMetadataPage {
+	page := &SensorMetadataPage{Items: make([]SensorMetadata, 0, o.Limit)}
+	for i, s := range sensors {
+		if i == o.Limit {
+			page.NextCursor = o.nextCursor(sensors[i-1])
+			break
+		}
+		page.Items = append(page.Items, *s)
+	}
+	return page
+}
+
+func inRange(t time.Time, after, before *time.Time) bool {
+	if after != nil && t.Before(*after) {
+		return false
+	}
+	if before != nil && !t.Before(*before) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sensor-metadata-api/internal/tagquery"
+)
+
+// seedSensors creates n sensors named sensor-00..n with increasing creation times.
+func seedSensors(t *testing.T, database SensorMetadataDB, n int) time.Time {
+	t.Helper()
+
+	start := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		tags := []string{"all"}
+		if i%2 == 0 {
+			tags = append(tags, "even")
+		}
+		require.NoError(t, database.CreateSensorMetadata(&SensorMetadata{
+			Name:        fmt.Sprintf("sensor-%02d", i),
+			Description: fmt.Sprintf("sensor number %d", i),
+			Location:    Location{Latitude: float64(i), Longitude: float64(-i)},
+			Tags:        tags,
+			CreatedAt:   start.Add(time.Duration(n-i) * time.Hour),
+			UpdatedAt:   start.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	return start
+}
+
+// listAll follows the cursors until the last page and returns the names seen.
+func listAll(t *testing.T, database SensorMetadataDB, opts ListOptions) []string {
+	t.Helper()
+
+	var names []string
+	for {
+		page, err := database.ListSensorMetadata(opts)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Items), opts.Limit)
+		for _, s := range page.Items {
+			names = append(names, s.Name)
+		}
+		if page.NextCursor == "" {
+			return names
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+func TestListSensorMetadata(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			start := seedSensors(t, database, 7)
+
+			names := listAll(t, database, ListOptions{Limit: 3})
+			assert.Equal(t, []string{
+				"sensor-00", "sensor-01", "sensor-02", "sensor-03", "sensor-04", "sensor-05", "sensor-06",
+			}, names)
+
+			// created_at decreases with the index
+			names = listAll(t, database, ListOptions{Limit: 2, SortBy: SortByCreatedAt})
+			assert.Equal(t, []string{
+				"sensor-06", "sensor-05", "sensor-04", "sensor-03", "sensor-02", "sensor-01", "sensor-00",
+			}, names)
+
+			names = listAll(t, database, ListOptions{Limit: 2, SortBy: SortByUpdatedAt, Descending: true})
+			assert.Equal(t, []string{
+				"sensor-06", "sensor-05", "sensor-04", "sensor-03", "sensor-02", "sensor-01", "sensor-00",
+			}, names)
+
+			names = listAll(t, database, ListOptions{Limit: 2, Tags: []string{"all", "even"}})
+			assert.Equal(t, []string{"sensor-00", "sensor-02", "sensor-04", "sensor-06"}, names)
+
+			after, before := start.Add(2*time.Hour), start.Add(4*time.Hour)
+			names = listAll(t, database, ListOptions{Limit: 10, UpdatedAfter: &after, UpdatedBefore: &before})
+			assert.Equal(t, []string{"sensor-02", "sensor-03"}, names)
+
+			require.NoError(t, database.CreateSensorMetadata(&SensorMetadata{Name: "Sensor_X", Description: "x"}))
+			names = listAll(t, database, ListOptions{Limit: 10, NamePrefix: "sensor-0"})
+			assert.Len(t, names, 7)
+			names = listAll(t, database, ListOptions{Limit: 10, NamePrefix: "Sensor_"})
+			assert.Equal(t, []string{"Sensor_X"}, names)
+		})
+	}
+}
+
+func TestListSensorMetadata_TagFilters(t *testing.T) {
+	sensors := map[string][]string{
+		"attic":   {"env=prod", "floor=3", "outdoor"},
+		"cellar":  {"env=staging", "floor=-1", "deprecated"},
+		"garden":  {"env=prod", "outdoor=yes"},
+		"hallway": {"Env=prod", "floor=30"},
+		"shed":    nil,
+	}
+
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			for sensor, tags := range sensors {
+				require.NoError(t, database.CreateSensorMetadata(&SensorMetadata{Name: sensor, Description: sensor, Tags: tags}))
+			}
+
+			tests := map[string]struct {
+				opts ListOptions
+				want []string
+			}{
+				"Any":       {ListOptions{TagsAny: []string{"outdoor", "deprecated"}}, []string{"attic", "cellar"}},
+				"None":      {ListOptions{TagsNone: []string{"env=prod", "deprecated"}}, []string{"hallway", "shed"}},
+				"All_Any":   {ListOptions{Tags: []string{"env=prod"}, TagsAny: []string{"floor=3", "outdoor=yes"}}, []string{"attic", "garden"}},
+				"In":        {ListOptions{Selector: selector(t, "env in (prod, staging)")}, []string{"attic", "cellar", "garden"}},
+				"Not_In":    {ListOptions{Selector: selector(t, "env notin (staging)")}, []string{"attic", "garden", "hallway", "shed"}},
+				"Equals":    {ListOptions{Selector: selector(t, "floor=3")}, []string{"attic"}},
+				"Not_Equal": {ListOptions{Selector: selector(t, "env!=prod")}, []string{"cellar", "hallway", "shed"}},
+				"Exists":    {ListOptions{Selector: selector(t, "outdoor")}, []string{"attic", "garden"}},
+				"Not_Exist": {ListOptions{Selector: selector(t, "!floor")}, []string{"garden", "shed"}},
+				"Combined":  {ListOptions{Selector: selector(t, "env in (prod,staging), !deprecated, floor=3")}, []string{"attic"}},
+			}
+
+			for name, tt := range tests {
+				t.Run(name, func(t *testing.T) {
+					tt.opts.Limit = 2
+					assert.Equal(t, tt.want, listAll(t, database, tt.opts))
+				})
+			}
+		})
+	}
+}
+
+func TestTagFilterPushDown(t *testing.T) {
+	d := &SensorMetadataDBImpl{db: dryRunPostgres(t)}
+	opts := &ListOptions{
+		TagsAny:  []string{"a", "b"},
+		TagsNone: []string{"c"},
+		Selector: selector(t, "!floor"),
+	}
+	var sensors []SensorMetadata
+	sql := d.applyListFilters(d.db.Model(&SensorMetadata{}), opts).Find(&sensors).Statement.SQL.String()
+
+	assert.Contains(t, sql, "tags && CAST($1 AS text[])")
+	assert.Contains(t, sql, "(tags IS NULL OR NOT (tags && CAST($2 AS text[])))")
+	assert.Contains(t, sql, "(tags IS NULL OR NOT (EXISTS (SELECT 1 FROM unnest(tags) AS t(tag) WHERE t.tag = $3 OR substr(t.tag, 1, $4) = $5)))")
+}
+
+func selector(t *testing.T, s string) tagquery.Selector {
+	t.Helper()
+	sel, err := tagquery.Parse(s)
+	require.NoError(t, err)
+	return sel
+}
+
+func TestListSensorMetadata_InvalidCursor(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedSensors(t, database, 3)
+
+			_, err := database.ListSensorMetadata(ListOptions{Cursor: "not-a-cursor"})
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+
+			// a cursor only continues the sort order it was issued for
+			page, err := database.ListSensorMetadata(ListOptions{Limit: 1})
+			require.NoError(t, err)
+			_, err = database.ListSensorMetadata(ListOptions{Limit: 1, Cursor: page.NextCursor, SortBy: SortByCreatedAt})
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+
+			_, err = database.ListSensorMetadata(ListOptions{SortBy: "description"})
+			assert.ErrorIs(t, err, ErrInvalidSort)
+		})
+	}
+}
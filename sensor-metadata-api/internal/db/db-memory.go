@@ -0,0 +1,697 @@
+package db
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MemorySensorMetadataDB is a concurrency-safe, in-process SensorMetadataDB.
+// It mirrors the behaviour of SensorMetadataDBImpl, including gorm's sentinel
+// errors, so it can stand in for postgres in local development and tests.
+type MemorySensorMetadataDB struct {
+	mu      sync.RWMutex
+	sensors map[uuid.UUID]*SensorMetadata
+	// names and aliases are keyed by canonicalName
+	names     map[string]uuid.UUID
+	aliases   map[string]SensorAlias
+	revisions map[uuid.UUID][]SensorRevision
+	seq       int64
+
+	// idempotency records do not touch the sensors, so they have their own lock
+	idempotencyMu sync.Mutex
+	idempotency   map[string]IdempotencyRecord
+
+	search searchIndex
+}
+
+func NewMemorySensorMetadataDB() *MemorySensorMetadataDB {
+	return &MemorySensorMetadataDB{
+		sensors:   make(map[uuid.UUID]*SensorMetadata),
+		names:     make(map[string]uuid.UUID),
+		aliases:   make(map[string]SensorAlias),
+		revisions: make(map[uuid.UUID][]SensorRevision),
+
+		idempotency: make(map[string]IdempotencyRecord),
+	}
+}
+
+func (d *MemorySensorMetadataDB) CreateSensorMetadata(sensor *SensorMetadata) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.insert(sensor); err != nil {
+		return err
+	}
+	d.record(sensor, OpCreate)
+	return nil
+}
+
+func (d *MemorySensorMetadataDB) GetSensorMetadataByName(name string) (*SensorMetadata, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	id, ok := d.names[canonicalName(name)]
+	if !ok || d.sensors[id].DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return cloneSensor(d.sensors[id]), nil
+}
+
+// GetSensorMetadataByNameUnscoped also returns a soft-deleted sensor.
+func (d *MemorySensorMetadataDB) GetSensorMetadataByNameUnscoped(name string) (*SensorMetadata, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	id, ok := d.names[canonicalName(name)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return cloneSensor(d.sensors[id]), nil
+}
+
+// UpdateSensorMetadata stores sensor if its Version is still the stored one, and
+// increments the version; otherwise it fails with ErrVersionConflict.
+func (d *MemorySensorMetadataDB) UpdateSensorMetadata(sensor *SensorMetadata) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.save(sensor); err != nil {
+		return err
+	}
+	d.record(sensor, OpUpdate)
+	return nil
+}
+
+// DeleteSensorMetadata soft-deletes a sensor, hiding it from reads until it is
+// restored. A non-zero version must match the stored one.
+func (d *MemorySensorMetadataDB) DeleteSensorMetadata(name string, version int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id, ok := d.names[canonicalName(name)]
+	if !ok || d.sensors[id].DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+
+	sensor := d.sensors[id]
+	if version != 0 && version != sensor.Version {
+		return ErrVersionConflict
+	}
+	now := time.Now()
+	sensor.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	sensor.UpdatedAt = now
+	sensor.Version++
+	d.record(sensor, OpDelete)
+	return nil
+}
+
+// RestoreSensorMetadata brings back a soft-deleted sensor. A non-zero version
+// must match the stored one.
+func (d *MemorySensorMetadataDB) RestoreSensorMetadata(name string, version int) (*SensorMetadata, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id, ok := d.names[canonicalName(name)]
+	if !ok || !d.sensors[id].DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if version != 0 && version != d.sensors[id].Version {
+		return nil, ErrVersionConflict
+	}
+
+	sensor := d.sensors[id]
+	sensor.DeletedAt = gorm.DeletedAt{}
+	sensor.UpdatedAt = time.Now()
+	sensor.Version++
+	d.record(sensor, OpRestore)
+	return cloneSensor(sensor), nil
+}
+
+// PurgeSensorMetadata permanently removes a sensor, whether soft-deleted or not,
+// along with its aliases. Its revision history is replaced by a single purge
+// revision.
+func (d *MemorySensorMetadataDB) PurgeSensorMetadata(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id, ok := d.names[canonicalName(name)]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	sensor := d.sensors[id]
+	delete(d.names, canonicalName(name))
+	delete(d.sensors, id)
+	delete(d.revisions, id)
+	for key, alias := range d.aliases {
+		if alias.SensorID == id {
+			delete(d.aliases, key)
+		}
+	}
+	tombstone(sensor)
+	d.record(sensor, OpPurge)
+	return nil
+}
+
+func (d *MemorySensorMetadataDB) GetSensorMetadataByID(id uuid.UUID) (*SensorMetadata, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	sensor, ok := d.sensors[id]
+	if !ok || sensor.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return cloneSensor(sensor), nil
+}
+
+// GetSensorMetadataByIDUnscoped also returns a soft-deleted sensor.
+func (d *MemorySensorMetadataDB) GetSensorMetadataByIDUnscoped(id uuid.UUID) (*SensorMetadata, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	sensor, ok := d.sensors[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return cloneSensor(sensor), nil
+}
+
+// ResolveSensorAlias returns the sensor, soft-deleted or not, that name is an
+// alias of.
+func (d *MemorySensorMetadataDB) ResolveSensorAlias(name string) (*SensorMetadata, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	alias, ok := d.aliases[canonicalName(name)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return cloneSensor(d.sensors[alias.SensorID]), nil
+}
+
+func (d *MemorySensorMetadataDB) ListSensorAliases(sensorID uuid.UUID) ([]SensorAlias, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	aliases := make([]SensorAlias, 0)
+	for _, alias := range d.aliases {
+		if alias.SensorID == sensorID {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		if !aliases[i].CreatedAt.Equal(aliases[j].CreatedAt) {
+			return aliases[i].CreatedAt.Before(aliases[j].CreatedAt)
+		}
+		return aliases[i].Name < aliases[j].Name
+	})
+	return aliases, nil
+}
+
+// ReleaseSensorAlias removes an alias of the sensor, freeing the name for
+// other sensors.
+func (d *MemorySensorMetadataDB) ReleaseSensorAlias(sensorID uuid.UUID, name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := canonicalName(name)
+	alias, ok := d.aliases[key]
+	if !ok || alias.SensorID != sensorID {
+		return gorm.ErrRecordNotFound
+	}
+
+	delete(d.aliases, key)
+	return nil
+}
+
+func (d *MemorySensorMetadataDB) ListSensorMetadata(opts ListOptions) (*SensorMetadataPage, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	sensors := make([]*SensorMetadata, 0, len(d.sensors))
+	for _, s := range d.sensors {
+		sensors = append(sensors, s)
+	}
+
+	page, err := opts.page(sensors)
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Items {
+		page.Items[i] = *cloneSensor(&page.Items[i])
+	}
+
+	return page, nil
+}
+
+func (d *MemorySensorMetadataDB) GetChangeToken() (ChangeToken, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return ChangeToken{Seq: d.seq, Sensors: int64(len(d.sensors))}, nil
+}
+
+func (d *MemorySensorMetadataDB) ListSensorRevisions(sensorID uuid.UUID) ([]SensorRevision, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	revisions := make([]SensorRevision, 0, len(d.revisions[sensorID]))
+	for _, rev := range d.revisions[sensorID] {
+		revisions = append(revisions, cloneRevision(rev))
+	}
+	return revisions, nil
+}
+
+// ListRevisionsSince returns up to limit revisions of any sensor recorded after
+// seq, in the order they were recorded.
+func (d *MemorySensorMetadataDB) ListRevisionsSince(seq int64, limit int) ([]SensorRevision, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	revisions := make([]SensorRevision, 0)
+	for _, revs := range d.revisions {
+		for _, rev := range revs {
+			if rev.Seq > seq {
+				revisions = append(revisions, rev)
+			}
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Seq < revisions[j].Seq })
+	if len(revisions) > limit {
+		revisions = revisions[:limit]
+	}
+	for i := range revisions {
+		revisions[i] = cloneRevision(revisions[i])
+	}
+	return revisions, nil
+}
+
+func (d *MemorySensorMetadataDB) GetSensorRevision(sensorID uuid.UUID, revision int) (*SensorRevision, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rev, ok := d.revision(sensorID, revision)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	c := cloneRevision(rev)
+	return &c, nil
+}
+
+// RollbackSensorMetadata restores the fields of an earlier revision onto a live
+// sensor, recording the result as a new revision. A non-zero version must
+// match the stored one.
+func (d *MemorySensorMetadataDB) RollbackSensorMetadata(sensorID uuid.UUID, revision int, version int) (*SensorMetadata, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current, ok := d.sensors[sensorID]
+	if !ok || current.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if version != 0 && version != current.Version {
+		return nil, ErrVersionConflict
+	}
+	rev, ok := d.revision(sensorID, revision)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	sensor := cloneSensor(current)
+	applySnapshot(sensor, &rev)
+	if err := d.save(sensor); err != nil {
+		return nil, err
+	}
+	d.record(sensor, OpRollback)
+	return sensor, nil
+}
+
+func (d *MemorySensorMetadataDB) revision(sensorID uuid.UUID, revision int) (SensorRevision, bool) {
+	for _, rev := range d.revisions[sensorID] {
+		if rev.Revision == revision {
+			return rev, true
+		}
+	}
+	return SensorRevision{}, false
+}
+
+// record appends the next revision of sensor. Callers hold d.mu.
+func (d *MemorySensorMetadataDB) record(sensor *SensorMetadata, op string) {
+	d.seq++
+	d.revisions[sensor.ID] = append(d.revisions[sensor.ID], SensorRevision{
+		Seq:       d.seq,
+		SensorID:  sensor.ID,
+		Name:      sensor.Name,
+		Revision:  sensor.Version,
+		Operation: op,
+		Snapshot:  *cloneSensor(sensor),
+		CreatedAt: time.Now(),
+	})
+}
+
+// BulkWriteSensorMetadata writes sensors under a single lock. A dry run, or an
+// atomic batch with a failing sensor, is undone by restoring the maps as they
+// were before it.
+func (d *MemorySensorMetadataDB) BulkWriteSensorMetadata(sensors []SensorMetadata, opts BulkOptions) ([]BulkResult, error) {
+	if err := opts.validate(len(sensors)); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// writes only ever replace map entries, so shallow copies are enough to undo them
+	sensorsBefore := make(map[uuid.UUID]*SensorMetadata, len(d.sensors))
+	for id, s := range d.sensors {
+		sensorsBefore[id] = s
+	}
+	namesBefore := make(map[string]uuid.UUID, len(d.names))
+	for name, id := range d.names {
+		namesBefore[name] = id
+	}
+	aliasesBefore := make(map[string]SensorAlias, len(d.aliases))
+	for name, alias := range d.aliases {
+		aliasesBefore[name] = alias
+	}
+	revisionsBefore := make(map[uuid.UUID][]SensorRevision, len(d.revisions))
+	for id, revs := range d.revisions {
+		revisionsBefore[id] = revs
+	}
+	seqBefore := d.seq
+
+	results := make([]BulkResult, len(sensors))
+	for i := range sensors {
+		results[i] = BulkResult{Index: i, Name: sensors[i].Name}
+		outcome, err := d.bulkWrite(&sensors[i], opts, opts.located(i))
+		if err != nil {
+			results[i].Outcome = OutcomeFailed
+			results[i].Err = err
+			continue
+		}
+		results[i].Outcome = outcome
+		results[i].Version = sensors[i].Version
+	}
+
+	if finishBulk(results, opts.Atomic) || opts.DryRun {
+		d.sensors, d.names, d.aliases, d.revisions, d.seq = sensorsBefore, namesBefore, aliasesBefore, revisionsBefore, seqBefore
+	}
+	return results, nil
+}
+
+// bulkWrite creates or updates one sensor of a batch. Callers hold d.mu.
+func (d *MemorySensorMetadataDB) bulkWrite(sensor *SensorMetadata, opts BulkOptions, located bool) (string, error) {
+	id, ok := d.names[canonicalName(sensor.Name)]
+	if err := checkBulkItem(sensor, ok, opts, located); err != nil {
+		return "", err
+	}
+
+	switch {
+	case !ok:
+		if err := d.insert(sensor); err != nil {
+			return "", err
+		}
+		d.record(sensor, OpCreate)
+		return OutcomeCreated, nil
+	case opts.Mode == BulkInsert:
+		return "", ErrDuplicateName
+	case d.sensors[id].DeletedAt.Valid:
+		return "", ErrSensorDeleted
+	}
+
+	stored := cloneSensor(d.sensors[id])
+	mergeSensor(stored, sensor, opts, located)
+	if err := d.save(stored); err != nil {
+		return "", err
+	}
+	d.record(stored, OpUpdate)
+	*sensor = *stored
+
+	if opts.Mode == BulkReplace {
+		return OutcomeReplaced, nil
+	}
+	return OutcomeUpdated, nil
+}
+
+// save replaces the live record with the same ID and version, moving sensor to
+// the next version. Callers hold d.mu.
+func (d *MemorySensorMetadataDB) save(sensor *SensorMetadata) error {
+	current, ok := d.sensors[sensor.ID]
+	if !ok || current.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	if current.Version != sensor.Version {
+		return ErrVersionConflict
+	}
+
+	key := canonicalName(sensor.Name)
+	if other, taken := d.names[key]; taken && other != sensor.ID {
+		return ErrDuplicateName
+	}
+	if d.descriptionTaken(sensor) {
+		return gorm.ErrDuplicatedKey
+	}
+	if !sameName(current.Name, sensor.Name) {
+		if alias, taken := d.aliases[key]; taken && alias.SensorID != sensor.ID {
+			return ErrNameReserved
+		}
+		// the old name stays an alias, and one taken back is no longer needed
+		delete(d.aliases, key)
+		d.aliases[canonicalName(current.Name)] = SensorAlias{Name: current.Name, NameKey: canonicalName(current.Name), SensorID: sensor.ID, CreatedAt: time.Now()}
+	}
+
+	sensor.UpdatedAt = time.Now()
+	sensor.Version++
+	sensor.NameKey = key
+
+	delete(d.names, canonicalName(current.Name))
+	d.names[key] = sensor.ID
+	d.sensors[sensor.ID] = cloneSensor(sensor)
+
+	return nil
+}
+
+// descriptionTaken reports whether another sensor, soft-deleted or not, has
+// the description of sensor, which the unique constraint of the sql backends
+// forbids. Callers hold d.mu.
+func (d *MemorySensorMetadataDB) descriptionTaken(sensor *SensorMetadata) bool {
+	for id, other := range d.sensors {
+		if id != sensor.ID && other.Description == sensor.Description {
+			return true
+		}
+	}
+	return false
+}
+
+// insert stores a copy of sensor as version 1, filling in the ID and timestamps
+// the way the postgres column defaults and gorm's autoCreateTime would. Callers
+// hold d.mu.
+func (d *MemorySensorMetadataDB) insert(sensor *SensorMetadata) error {
+	key := canonicalName(sensor.Name)
+	if _, taken := d.names[key]; taken {
+		return ErrDuplicateName
+	}
+	if _, taken := d.aliases[key]; taken {
+		return ErrNameReserved
+	}
+	if sensor.ID == uuid.Nil {
+		sensor.ID = uuid.New()
+	} else if _, taken := d.sensors[sensor.ID]; taken {
+		return gorm.ErrDuplicatedKey
+	}
+	if d.descriptionTaken(sensor) {
+		return gorm.ErrDuplicatedKey
+	}
+
+	sensor.Version = 1
+	sensor.NameKey = key
+	now := time.Now()
+	if sensor.CreatedAt.IsZero() {
+		sensor.CreatedAt = now
+	}
+	if sensor.UpdatedAt.IsZero() {
+		sensor.UpdatedAt = now
+	}
+
+	d.sensors[sensor.ID] = cloneSensor(sensor)
+	d.names[key] = sensor.ID
+
+	return nil
+}
+
+// cloneSensor returns a deep copy so callers never share memory with the store.
+func cloneSensor(sensor *SensorMetadata) *SensorMetadata {
+	c := *sensor
+	if sensor.Tags != nil {
+		c.Tags = append(c.Tags[:0:0], sensor.Tags...)
+	}
+	return &c
+}
+
+func cloneRevision(rev SensorRevision) SensorRevision {
+	rev.Snapshot = *cloneSensor(&rev.Snapshot)
+	return rev
+}
+
+// GetSensorStats sums up the sensors matching the list filters of opts.
+func (d *MemorySensorMetadataDB) GetSensorStats(opts StatsOptions) (*SensorStats, error) {
+	agg, err := newStatsAggregator(&opts)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, s := range d.sensors {
+		if opts.matches(s) {
+			agg.add(s)
+		}
+	}
+	return agg.result(), nil
+}
+
+// SearchSensorMetadata ranks the live sensors matching a full-text search.
+func (d *MemorySensorMetadataDB) SearchSensorMetadata(opts SearchOptions) ([]SearchResult, error) {
+	q, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := d.GetChangeToken()
+	if err != nil {
+		return nil, err
+	}
+	return d.search.search(q, opts, token, func() ([]SensorMetadata, error) {
+		d.mu.RLock()
+		defer d.mu.RUnlock()
+
+		sensors := make([]SensorMetadata, 0, len(d.sensors))
+		for _, s := range d.sensors {
+			if !s.DeletedAt.Valid {
+				sensors = append(sensors, *cloneSensor(s))
+			}
+		}
+		return sensors, nil
+	})
+}
+
+// FindSensorMetadataInBox returns the live sensors inside box, ordered by name.
+func (d *MemorySensorMetadataDB) FindSensorMetadataInBox(box BoundingBox) ([]SensorMetadata, error) {
+	if err := box.validate(); err != nil {
+		return nil, err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	sensors := make([]SensorMetadata, 0)
+	for _, s := range d.sensors {
+		if !s.DeletedAt.Valid && box.Contains(s.Location) {
+			sensors = append(sensors, *cloneSensor(s))
+		}
+	}
+	sort.Slice(sensors, func(i, j int) bool { return sensors[i].Name < sensors[j].Name })
+
+	return sensors, nil
+}
+
+// FindSensorMetadataWithinRadius returns the live sensors at most radius meters
+// from center, nearest first.
+func (d *MemorySensorMetadataDB) FindSensorMetadataWithinRadius(center Location, radius float64) ([]SensorDistance, error) {
+	if !center.Valid() || radius <= 0 {
+		return nil, ErrInvalidGeoQuery
+	}
+
+	results := d.distances(center)
+	n := sort.Search(len(results), func(i int) bool { return results[i].DistanceMeters > radius })
+	return results[:n], nil
+}
+
+// FindNearestSensorMetadata returns the k live sensors closest to center,
+// nearest first.
+func (d *MemorySensorMetadataDB) FindNearestSensorMetadata(center Location, k int) ([]SensorDistance, error) {
+	if !center.Valid() || k < 1 || k > MaxNearest {
+		return nil, ErrInvalidGeoQuery
+	}
+
+	results := d.distances(center)
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// distances measures every live sensor against center, nearest first.
+func (d *MemorySensorMetadataDB) distances(center Location) []SensorDistance {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	results := make([]SensorDistance, 0, len(d.sensors))
+	for _, s := range d.sensors {
+		if !s.DeletedAt.Valid {
+			results = append(results, SensorDistance{SensorMetadata: *cloneSensor(s), DistanceMeters: center.DistanceTo(s.Location)})
+		}
+	}
+	sortByDistance(results)
+	return results
+}
+
+func (d *MemorySensorMetadataDB) ReserveIdempotencyKey(record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	d.idempotencyMu.Lock()
+	defer d.idempotencyMu.Unlock()
+
+	now := time.Now()
+	for key, r := range d.idempotency {
+		if !r.ExpiresAt.After(now) {
+			delete(d.idempotency, key)
+		}
+	}
+
+	if stored, ok := d.idempotency[record.Key]; ok {
+		return cloneIdempotencyRecord(stored), nil
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = now
+	}
+	d.idempotency[record.Key] = *cloneIdempotencyRecord(*record)
+	return nil, nil
+}
+
+func (d *MemorySensorMetadataDB) SaveIdempotencyResponse(record *IdempotencyRecord) error {
+	d.idempotencyMu.Lock()
+	defer d.idempotencyMu.Unlock()
+
+	if _, ok := d.idempotency[record.Key]; ok {
+		d.idempotency[record.Key] = *cloneIdempotencyRecord(*record)
+	}
+	return nil
+}
+
+func (d *MemorySensorMetadataDB) ReleaseIdempotencyKey(key string) error {
+	d.idempotencyMu.Lock()
+	defer d.idempotencyMu.Unlock()
+
+	delete(d.idempotency, key)
+	return nil
+}
+
+func cloneIdempotencyRecord(record IdempotencyRecord) *IdempotencyRecord {
+	if record.Header != nil {
+		header := make(map[string]string, len(record.Header))
+		for k, v := range record.Header {
+			header[k] = v
+		}
+		record.Header = header
+	}
+	record.Body = append([]byte(nil), record.Body...)
+	return &record
+}
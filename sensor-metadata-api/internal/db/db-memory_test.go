@@ -0,0 +1,113 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMemorySensorMetadataDB_CreateAndGet(t *testing.T) {
+	database := NewMemorySensorMetadataDB()
+
+	sensor := &SensorMetadata{
+		Name:     "sensor-1",
+		Location: Location{Latitude: 40.0, Longitude: -80.0},
+		Tags:     []string{"tag1"},
+	}
+	require.NoError(t, database.CreateSensorMetadata(sensor))
+
+	// ID and timestamps are filled in on the caller's value, as gorm does
+	assert.NotEqual(t, uuid.Nil, sensor.ID)
+	assert.False(t, sensor.CreatedAt.IsZero())
+	assert.False(t, sensor.UpdatedAt.IsZero())
+
+	got, err := database.GetSensorMetadataByName("sensor-1")
+	require.NoError(t, err)
+	assert.Equal(t, sensor.ID, got.ID)
+	assert.Equal(t, sensor.Location, got.Location)
+
+	// returned values are copies
+	got.Tags[0] = "changed"
+	again, err := database.GetSensorMetadataByName("sensor-1")
+	require.NoError(t, err)
+	assert.Equal(t, "tag1", again.Tags[0])
+}
+
+func TestMemorySensorMetadataDB_NotFound(t *testing.T) {
+	database := NewMemorySensorMetadataDB()
+
+	_, err := database.GetSensorMetadataByName("missing")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestMemorySensorMetadataDB_UniqueName(t *testing.T) {
+	database := NewMemorySensorMetadataDB()
+
+	require.NoError(t, database.CreateSensorMetadata(&SensorMetadata{Name: "sensor-1", Description: "first"}))
+	err := database.CreateSensorMetadata(&SensorMetadata{Name: "sensor-1", Description: "second"})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+	// renaming onto an existing name is rejected as well
+	other := &SensorMetadata{Name: "sensor-2", Description: "third"}
+	require.NoError(t, database.CreateSensorMetadata(other))
+	other.Name = "sensor-1"
+	assert.ErrorIs(t, database.UpdateSensorMetadata(other), gorm.ErrDuplicatedKey)
+}
+
+func TestMemorySensorMetadataDB_Update(t *testing.T) {
+	database := NewMemorySensorMetadataDB()
+
+	sensor := &SensorMetadata{Name: "sensor-1"}
+	require.NoError(t, database.CreateSensorMetadata(sensor))
+	createdAt := sensor.UpdatedAt
+
+	sensor.Name = "sensor-renamed"
+	sensor.Tags = []string{"tag2"}
+	require.NoError(t, database.UpdateSensorMetadata(sensor))
+	assert.False(t, sensor.UpdatedAt.Before(createdAt))
+
+	_, err := database.GetSensorMetadataByName("sensor-1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	got, err := database.GetSensorMetadataByName("sensor-renamed")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tag2"}, []string(got.Tags))
+
+	// updates only apply to stored sensors
+	fresh := &SensorMetadata{Name: "sensor-3"}
+	assert.ErrorIs(t, database.UpdateSensorMetadata(fresh), gorm.ErrRecordNotFound)
+}
+
+func TestMemorySensorMetadataDB_ConcurrentCreate(t *testing.T) {
+	database := NewMemorySensorMetadataDB()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// every name is attempted twice, only one of each may win
+			errs <- database.CreateSensorMetadata(&SensorMetadata{
+				Name:        fmt.Sprintf("sensor-%d", i%50),
+				Description: fmt.Sprintf("attempt %d", i),
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	failed := 0
+	for err := range errs {
+		if err != nil {
+			assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+			failed++
+		}
+	}
+	assert.Equal(t, 50, failed)
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationLockKey is the key of the postgres advisory lock held while migrating,
+// so that only one replica applies migrations at a time.
+const migrationLockKey int64 = 7_281_493_106
+
+// Migration is a single numbered, reversible schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration is a row of the schema_migrations table, one per applied migration.
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"type:varchar(255);not null" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus reports whether a known migration has been applied.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator applies and rolls back the registered migrations.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(conn *gorm.DB) *Migrator {
+	ms := make([]Migration, len(migrations))
+	copy(ms, migrations)
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+
+	return &Migrator{db: conn, migrations: ms}
+}
+
+// Up applies every pending migration in version order.
+func (m *Migrator) Up() error {
+	return m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			err = conn.Transaction(func(tx *gorm.DB) error {
+				if err := mig.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{
+					Version:   mig.Version,
+					Name:      mig.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", mig.Version, mig.Name, err)
+			}
+		}
+
+		// the geography column of migration 6 depends on an extension that may
+		// be installed after the migration ran, so it is looked for on every start
+		if _, ok := m.find(6); ok {
+			if err = conn.Transaction(ensureGeography); err != nil {
+				return fmt.Errorf("adding the PostGIS column failed: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the latest steps applied migrations, newest first.
+func (m *Migrator) Down(steps int) error {
+	return m.withLock(func(conn *gorm.DB) error {
+		var rows []SchemaMigration
+		err := conn.Order("version desc").Limit(steps).Find(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			mig, ok := m.find(row.Version)
+			if !ok {
+				return fmt.Errorf("migration %d (%s) is not known to this build", row.Version, row.Name)
+			}
+
+			err = conn.Transaction(func(tx *gorm.DB) error {
+				if err := mig.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, row.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of migration %d (%s) failed: %w", mig.Version, mig.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Version returns the highest applied migration version, or 0 on an empty schema.
+func (m *Migrator) Version() (int, error) {
+	if err := m.ensureTable(m.db); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := m.db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// Status lists every known migration along with when it was applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied(m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if row, ok := applied[mig.Version]; ok {
+			appliedAt := row.AppliedAt
+			s.AppliedAt = &appliedAt
+		}
+		status = append(status, s)
+	}
+
+	return status, nil
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+func (m *Migrator) ensureTable(conn *gorm.DB) error {
+	return conn.AutoMigrate(&SchemaMigration{})
+}
+
+func (m *Migrator) applied(conn *gorm.DB) (map[int]SchemaMigration, error) {
+	if err := m.ensureTable(conn); err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if err := conn.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
+
+// withLock runs fn on a single pooled connection holding the migration advisory lock.
+func (m *Migrator) withLock(fn func(conn *gorm.DB) error) error {
+	if m.db.Dialector.Name() != "postgres" {
+		return fn(m.db)
+	}
+
+	return m.db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey)
+
+		return fn(conn)
+	})
+}
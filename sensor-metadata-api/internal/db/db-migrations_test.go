@@ -0,0 +1,132 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"sensor-metadata-api/config"
+)
+
+// newTestMigrator returns a migrator over an empty sqlite database.
+func newTestMigrator(t *testing.T) (*Migrator, *gorm.DB) {
+	t.Helper()
+
+	conn, err := Connect(&config.DBConfig{
+		Driver: DriverSQLite,
+		Path:   filepath.Join(t.TempDir(), "sensor-metadata.db"),
+	})
+	require.NoError(t, err)
+
+	return NewMigrator(conn), conn
+}
+
+// pending lists the versions Status reports as not applied.
+func pending(t *testing.T, m *Migrator) []int {
+	t.Helper()
+
+	status, err := m.Status()
+	require.NoError(t, err)
+
+	versions := make([]int, 0)
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			versions = append(versions, s.Version)
+		}
+	}
+	return versions
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	m, conn := newTestMigrator(t)
+	version, err := m.Version()
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	require.NoError(t, m.Up())
+	// applying again is a no-op
+	require.NoError(t, m.Up())
+
+	version, err = m.Version()
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].Version, version)
+	assert.True(t, conn.Migrator().HasTable(&SensorMetadata{}))
+	assert.Empty(t, pending(t, m))
+
+	require.NoError(t, m.Down(len(migrations)))
+	version, err = m.Version()
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	assert.False(t, conn.Migrator().HasTable(&SensorMetadata{}))
+	assert.Len(t, pending(t, m), len(migrations))
+}
+
+func TestMigrator_UpKeepsRows(t *testing.T) {
+	m, conn := newTestMigrator(t)
+	require.NoError(t, m.Up())
+
+	database := NewSQLiteSensorMetadataDB(conn)
+	sensor := &SensorMetadata{Name: "sensor-1", Description: "first", Location: Location{Latitude: 1, Longitude: 2}}
+	require.NoError(t, database.CreateSensorMetadata(sensor))
+
+	// restarting on a migrated database changes nothing
+	require.NoError(t, NewMigrator(conn).Up())
+
+	got, err := database.GetSensorMetadataByName("sensor-1")
+	require.NoError(t, err)
+	assert.Equal(t, sensor.ID, got.ID)
+}
+
+func TestMigrator_DownOne(t *testing.T) {
+	m, _ := newTestMigrator(t)
+	require.NoError(t, m.Up())
+	latest := migrations[len(migrations)-1].Version
+
+	require.NoError(t, m.Down(1))
+	version, err := m.Version()
+	require.NoError(t, err)
+	assert.Equal(t, latest-1, version)
+	assert.Equal(t, []int{latest}, pending(t, m))
+
+	// the next start applies it again
+	require.NoError(t, m.Up())
+	version, err = m.Version()
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+	assert.Empty(t, pending(t, m))
+}
+
+func TestMigrator_Status(t *testing.T) {
+	m, _ := newTestMigrator(t)
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	require.Len(t, status, len(migrations))
+	for i, s := range status {
+		assert.Equal(t, migrations[i].Version, s.Version)
+		assert.Equal(t, migrations[i].Name, s.Name)
+		assert.Nil(t, s.AppliedAt)
+	}
+
+	require.NoError(t, m.Up())
+	status, err = m.Status()
+	require.NoError(t, err)
+	for _, s := range status {
+		assert.NotNil(t, s.AppliedAt, "migration %d", s.Version)
+	}
+}
+
+func TestMigrator_DownUnknown(t *testing.T) {
+	m, conn := newTestMigrator(t)
+	require.NoError(t, m.Up())
+
+	// a build that does not know the latest migration can not roll it back
+	older := &Migrator{db: conn, migrations: m.migrations[:len(m.migrations)-1]}
+	assert.Error(t, older.Down(1))
+
+	version, err := m.Version()
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].Version, version)
+}
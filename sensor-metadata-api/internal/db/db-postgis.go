@@ -0,0 +1,89 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// geographyColumn is the generated PostGIS point of a sensor, added by
+// ensureGeography when the extension can be installed.
+const geographyColumn = "geog"
+
+// pointSQL builds the geography of a (longitude, latitude) pair.
+const pointSQL = "ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography"
+
+// hasGeography reports whether conn has the PostGIS column, in which case
+// spatial queries are pushed down to its GiST indexes.
+func hasGeography(conn *gorm.DB) bool {
+	if conn.Dialector.Name() != DriverPostgres {
+		return false
+	}
+	return conn.Migrator().HasColumn("sensor_metadata", geographyColumn)
+}
+
+// ensureGeography adds the PostGIS column and its indexes to sensor_metadata
+// when the extension is available and the role may install it, and does
+// nothing otherwise. It is safe to run again, which the migrator does after
+// every Up so that PostGIS installed after the first start is picked up.
+func ensureGeography(tx *gorm.DB) error {
+	if tx.Dialector.Name() != DriverPostgres || hasGeography(tx) {
+		return nil
+	}
+
+	var available bool
+	err := tx.Raw("SELECT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'postgis')").
+		Scan(&available).Error
+	if err != nil || !available {
+		return err
+	}
+	// the role may not be allowed to create extensions
+	if err = tx.SavePoint("postgis").Error; err != nil {
+		return err
+	}
+	if err = tx.Exec("CREATE EXTENSION IF NOT EXISTS postgis").Error; err != nil {
+		return tx.RollbackTo("postgis").Error
+	}
+
+	for _, stmt := range []string{
+		`ALTER TABLE sensor_metadata ADD COLUMN IF NOT EXISTS geog geography(Point, 4326)
+			GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography) STORED`,
+		"CREATE INDEX IF NOT EXISTS idx_sensor_metadata_geog ON sensor_metadata USING GIST (geog)",
+		"CREATE INDEX IF NOT EXISTS idx_sensor_metadata_geom ON sensor_metadata USING GIST ((geog::geometry))",
+	} {
+		if err = tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// whereInBoxGeography restricts q to sensors inside box. The box is compared as
+// geometry, so its edges are parallels and meridians as with whereInBox.
+func whereInBoxGeography(q *gorm.DB, box BoundingBox) *gorm.DB {
+	const intersects = "ST_Intersects(geog::geometry, ST_MakeEnvelope(?, ?, ?, ?, 4326))"
+
+	if box.MinLongitude <= box.MaxLongitude {
+		return q.Where(intersects, box.MinLongitude, box.MinLatitude, box.MaxLongitude, box.MaxLatitude)
+	}
+	// split at the antimeridian
+	return q.Where(intersects+" OR "+intersects,
+		box.MinLongitude, box.MinLatitude, 180, box.MaxLatitude,
+		-180, box.MinLatitude, box.MaxLongitude, box.MaxLatitude,
+	)
+}
+
+// whereWithinGeography restricts q to sensors at most radius meters from center,
+// measured on the same sphere as Location.DistanceTo.
+func whereWithinGeography(q *gorm.DB, center Location, radius float64) *gorm.DB {
+	return q.Where("ST_DWithin(geog, "+pointSQL+", ?, false)", center.Longitude, center.Latitude, radius)
+}
+
+// orderByGeographyDistance sorts q nearest first using the KNN operator of the
+// geography index.
+func orderByGeographyDistance(q *gorm.DB, center Location) *gorm.DB {
+	return q.Clauses(clause.OrderBy{Expression: clause.Expr{
+		SQL:                "geog <-> " + pointSQL + ", name",
+		Vars:               []interface{}{center.Longitude, center.Latitude},
+		WithoutParentheses: true,
+	}})
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunPostgres renders postgres statements without connecting to a server.
+func dryRunPostgres(t *testing.T) *gorm.DB {
+	conn, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	return conn
+}
+
+func TestGeographyPushDown(t *testing.T) {
+	conn := dryRunPostgres(t)
+	sql := func(q *gorm.DB) string {
+		var sensors []SensorMetadata
+		return q.Find(&sensors).Statement.SQL.String()
+	}
+
+	box := sql(whereInBoxGeography(conn.Model(&SensorMetadata{}), BoundingBox{
+		MinLatitude: -20, MinLongitude: 170, MaxLatitude: -10, MaxLongitude: -170,
+	}))
+	assert.Contains(t, box, "ST_Intersects(geog::geometry, ST_MakeEnvelope($1, $2, $3, $4, 4326)) OR ST_Intersects(")
+	assert.Contains(t, box, `"sensor_metadata"."deleted_at" IS NULL`)
+
+	within := sql(whereWithinGeography(conn.Model(&SensorMetadata{}), Location{Latitude: 1, Longitude: 2}, 100))
+	assert.Contains(t, within, "ST_DWithin(geog, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3, false)")
+
+	nearest := sql(orderByGeographyDistance(conn.Model(&SensorMetadata{}), Location{Latitude: 1, Longitude: 2}))
+	assert.Contains(t, nearest, "ORDER BY geog <-> ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, name")
+}
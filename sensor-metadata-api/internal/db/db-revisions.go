@@ -0,0 +1,212 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Revision operations
+const (
+	OpCreate   = "create"
+	OpUpdate   = "update"
+	OpDelete   = "delete"
+	OpRestore  = "restore"
+	OpRollback = "rollback"
+	// OpPurge is the last revision of a purged sensor, left in place of its
+	// history so followers of the revisions learn it is gone.
+	OpPurge = "purge"
+)
+
+// SensorRevision is a snapshot of a sensor as it was after a change. Revision is
+// the version of the sensor it captures; Seq orders the revisions of all sensors.
+type SensorRevision struct {
+	Seq       int64          `gorm:"primaryKey;autoIncrement" json:"seq"`
+	SensorID  uuid.UUID      `gorm:"type:uuid;not null" json:"sensor_id"`
+	Name      string         `gorm:"type:varchar(255);not null" json:"name"`
+	Revision  int            `gorm:"not null" json:"revision"`
+	Operation string         `gorm:"type:varchar(16);not null" json:"operation"`
+	Snapshot  SensorMetadata `gorm:"serializer:json;not null" json:"snapshot"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// ChangeToken identifies the state of the whole collection: it changes whenever
+// a sensor is created, changed or removed. Seq is the latest revision and
+// Sensors the number of stored sensors, soft-deleted ones included. Seq only
+// grows, as revisions become visible in seq order and purges record one too.
+type ChangeToken struct {
+	Seq     int64
+	Sensors int64
+}
+
+func (t ChangeToken) String() string {
+	return fmt.Sprintf("%d-%d", t.Seq, t.Sensors)
+}
+
+// FieldChange is one field that differs between two revisions.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// DiffSensorRevisions lists the fields changed between the snapshots of two revisions.
+func DiffSensorRevisions(from, to *SensorRevision) []FieldChange {
+	a, b := &from.Snapshot, &to.Snapshot
+	changes := make([]FieldChange, 0)
+
+	if a.Name != b.Name {
+		changes = append(changes, FieldChange{Field: "name", From: a.Name, To: b.Name})
+	}
+	if a.Description != b.Description {
+		changes = append(changes, FieldChange{Field: "description", From: a.Description, To: b.Description})
+	}
+	if a.Location.Latitude != b.Location.Latitude {
+		changes = append(changes, FieldChange{Field: "location.latitude", From: a.Location.Latitude, To: b.Location.Latitude})
+	}
+	if a.Location.Longitude != b.Location.Longitude {
+		changes = append(changes, FieldChange{Field: "location.longitude", From: a.Location.Longitude, To: b.Location.Longitude})
+	}
+	if !equalTags(a.Tags, b.Tags) {
+		changes = append(changes, FieldChange{Field: "tags", From: a.Tags, To: b.Tags})
+	}
+	if a.DeletedAt.Valid != b.DeletedAt.Valid {
+		changes = append(changes, FieldChange{Field: "deleted", From: a.DeletedAt.Valid, To: b.DeletedAt.Valid})
+	}
+
+	return changes
+}
+
+// applySnapshot copies the user editable fields of a revision onto sensor.
+func applySnapshot(sensor *SensorMetadata, rev *SensorRevision) {
+	sensor.Name = rev.Snapshot.Name
+	sensor.Description = rev.Snapshot.Description
+	sensor.Location = rev.Snapshot.Location
+	sensor.Tags = append(StringArray(nil), rev.Snapshot.Tags...)
+}
+
+func equalTags(a, b StringArray) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// revisionLockKey is the key of the postgres advisory lock held by the
+// transactions that record revisions.
+const revisionLockKey int64 = 7_281_493_107
+
+// writeTransaction runs fn in a transaction that may record revisions. On
+// postgres it first takes the revision lock, held until commit, so revisions
+// become visible in the order of their seq and readers following it, like the
+// event stream, never pass one still being committed. SQLite and the memory
+// backend serialize writes anyway. Taking the lock before any row keeps writers
+// from deadlocking on it.
+func (d *SensorMetadataDBImpl) writeTransaction(fn func(tx *gorm.DB) error) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == DriverPostgres {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", revisionLockKey).Error; err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// tombstone turns sensor into the snapshot of its purge revision: the next
+// version, deleted now unless it already was.
+func tombstone(sensor *SensorMetadata) {
+	now := time.Now()
+	sensor.Version++
+	sensor.UpdatedAt = now
+	if !sensor.DeletedAt.Valid {
+		sensor.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	}
+}
+
+// recordRevision stores the current version of sensor as its revision, within
+// the write transaction tx, see writeTransaction.
+func recordRevision(tx *gorm.DB, sensor *SensorMetadata, op string) error {
+	return tx.Create(&SensorRevision{
+		SensorID:  sensor.ID,
+		Name:      sensor.Name,
+		Revision:  sensor.Version,
+		Operation: op,
+		Snapshot:  *sensor,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+func (d *SensorMetadataDBImpl) GetChangeToken() (ChangeToken, error) {
+	var token ChangeToken
+	err := d.db.Model(&SensorRevision{}).Select("COALESCE(MAX(seq), 0)").Scan(&token.Seq).Error
+	if err != nil {
+		return token, err
+	}
+	err = d.db.Unscoped().Model(&SensorMetadata{}).Count(&token.Sensors).Error
+	return token, err
+}
+
+func (d *SensorMetadataDBImpl) ListSensorRevisions(sensorID uuid.UUID) ([]SensorRevision, error) {
+	revisions := make([]SensorRevision, 0)
+	err := d.db.Where("sensor_id = ?", sensorID).Order("revision").Find(&revisions).Error
+	return revisions, err
+}
+
+func (d *SensorMetadataDBImpl) GetSensorRevision(sensorID uuid.UUID, revision int) (*SensorRevision, error) {
+	var rev SensorRevision
+	err := d.db.Where("sensor_id = ? AND revision = ?", sensorID, revision).First(&rev).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &rev, nil
+}
+
+// ListRevisionsSince returns up to limit revisions of any sensor recorded after
+// seq, in the order they were recorded. Revisions commit in seq order, see
+// writeTransaction, so none with a lower seq shows up later.
+func (d *SensorMetadataDBImpl) ListRevisionsSince(seq int64, limit int) ([]SensorRevision, error) {
+	revisions := make([]SensorRevision, 0)
+	err := d.db.Where("seq > ?", seq).Order("seq").Limit(limit).Find(&revisions).Error
+	return revisions, err
+}
+
+// RollbackSensorMetadata restores the fields of an earlier revision onto a live
+// sensor, recording the result as a new revision. A non-zero version must
+// match the stored one.
+func (d *SensorMetadataDBImpl) RollbackSensorMetadata(sensorID uuid.UUID, revision int, version int) (*SensorMetadata, error) {
+	var sensor SensorMetadata
+	err := d.writeTransaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", sensorID).First(&sensor).Error; err != nil {
+			return err
+		}
+		if version != 0 && version != sensor.Version {
+			return ErrVersionConflict
+		}
+
+		var rev SensorRevision
+		err := tx.Where("sensor_id = ? AND revision = ?", sensorID, revision).First(&rev).Error
+		if err != nil {
+			return err
+		}
+
+		applySnapshot(&sensor, &rev)
+		if err = compareAndSwap(tx, &sensor); err != nil {
+			return err
+		}
+		return recordRevision(tx, &sensor, OpRollback)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &sensor, nil
+}
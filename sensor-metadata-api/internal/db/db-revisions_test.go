@@ -0,0 +1,183 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"sensor-metadata-api/config"
+)
+
+func TestSensorRevisions(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			sensor := &SensorMetadata{
+				Name:        "sensor-1",
+				Description: "first",
+				Location:    Location{Latitude: 1, Longitude: 2},
+				Tags:        []string{"a"},
+			}
+			require.NoError(t, database.CreateSensorMetadata(sensor))
+
+			sensor.Description = "second"
+			sensor.Location.Latitude = 0
+			sensor.Tags = []string{"a", "b"}
+			require.NoError(t, database.UpdateSensorMetadata(sensor))
+
+			require.NoError(t, database.DeleteSensorMetadata("sensor-1", sensor.Version))
+			_, err := database.RestoreSensorMetadata("sensor-1", 0)
+			require.NoError(t, err)
+
+			revisions, err := database.ListSensorRevisions(sensor.ID)
+			require.NoError(t, err)
+			require.Len(t, revisions, 4)
+			for i, op := range []string{OpCreate, OpUpdate, OpDelete, OpRestore} {
+				assert.Equal(t, i+1, revisions[i].Revision)
+				assert.Equal(t, op, revisions[i].Operation)
+				if i > 0 {
+					assert.Greater(t, revisions[i].Seq, revisions[i-1].Seq)
+				}
+			}
+			assert.Equal(t, "second", revisions[1].Snapshot.Description)
+			assert.True(t, revisions[2].Snapshot.DeletedAt.Valid)
+
+			changes := DiffSensorRevisions(&revisions[0], &revisions[1])
+			fields := make([]string, 0, len(changes))
+			for _, c := range changes {
+				fields = append(fields, c.Field)
+			}
+			assert.Equal(t, []string{"description", "location.latitude", "tags"}, fields)
+
+			// rolling back to revision 1 is recorded as revision 5
+			restored, err := database.RollbackSensorMetadata(sensor.ID, 1, 0)
+			require.NoError(t, err)
+			assert.Equal(t, "first", restored.Description)
+			assert.Equal(t, StringArray{"a"}, restored.Tags)
+
+			got, err := database.GetSensorMetadataByName("sensor-1")
+			require.NoError(t, err)
+			assert.Equal(t, Location{Latitude: 1, Longitude: 2}, got.Location)
+
+			rev, err := database.GetSensorRevision(sensor.ID, 5)
+			require.NoError(t, err)
+			assert.Equal(t, OpRollback, rev.Operation)
+			assert.Empty(t, DiffSensorRevisions(&revisions[0], rev))
+
+			_, err = database.GetSensorRevision(sensor.ID, 6)
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			_, err = database.RollbackSensorMetadata(sensor.ID, 6, 0)
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			_, err = database.RollbackSensorMetadata(sensor.ID, 1, restored.Version-1)
+			assert.ErrorIs(t, err, ErrVersionConflict)
+
+			// purge replaces the history with a tombstone
+			require.NoError(t, database.PurgeSensorMetadata("sensor-1"))
+			revisions, err = database.ListSensorRevisions(sensor.ID)
+			require.NoError(t, err)
+			require.Len(t, revisions, 1)
+			assert.Equal(t, OpPurge, revisions[0].Operation)
+			assert.Equal(t, 6, revisions[0].Revision)
+			assert.Greater(t, revisions[0].Seq, rev.Seq)
+			assert.True(t, revisions[0].Snapshot.DeletedAt.Valid)
+		})
+	}
+}
+
+func TestSensorRevisions_Backfill(t *testing.T) {
+	conn, err := Connect(&config.DBConfig{
+		Driver: DriverSQLite,
+		Path:   filepath.Join(t.TempDir(), "sensor-metadata.db"),
+	})
+	require.NoError(t, err)
+
+	// a database created before revisions existed
+	m := NewMigrator(conn)
+	full := m.migrations
+	m.migrations = full[:2]
+	require.NoError(t, m.Up())
+	require.NoError(t, conn.Exec(
+		`INSERT INTO sensor_metadata (id, name, description, latitude, longitude, tags, created_at, updated_at)
+		VALUES ('6f1c1f9e-3b35-4f5e-9a43-0c8f2a5d1e10', 'legacy', 'from before', 1, 2, '["x"]', ?, ?)`,
+		"2023-08-01 00:00:00+00:00", "2023-08-01 00:00:00+00:00",
+	).Error)
+
+	m.migrations = full
+	require.NoError(t, m.Up())
+
+	database := NewSQLiteSensorMetadataDB(conn)
+	sensor, err := database.GetSensorMetadataByName("legacy")
+	require.NoError(t, err)
+
+	revisions, err := database.ListSensorRevisions(sensor.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, OpCreate, revisions[0].Operation)
+	assert.Equal(t, "legacy", revisions[0].Snapshot.Name)
+	assert.Equal(t, StringArray{"x"}, revisions[0].Snapshot.Tags)
+}
+
+func TestChangeToken(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			token := func() ChangeToken {
+				token, err := database.GetChangeToken()
+				require.NoError(t, err)
+				return token
+			}
+
+			seen := map[ChangeToken]bool{token(): true}
+			changed := func(step string) {
+				current := token()
+				assert.False(t, seen[current], "token %s repeats after %s", current, step)
+				seen[current] = true
+			}
+
+			seedSensors(t, database, 2)
+			changed("create")
+			require.NoError(t, database.DeleteSensorMetadata("sensor-00", 0))
+			changed("delete")
+			_, err := database.RestoreSensorMetadata("sensor-00", 0)
+			require.NoError(t, err)
+			changed("restore")
+
+			// purging the sensor holding the latest revision still moves the token on
+			sensor, err := database.GetSensorMetadataByName("sensor-01")
+			require.NoError(t, err)
+			sensor.Description = "updated"
+			require.NoError(t, database.UpdateSensorMetadata(sensor))
+			changed("update")
+			require.NoError(t, database.PurgeSensorMetadata("sensor-01"))
+			changed("purge")
+		})
+	}
+}
+
+func TestListRevisionsSince(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedSensors(t, database, 2)
+			require.NoError(t, database.DeleteSensorMetadata("sensor-00", 0))
+
+			revisions, err := database.ListRevisionsSince(0, 10)
+			require.NoError(t, err)
+			require.Len(t, revisions, 3)
+			for i, rev := range revisions {
+				assert.Equal(t, int64(i+1), rev.Seq)
+			}
+			assert.Equal(t, OpDelete, revisions[2].Operation)
+			assert.Equal(t, "sensor-00", revisions[2].Snapshot.Name)
+			assert.True(t, revisions[2].Snapshot.DeletedAt.Valid)
+
+			revisions, err = database.ListRevisionsSince(1, 1)
+			require.NoError(t, err)
+			require.Len(t, revisions, 1)
+			assert.Equal(t, int64(2), revisions[0].Seq)
+
+			revisions, err = database.ListRevisionsSince(3, 10)
+			require.NoError(t, err)
+			assert.Empty(t, revisions)
+		})
+	}
+}
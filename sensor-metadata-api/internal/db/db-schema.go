@@ -0,0 +1,389 @@
+package db
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// migrations is the ordered list of schema changes. Once released, a migration
+// must never be edited; add a new one with the next version instead.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_sensor_metadata",
+		Up: func(tx *gorm.DB) error {
+			if isSQLite(tx) {
+				return tx.Exec(`CREATE TABLE IF NOT EXISTS sensor_metadata (
+					id TEXT PRIMARY KEY,
+					name VARCHAR(255) NOT NULL UNIQUE,
+					description TEXT NOT NULL UNIQUE,
+					latitude REAL,
+					longitude REAL,
+					tags TEXT,
+					created_at DATETIME,
+					updated_at DATETIME
+				)`).Error
+			}
+
+			// Enable UUID Generator V4
+			if err := tx.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`).Error; err != nil {
+				return err
+			}
+			// adopts a table created by earlier AutoMigrate based releases
+			return tx.AutoMigrate(&sensorMetadataV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&sensorMetadataV1{})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "add_sensor_metadata_deleted_at",
+		Up: func(tx *gorm.DB) error {
+			column := "deleted_at timestamptz"
+			if isSQLite(tx) {
+				column = "deleted_at DATETIME"
+			}
+			if err := tx.Exec("ALTER TABLE sensor_metadata ADD COLUMN " + column).Error; err != nil {
+				return err
+			}
+			return tx.Exec("CREATE INDEX idx_sensor_metadata_deleted_at ON sensor_metadata (deleted_at)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec("DROP INDEX idx_sensor_metadata_deleted_at").Error; err != nil {
+				return err
+			}
+			return tx.Exec("ALTER TABLE sensor_metadata DROP COLUMN deleted_at").Error
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create_sensor_revisions",
+		Up: func(tx *gorm.DB) error {
+			ddl := `CREATE TABLE sensor_revisions (
+				seq BIGSERIAL PRIMARY KEY,
+				sensor_id uuid NOT NULL,
+				name varchar(255) NOT NULL,
+				revision integer NOT NULL,
+				operation varchar(16) NOT NULL,
+				snapshot jsonb NOT NULL,
+				created_at timestamptz NOT NULL,
+				UNIQUE (sensor_id, revision)
+			)`
+			if isSQLite(tx) {
+				ddl = `CREATE TABLE sensor_revisions (
+					seq INTEGER PRIMARY KEY AUTOINCREMENT,
+					sensor_id TEXT NOT NULL,
+					name VARCHAR(255) NOT NULL,
+					revision INTEGER NOT NULL,
+					operation VARCHAR(16) NOT NULL,
+					snapshot TEXT NOT NULL,
+					created_at DATETIME NOT NULL,
+					UNIQUE (sensor_id, revision)
+				)`
+			}
+			if err := tx.Exec(ddl).Error; err != nil {
+				return err
+			}
+
+			// existing sensors start their history with a create revision
+			var sensors []sensorMetadataV2
+			if err := tx.Unscoped().Find(&sensors).Error; err != nil {
+				return err
+			}
+			for _, s := range sensors {
+				err := tx.Create(&sensorRevisionV3{
+					SensorID:  s.ID,
+					Name:      s.Name,
+					Revision:  1,
+					Operation: OpCreate,
+					Snapshot:  s,
+					CreatedAt: s.CreatedAt,
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP TABLE sensor_revisions").Error
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_sensor_metadata_version",
+		Up: func(tx *gorm.DB) error {
+			err := tx.Exec("ALTER TABLE sensor_metadata ADD COLUMN version integer NOT NULL DEFAULT 1").Error
+			if err != nil {
+				return err
+			}
+			// revision numbers become versions, so continue from the latest one
+			return tx.Exec(`UPDATE sensor_metadata SET version = COALESCE(
+				(SELECT MAX(r.revision) FROM sensor_revisions r WHERE r.sensor_id = sensor_metadata.id), 1
+			)`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE sensor_metadata DROP COLUMN version").Error
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_sensor_metadata_location_index",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("CREATE INDEX idx_sensor_metadata_location ON sensor_metadata (latitude, longitude)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP INDEX idx_sensor_metadata_location").Error
+		},
+	},
+	{
+		// Postgres only, and only where PostGIS can be installed; without it the
+		// spatial queries fall back to the latitude/longitude columns. The
+		// migrator retries ensureGeography on every start, so PostGIS installed
+		// later is picked up without rolling back.
+		Version: 6,
+		Name:    "add_sensor_metadata_geography",
+		Up:      ensureGeography,
+		Down: func(tx *gorm.DB) error {
+			if isSQLite(tx) {
+				return nil
+			}
+			// dropping the column drops its indexes; the extension is left installed
+			return tx.Exec("ALTER TABLE sensor_metadata DROP COLUMN IF EXISTS geog").Error
+		},
+	},
+	{
+		// Fails on postgres while two sensors share a name up to case; rename
+		// one of them first.
+		Version: 7,
+		Name:    "add_sensor_metadata_lower_name_and_aliases",
+		Up: func(tx *gorm.DB) error {
+			ddl := `CREATE TABLE sensor_aliases (
+				name varchar(255) PRIMARY KEY,
+				sensor_id uuid NOT NULL,
+				created_at timestamptz NOT NULL
+			)`
+			if isSQLite(tx) {
+				ddl = `CREATE TABLE sensor_aliases (
+					name VARCHAR(255) PRIMARY KEY,
+					sensor_id TEXT NOT NULL,
+					created_at DATETIME NOT NULL
+				)`
+			}
+
+			for _, stmt := range []string{
+				"CREATE UNIQUE INDEX idx_sensor_metadata_name_lower ON sensor_metadata (lower(name))",
+				ddl,
+				"CREATE UNIQUE INDEX idx_sensor_aliases_name_lower ON sensor_aliases (lower(name))",
+				"CREATE INDEX idx_sensor_aliases_sensor_id ON sensor_aliases (sensor_id)",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec("DROP TABLE sensor_aliases").Error; err != nil {
+				return err
+			}
+			return tx.Exec("DROP INDEX idx_sensor_metadata_name_lower").Error
+		},
+	},
+	{
+		Version: 8,
+		Name:    "create_idempotency_keys",
+		Up: func(tx *gorm.DB) error {
+			ddl := `CREATE TABLE idempotency_keys (
+				key varchar(255) PRIMARY KEY,
+				fingerprint varchar(64) NOT NULL,
+				status integer NOT NULL DEFAULT 0,
+				header text,
+				body bytea,
+				created_at timestamptz NOT NULL,
+				expires_at timestamptz NOT NULL
+			)`
+			if isSQLite(tx) {
+				ddl = `CREATE TABLE idempotency_keys (
+					key VARCHAR(255) PRIMARY KEY,
+					fingerprint VARCHAR(64) NOT NULL,
+					status INTEGER NOT NULL DEFAULT 0,
+					header TEXT,
+					body BLOB,
+					created_at DATETIME NOT NULL,
+					expires_at DATETIME NOT NULL
+				)`
+			}
+			if err := tx.Exec(ddl).Error; err != nil {
+				return err
+			}
+			return tx.Exec("CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP TABLE idempotency_keys").Error
+		},
+	},
+	{
+		// Postgres only: SQLite has no index on the elements of a JSON array.
+		Version: 9,
+		Name:    "add_sensor_metadata_tags_index",
+		Up: func(tx *gorm.DB) error {
+			if isSQLite(tx) {
+				return nil
+			}
+			return tx.Exec("CREATE INDEX idx_sensor_metadata_tags ON sensor_metadata USING GIN (tags)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if isSQLite(tx) {
+				return nil
+			}
+			return tx.Exec("DROP INDEX idx_sensor_metadata_tags").Error
+		},
+	},
+	{
+		// Postgres only; the other backends index the sensors in process. The
+		// weights rank the name above the tags above the description.
+		Version: 10,
+		Name:    "add_sensor_metadata_search",
+		Up: func(tx *gorm.DB) error {
+			if isSQLite(tx) {
+				return nil
+			}
+			for _, stmt := range []string{
+				// array_to_string is only stable, which generated columns do not accept
+				`CREATE FUNCTION sensor_metadata_tags_text(tags text[]) RETURNS text
+					LANGUAGE sql IMMUTABLE AS $$ SELECT array_to_string(tags, ' ') $$`,
+				`ALTER TABLE sensor_metadata ADD COLUMN search tsvector GENERATED ALWAYS AS (
+					setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+					setweight(to_tsvector('simple', coalesce(sensor_metadata_tags_text(tags), '')), 'B') ||
+					setweight(to_tsvector('simple', coalesce(description, '')), 'C')
+				) STORED`,
+				"CREATE INDEX idx_sensor_metadata_search ON sensor_metadata USING GIN (search)",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if isSQLite(tx) {
+				return nil
+			}
+			if err := tx.Exec("ALTER TABLE sensor_metadata DROP COLUMN search").Error; err != nil {
+				return err
+			}
+			return tx.Exec("DROP FUNCTION sensor_metadata_tags_text(text[])").Error
+		},
+	},
+	{
+		// Replaces the lower(name) indexes of migration 7: lower() only folds
+		// ASCII on SQLite, so names are compared by canonicalName, computed in
+		// Go and stored along with them. Fails while two names only differ by
+		// the case of non-ASCII letters; rename one of them first.
+		Version: 11,
+		Name:    "add_name_key",
+		Up: func(tx *gorm.DB) error {
+			for _, table := range []string{"sensor_metadata", "sensor_aliases"} {
+				if err := tx.Exec("ALTER TABLE " + table + " ADD COLUMN name_key varchar(255)").Error; err != nil {
+					return err
+				}
+
+				var names []string
+				if err := tx.Table(table).Pluck("name", &names).Error; err != nil {
+					return err
+				}
+				for _, name := range names {
+					err := tx.Exec("UPDATE "+table+" SET name_key = ? WHERE name = ?", canonicalName(name), name).Error
+					if err != nil {
+						return err
+					}
+				}
+
+				if !isSQLite(tx) {
+					if err := tx.Exec("ALTER TABLE " + table + " ALTER COLUMN name_key SET NOT NULL").Error; err != nil {
+						return err
+					}
+				}
+				for _, stmt := range []string{
+					"CREATE UNIQUE INDEX idx_" + table + "_name_key ON " + table + " (name_key)",
+					"DROP INDEX idx_" + table + "_name_lower",
+				} {
+					if err := tx.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, table := range []string{"sensor_metadata", "sensor_aliases"} {
+				for _, stmt := range []string{
+					"CREATE UNIQUE INDEX idx_" + table + "_name_lower ON " + table + " (lower(name))",
+					"DROP INDEX idx_" + table + "_name_key",
+					"ALTER TABLE " + table + " DROP COLUMN name_key",
+				} {
+					if err := tx.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+}
+
+func isSQLite(tx *gorm.DB) bool {
+	return tx.Dialector.Name() == DriverSQLite
+}
+
+// sensorMetadataV1 is the sensor_metadata table as created by migration 1.
+type sensorMetadataV1 struct {
+	ID          uuid.UUID      `gorm:"type:uuid;default:uuid_generate_v4();primary_key"`
+	Name        string         `gorm:"type:varchar(255); not null; unique"`
+	Description string         `gorm:"type:varchar; not null; unique"`
+	Location    Location       `gorm:"embedded"`
+	Tags        pq.StringArray `gorm:"type:text[]"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (sensorMetadataV1) TableName() string {
+	return "sensor_metadata"
+}
+
+// sensorMetadataV2 is a sensor_metadata row as of migration 2, with the JSON
+// encoding of SensorMetadata.
+type sensorMetadataV2 struct {
+	ID          uuid.UUID      `gorm:"type:uuid"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Location    Location       `gorm:"embedded" json:"location"`
+	Tags        StringArray    `json:"tags"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at"`
+}
+
+func (sensorMetadataV2) TableName() string {
+	return "sensor_metadata"
+}
+
+// sensorRevisionV3 is the sensor_revisions table as created by migration 3.
+type sensorRevisionV3 struct {
+	Seq       int64     `gorm:"primaryKey;autoIncrement"`
+	SensorID  uuid.UUID `gorm:"type:uuid"`
+	Name      string
+	Revision  int
+	Operation string
+	Snapshot  sensorMetadataV2 `gorm:"serializer:json"`
+	CreatedAt time.Time
+}
+
+func (sensorRevisionV3) TableName() string {
+	return "sensor_revisions"
+}
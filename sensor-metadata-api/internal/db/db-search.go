@@ -0,0 +1,173 @@
+package db
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"sensor-metadata-api/internal/textsearch"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+
+	// snippetWidth is about the length in characters of highlighted excerpts.
+	snippetWidth = 160
+)
+
+// ErrInvalidSearch is returned for a search without any word to look for.
+var ErrInvalidSearch = errors.New("invalid search")
+
+// SearchOptions is a full-text search over the names, tags and descriptions of
+// the live sensors. Every word of Query must match the start of a word of the
+// sensor.
+type SearchOptions struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a sensor matching a search. Rank orders the results of one
+// search, best first; it is not comparable across searches or backends.
+// Highlights holds HTML escaped excerpts of the name, tags and description
+// fields that matched, with the matching words in <mark> elements.
+type SearchResult struct {
+	SensorMetadata
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// normalize validates opts, fills in defaults and parses the query.
+func (o *SearchOptions) normalize() (textsearch.Query, error) {
+	q := textsearch.ParseQuery(o.Query)
+	if len(q) == 0 || o.Offset < 0 {
+		return nil, ErrInvalidSearch
+	}
+
+	if o.Limit <= 0 {
+		o.Limit = DefaultSearchLimit
+	}
+	if o.Limit > MaxSearchLimit {
+		o.Limit = MaxSearchLimit
+	}
+	return q, nil
+}
+
+// newSearchResult highlights the fields of sensor matching q.
+func newSearchResult(sensor SensorMetadata, rank float64, q textsearch.Query) SearchResult {
+	result := SearchResult{SensorMetadata: sensor, Rank: rank, Highlights: make(map[string]string)}
+	for field, text := range map[string]string{
+		"name":        sensor.Name,
+		"tags":        strings.Join(sensor.Tags, ", "),
+		"description": sensor.Description,
+	} {
+		if h := textsearch.Highlight(text, q, snippetWidth); h != "" {
+			result.Highlights[field] = h
+		}
+	}
+	return result
+}
+
+// indexSensor adds the fields of a sensor to ix, weighted as the search column
+// of postgres: the name above the tags above the description.
+func indexSensor(ix *textsearch.Index, doc int, sensor *SensorMetadata) {
+	ix.Add(doc, sensor.Name, textsearch.WeightA)
+	for _, tag := range sensor.Tags {
+		ix.Add(doc, tag, textsearch.WeightB)
+	}
+	ix.Add(doc, sensor.Description, textsearch.WeightC)
+}
+
+// searchIndex is the inverted index of the live sensors for the backends
+// without full-text search of their own. It is built on the first search and
+// rebuilt on the first search after any sensor changed.
+type searchIndex struct {
+	mu      sync.Mutex
+	token   ChangeToken
+	index   *textsearch.Index
+	sensors []SensorMetadata
+}
+
+// search runs q against the index of the sensors as of token; load returns the
+// live sensors when the index has to be rebuilt.
+func (s *searchIndex) search(q textsearch.Query, opts SearchOptions, token ChangeToken, load func() ([]SensorMetadata, error)) ([]SearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil || s.token != token {
+		sensors, err := load()
+		if err != nil {
+			return nil, err
+		}
+		ix := textsearch.NewIndex()
+		for i := range sensors {
+			indexSensor(ix, i, &sensors[i])
+		}
+		s.token, s.index, s.sensors = token, ix, sensors
+	}
+
+	hits := s.index.Search(q)
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return s.sensors[hits[i].Doc].Name < s.sensors[hits[j].Doc].Name
+	})
+
+	results := make([]SearchResult, 0, opts.Limit)
+	for i := opts.Offset; i < len(hits) && len(results) < opts.Limit; i++ {
+		results = append(results, newSearchResult(*cloneSensor(&s.sensors[hits[i].Doc]), hits[i].Score, q))
+	}
+	return results, nil
+}
+
+// SearchSensorMetadata ranks the sensors matching a full-text search. Postgres
+// uses the search column and its GIN index, SQLite the in-process index.
+func (d *SensorMetadataDBImpl) SearchSensorMetadata(opts SearchOptions) ([]SearchResult, error) {
+	q, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.db.Dialector.Name() != DriverPostgres {
+		token, err := d.GetChangeToken()
+		if err != nil {
+			return nil, err
+		}
+		return d.search.search(q, opts, token, func() ([]SensorMetadata, error) {
+			var sensors []SensorMetadata
+			err := d.db.Find(&sensors).Error
+			return sensors, err
+		})
+	}
+
+	var rows []struct {
+		SensorMetadata `gorm:"embedded"`
+		Rank           float64
+	}
+	err = d.searchQuery(q, opts).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, newSearchResult(row.SensorMetadata, row.Rank, q))
+	}
+	return results, nil
+}
+
+// searchQuery selects the sensors matching q on postgres, best ranked first.
+func (d *SensorMetadataDBImpl) searchQuery(q textsearch.Query, opts SearchOptions) *gorm.DB {
+	tsquery := q.TSQuery()
+	return d.db.Model(&SensorMetadata{}).
+		Select("sensor_metadata.*, ts_rank(search, to_tsquery('simple', ?)) AS rank", tsquery).
+		Where("search @@ to_tsquery('simple', ?)", tsquery).
+		Order("rank DESC, name").
+		Limit(opts.Limit).
+		Offset(opts.Offset)
+}
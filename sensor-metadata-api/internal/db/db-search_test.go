@@ -0,0 +1,73 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchSensorMetadata(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, s := range []SensorMetadata{
+				{Name: "roof-temperature", Description: "Temperature on the roof of the west building", Tags: []string{"outdoor"}},
+				{Name: "cellar-humidity", Description: "Humidity in the cellar, below the roof terrace", Tags: []string{"indoor"}},
+				{Name: "garden-rain", Description: "Rain gauge in the <garden>", Tags: []string{"outdoor", "roofless"}},
+				{Name: "attic-roof", Description: "Old attic sensor"},
+			} {
+				s := s
+				require.NoError(t, database.CreateSensorMetadata(&s))
+			}
+			require.NoError(t, database.DeleteSensorMetadata("attic-roof", 0))
+
+			search := func(opts SearchOptions) []string {
+				results, err := database.SearchSensorMetadata(opts)
+				require.NoError(t, err)
+				names := make([]string, 0, len(results))
+				for _, r := range results {
+					names = append(names, r.Name)
+				}
+				return names
+			}
+
+			// the name weighs more than the tags, the tags more than the description
+			assert.Equal(t, []string{"roof-temperature", "garden-rain", "cellar-humidity"}, search(SearchOptions{Query: "roof"}))
+			assert.Equal(t, []string{"garden-rain"}, search(SearchOptions{Query: "roof", Limit: 1, Offset: 1}))
+			assert.Equal(t, []string{"cellar-humidity"}, search(SearchOptions{Query: "Hum ROOF"}))
+			assert.Empty(t, search(SearchOptions{Query: "roof basement"}))
+
+			results, err := database.SearchSensorMetadata(SearchOptions{Query: "garden"})
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Positive(t, results[0].Rank)
+			assert.Equal(t, map[string]string{
+				"name":        "<mark>garden</mark>-rain",
+				"description": "Rain gauge in the &lt;<mark>garden</mark>&gt;",
+			}, results[0].Highlights)
+
+			// the index follows the changes
+			sensor, err := database.GetSensorMetadataByName("cellar-humidity")
+			require.NoError(t, err)
+			sensor.Description = "Humidity in the cellar"
+			require.NoError(t, database.UpdateSensorMetadata(sensor))
+			assert.Equal(t, []string{"roof-temperature", "garden-rain"}, search(SearchOptions{Query: "roof"}))
+
+			_, err = database.SearchSensorMetadata(SearchOptions{Query: " - "})
+			assert.ErrorIs(t, err, ErrInvalidSearch)
+		})
+	}
+}
+
+func TestSearchPushDown(t *testing.T) {
+	d := &SensorMetadataDBImpl{db: dryRunPostgres(t)}
+	q := (&SearchOptions{Query: "roof sensor-1"})
+	tsquery, err := q.normalize()
+	require.NoError(t, err)
+
+	var rows []SensorMetadata
+	sql := d.searchQuery(tsquery, *q).Find(&rows).Statement.SQL.String()
+	assert.Contains(t, sql, "SELECT sensor_metadata.*, ts_rank(search, to_tsquery('simple', $1)) AS rank")
+	assert.Contains(t, sql, "search @@ to_tsquery('simple', $2)")
+	assert.Contains(t, sql, "ORDER BY rank DESC, name LIMIT $3")
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// SQLiteSensorMetadataDB is the embedded single-file backend. It shares the gorm
+// queries of SensorMetadataDBImpl; StringArray and SensorMetadata.BeforeCreate
+// cover the tags column and primary key default that are native on postgres.
+type SQLiteSensorMetadataDB struct {
+	*SensorMetadataDBImpl
+}
+
+func NewSQLiteSensorMetadataDB(db *gorm.DB) *SQLiteSensorMetadataDB {
+	return &SQLiteSensorMetadataDB{SensorMetadataDBImpl: NewSensorMetadataDB(db)}
+}
+
+// openSQLite opens the database file at path, creating it if needed. WAL mode
+// and a busy timeout let readers and the single writer share the file; LIKE is
+// made case-sensitive to match postgres.
+func openSQLite(path string) (*gorm.DB, error) {
+	dsn := path +
+		"?_pragma=foreign_keys(1)" +
+		"&_pragma=journal_mode(WAL)" +
+		"&_pragma=busy_timeout(5000)" +
+		"&_pragma=case_sensitive_like(1)" +
+		"&_txlock=immediate" +
+		"&_time_format=sqlite"
+
+	return gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true})
+}
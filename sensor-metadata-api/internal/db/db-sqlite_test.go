@@ -0,0 +1,72 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"sensor-metadata-api/config"
+)
+
+// newTestSQLiteDB returns a migrated sqlite backend stored in a temporary file.
+func newTestSQLiteDB(t *testing.T) *SQLiteSensorMetadataDB {
+	t.Helper()
+
+	conn, err := Connect(&config.DBConfig{
+		Driver: DriverSQLite,
+		Path:   filepath.Join(t.TempDir(), "sensor-metadata.db"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, NewMigrator(conn).Up())
+
+	return NewSQLiteSensorMetadataDB(conn)
+}
+
+func TestSQLiteSensorMetadataDB_CreateAndGet(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	sensor := &SensorMetadata{
+		Name:        "sensor-1",
+		Description: "first",
+		Location:    Location{Latitude: 40.0, Longitude: -80.0},
+		Tags:        []string{"tag1", "floor=3"},
+	}
+	require.NoError(t, database.CreateSensorMetadata(sensor))
+	assert.NotEqual(t, uuid.Nil, sensor.ID)
+
+	got, err := database.GetSensorMetadataByName("sensor-1")
+	require.NoError(t, err)
+	assert.Equal(t, sensor.ID, got.ID)
+	assert.Equal(t, sensor.Location, got.Location)
+	assert.Equal(t, StringArray{"tag1", "floor=3"}, got.Tags)
+
+	// tags are kept as a JSON array
+	var raw string
+	require.NoError(t, database.db.Raw("SELECT tags FROM sensor_metadata WHERE name = ?", "sensor-1").Scan(&raw).Error)
+	assert.JSONEq(t, `["tag1", "floor=3"]`, raw)
+
+	_, err = database.GetSensorMetadataByName("missing")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	err = database.CreateSensorMetadata(&SensorMetadata{Name: "sensor-1", Description: "second"})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+}
+
+func TestSQLiteSensorMetadataDB_Update(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	sensor := &SensorMetadata{Name: "sensor-1", Description: "first", Tags: []string{"tag1"}}
+	require.NoError(t, database.CreateSensorMetadata(sensor))
+
+	sensor.Tags = nil
+	sensor.Location = Location{Latitude: 1.5, Longitude: 2.5}
+	require.NoError(t, database.UpdateSensorMetadata(sensor))
+
+	got, err := database.GetSensorMetadataByName("sensor-1")
+	require.NoError(t, err)
+	assert.Empty(t, got.Tags)
+	assert.Equal(t, sensor.Location, got.Location)
+}
@@ -0,0 +1,212 @@
+package db
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sensor-metadata-api/internal/geohash"
+)
+
+// Fields stats can be grouped by.
+const (
+	GroupByTag     = "tag"
+	GroupByCreated = "created"
+	GroupByUpdated = "updated"
+	GroupByGeohash = "geohash"
+)
+
+// Time buckets of the created and updated groupings. Weeks start on Monday.
+const (
+	IntervalHour  = "hour"
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+const DefaultGeohashPrecision = 4
+
+var ErrInvalidStats = errors.New("invalid stats query")
+
+// StatsOptions aggregates the sensors matching the filters of ListOptions; its
+// paging and sorting are ignored. GroupBy optionally splits the count, by
+// Interval for the time groupings and by geohash cells of Precision characters.
+type StatsOptions struct {
+	ListOptions
+
+	GroupBy   string
+	Interval  string
+	Precision int
+}
+
+// SensorStats sums up a set of sensors. The time ranges are nil when no sensor
+// matched.
+type SensorStats struct {
+	Count int64 `json:"count"`
+	// Deleted counts the soft-deleted sensors among Count.
+	Deleted    int64        `json:"deleted"`
+	CreatedMin *time.Time   `json:"created_min,omitempty"`
+	CreatedMax *time.Time   `json:"created_max,omitempty"`
+	UpdatedMin *time.Time   `json:"updated_min,omitempty"`
+	UpdatedMax *time.Time   `json:"updated_max,omitempty"`
+	Groups     []StatsGroup `json:"groups,omitempty"`
+}
+
+// StatsGroup counts the sensors sharing a key: a tag, the RFC 3339 start of a
+// time bucket or a geohash. A sensor counts once for each of its tags.
+type StatsGroup struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// normalize validates opts and fills in defaults.
+func (o *StatsOptions) normalize() error {
+	switch o.GroupBy {
+	case "", GroupByTag:
+	case GroupByCreated, GroupByUpdated:
+		if o.Interval == "" {
+			o.Interval = IntervalDay
+		}
+		switch o.Interval {
+		case IntervalHour, IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+		default:
+			return ErrInvalidStats
+		}
+	case GroupByGeohash:
+		if o.Precision == 0 {
+			o.Precision = DefaultGeohashPrecision
+		}
+		if o.Precision < 1 || o.Precision > geohash.MaxPrecision {
+			return ErrInvalidStats
+		}
+	default:
+		return ErrInvalidStats
+	}
+	return nil
+}
+
+// statsAggregator sums up sensors one at a time, so each backend only has to
+// feed it the sensors matching the filters.
+type statsAggregator struct {
+	opts   *StatsOptions
+	stats  SensorStats
+	groups map[string]int64
+}
+
+func newStatsAggregator(opts *StatsOptions) (*statsAggregator, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+	return &statsAggregator{opts: opts, groups: make(map[string]int64)}, nil
+}
+
+func (a *statsAggregator) add(sensor *SensorMetadata) {
+	s := &a.stats
+	s.Count++
+	if sensor.DeletedAt.Valid {
+		s.Deleted++
+	}
+	s.CreatedMin, s.CreatedMax = widen(s.CreatedMin, s.CreatedMax, sensor.CreatedAt)
+	s.UpdatedMin, s.UpdatedMax = widen(s.UpdatedMin, s.UpdatedMax, sensor.UpdatedAt)
+
+	switch a.opts.GroupBy {
+	case GroupByTag:
+		for _, tag := range sensor.Tags {
+			a.groups[tag]++
+		}
+	case GroupByCreated:
+		a.groups[bucket(sensor.CreatedAt, a.opts.Interval)]++
+	case GroupByUpdated:
+		a.groups[bucket(sensor.UpdatedAt, a.opts.Interval)]++
+	case GroupByGeohash:
+		a.groups[geohash.Encode(sensor.Location.Latitude, sensor.Location.Longitude, a.opts.Precision)]++
+	}
+}
+
+// result returns the stats, with time buckets in chronological order and other
+// groups by decreasing count.
+func (a *statsAggregator) result() *SensorStats {
+	if a.opts.GroupBy == "" {
+		return &a.stats
+	}
+
+	groups := make([]StatsGroup, 0, len(a.groups))
+	for key, count := range a.groups {
+		groups = append(groups, StatsGroup{Key: key, Count: count})
+	}
+	chronological := a.opts.GroupBy == GroupByCreated || a.opts.GroupBy == GroupByUpdated
+	sort.Slice(groups, func(i, j int) bool {
+		if !chronological && groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Key < groups[j].Key
+	})
+	a.stats.Groups = groups
+	return &a.stats
+}
+
+// widen extends the range [min, max] to t.
+func widen(min, max *time.Time, t time.Time) (*time.Time, *time.Time) {
+	if min == nil || t.Before(*min) {
+		min = &t
+	}
+	if max == nil || t.After(*max) {
+		max = &t
+	}
+	return min, max
+}
+
+// bucket returns the RFC 3339 start, in UTC, of the interval holding t.
+func bucket(t time.Time, interval string) string {
+	t = t.UTC()
+	switch interval {
+	case IntervalHour:
+		t = t.Truncate(time.Hour)
+	case IntervalDay:
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case IntervalWeek:
+		// days since Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		t = time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+	case IntervalMonth:
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case IntervalYear:
+		t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// statsBatchSize is the number of sensors read at once to compute stats.
+const statsBatchSize = 1000
+
+// GetSensorStats sums up the sensors matching the list filters of opts. They
+// are read in batches and aggregated in process, the same way on every
+// database.
+func (d *SensorMetadataDBImpl) GetSensorStats(opts StatsOptions) (*SensorStats, error) {
+	agg, err := newStatsAggregator(&opts)
+	if err != nil {
+		return nil, err
+	}
+
+	q := d.db.Model(&SensorMetadata{})
+	if opts.IncludeDeleted {
+		q = q.Unscoped()
+	}
+	q = d.applyListFilters(q, &opts.ListOptions)
+
+	var batch []SensorMetadata
+	err = q.FindInBatches(&batch, statsBatchSize, func(*gorm.DB, int) error {
+		for i := range batch {
+			agg.add(&batch[i])
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return agg.result(), nil
+}
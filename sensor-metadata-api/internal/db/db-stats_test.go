@@ -0,0 +1,67 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSensorStats(t *testing.T) {
+	for name, database := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			start := seedSensors(t, database, 7)
+			require.NoError(t, database.DeleteSensorMetadata("sensor-06", 0))
+
+			stats, err := database.GetSensorStats(StatsOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), stats.Count)
+			assert.Zero(t, stats.Deleted)
+			assert.True(t, start.Add(2*time.Hour).Equal(*stats.CreatedMin), stats.CreatedMin)
+			assert.True(t, start.Add(7*time.Hour).Equal(*stats.CreatedMax), stats.CreatedMax)
+			assert.Nil(t, stats.Groups)
+
+			stats, err = database.GetSensorStats(StatsOptions{GroupBy: GroupByTag, ListOptions: ListOptions{IncludeDeleted: true}})
+			require.NoError(t, err)
+			assert.Equal(t, int64(7), stats.Count)
+			assert.Equal(t, int64(1), stats.Deleted)
+			assert.Equal(t, []StatsGroup{{Key: "all", Count: 7}, {Key: "even", Count: 4}}, stats.Groups)
+
+			// filters apply
+			stats, err = database.GetSensorStats(StatsOptions{GroupBy: GroupByTag, ListOptions: ListOptions{Tags: []string{"even"}}})
+			require.NoError(t, err)
+			assert.Equal(t, []StatsGroup{{Key: "all", Count: 3}, {Key: "even", Count: 3}}, stats.Groups)
+
+			stats, err = database.GetSensorStats(StatsOptions{GroupBy: GroupByUpdated, Interval: IntervalHour, ListOptions: ListOptions{NamePrefix: "sensor-0"}})
+			require.NoError(t, err)
+			require.Len(t, stats.Groups, 6)
+			assert.Equal(t, StatsGroup{Key: "2023-08-01T00:00:00Z", Count: 1}, stats.Groups[0])
+			assert.Equal(t, StatsGroup{Key: "2023-08-01T05:00:00Z", Count: 1}, stats.Groups[5])
+
+			// 2023-08-01 is a Tuesday
+			stats, err = database.GetSensorStats(StatsOptions{GroupBy: GroupByCreated, Interval: IntervalWeek})
+			require.NoError(t, err)
+			assert.Equal(t, []StatsGroup{{Key: "2023-07-31T00:00:00Z", Count: 6}}, stats.Groups)
+
+			stats, err = database.GetSensorStats(StatsOptions{GroupBy: GroupByGeohash, Precision: 1})
+			require.NoError(t, err)
+			assert.Equal(t, []StatsGroup{{Key: "e", Count: 5}, {Key: "s", Count: 1}}, stats.Groups)
+
+			for _, opts := range []StatsOptions{
+				{GroupBy: "name"},
+				{GroupBy: GroupByCreated, Interval: "fortnight"},
+				{GroupBy: GroupByGeohash, Precision: 13},
+			} {
+				_, err = database.GetSensorStats(opts)
+				assert.ErrorIs(t, err, ErrInvalidStats)
+			}
+		})
+	}
+}
+
+func TestGetSensorStats_Empty(t *testing.T) {
+	stats, err := NewMemorySensorMetadataDB().GetSensorStats(StatsOptions{GroupBy: GroupByTag})
+	require.NoError(t, err)
+	assert.Equal(t, &SensorStats{Groups: []StatsGroup{}}, stats)
+}
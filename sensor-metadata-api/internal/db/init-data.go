@@ -1,11 +1,14 @@
 package db
 
 import (
+	"errors"
 	"fmt"
+	"gorm.io/gorm"
 	"time"
 )
 
-func initData(conn *SensorMetadataDBImpl) error {
+// initData seeds a few sample sensors, skipping any that already exist.
+func initData(conn SensorMetadataDB) error {
 	var sensors = []SensorMetadata{
 		{
 			Name:        "proximity",
@@ -42,13 +45,19 @@ func initData(conn *SensorMetadataDBImpl) error {
 		},
 	}
 
-	err := conn.db.Create(&sensors).Error
-	if err != nil {
-		return err
-	}
+	for i := range sensors {
+		_, err := conn.GetSensorMetadataByName(sensors[i].Name)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
 
-	for _, sensor := range sensors {
-		fmt.Println(sensor.ID)
+		if err = conn.CreateSensorMetadata(&sensors[i]); err != nil {
+			return err
+		}
+		fmt.Println(sensors[i].ID)
 	}
 
 	return nil
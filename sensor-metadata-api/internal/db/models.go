@@ -1,8 +1,15 @@
 package db
 
 import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 	"time"
 )
 
@@ -10,11 +17,25 @@ import (
 type SensorMetadata struct {
 	ID          uuid.UUID      `gorm:"type:uuid;default:uuid_generate_v4();primary_key"`
 	Name        string         `gorm:"type:varchar(255); not null; unique"  json:"name"`
+	NameKey     string         `gorm:"type:varchar(255)" json:"-"`
 	Description string         `gorm:"type:varchar; not null; unique"  json:"description"`
 	Location    Location       `gorm:"embedded" json:"location"`
-	Tags        pq.StringArray `gorm:"type:text[]" json:"tags"`
+	Tags        StringArray    `json:"tags"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at" swaggertype:"string"`
+	// Version starts at 1 and is incremented by every change, see ErrVersionConflict.
+	Version int `gorm:"not null;default:1" json:"version"`
+}
+
+// BeforeCreate assigns the primary key on the client, for databases without
+// uuid_generate_v4(), and the key the name is looked up by.
+func (s *SensorMetadata) BeforeCreate(*gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	s.NameKey = canonicalName(s.Name)
+	return nil
 }
 
 // Location represents the GPS position
@@ -22,3 +43,93 @@ type Location struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 }
+
+// StringArray is a list of strings stored as a native text[] on postgres and as
+// a JSON array on databases without array types.
+type StringArray []string
+
+func (StringArray) GormDataType() string {
+	return "text"
+}
+
+func (StringArray) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	if db.Dialector.Name() == DriverPostgres {
+		return "text[]"
+	}
+	return "text"
+}
+
+func (a StringArray) GormValue(_ context.Context, db *gorm.DB) clause.Expr {
+	if db.Dialector.Name() == DriverPostgres {
+		return clause.Expr{SQL: "?", Vars: []interface{}{pq.StringArray(a)}}
+	}
+
+	value, err := a.jsonValue()
+	if err != nil {
+		_ = db.AddError(err)
+	}
+	return clause.Expr{SQL: "?", Vars: []interface{}{value}}
+}
+
+// Value encodes the array as a postgres array literal, for use in raw postgres queries.
+func (a StringArray) Value() (driver.Value, error) {
+	return pq.StringArray(a).Value()
+}
+
+// Scan accepts both postgres array literals and JSON arrays.
+func (a *StringArray) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into StringArray", src)
+	}
+
+	if len(raw) > 0 && raw[0] == '[' {
+		return json.Unmarshal(raw, (*[]string)(a))
+	}
+
+	var arr pq.StringArray
+	if err := arr.Scan(raw); err != nil {
+		return err
+	}
+	*a = StringArray(arr)
+	return nil
+}
+
+func (a StringArray) jsonValue() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal([]string(a))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Contains reports whether tag is one of the tags.
+func (a StringArray) Contains(tag string) bool {
+	for _, t := range a {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsAny reports whether at least one of tags is among the tags.
+func (a StringArray) ContainsAny(tags []string) bool {
+	for _, tag := range tags {
+		if a.Contains(tag) {
+			return true
+		}
+	}
+	return false
+}
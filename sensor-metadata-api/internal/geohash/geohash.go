@@ -0,0 +1,94 @@
+// Package geohash encodes positions as geohashes: base 32 strings naming cells
+// of a grid, where each added character divides a cell in 32 and a cell
+// contains every cell whose hash it prefixes.
+package geohash
+
+import (
+	"errors"
+	"strings"
+)
+
+// MaxPrecision is the longest hash encoded, cells of a few centimeters.
+const MaxPrecision = 12
+
+const alphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+var ErrInvalidHash = errors.New("invalid geohash")
+
+// Box is the cell of a geohash, edges included.
+type Box struct {
+	MinLatitude, MinLongitude float64
+	MaxLatitude, MaxLongitude float64
+}
+
+// Center is the middle of the cell.
+func (b Box) Center() (latitude, longitude float64) {
+	return (b.MinLatitude + b.MaxLatitude) / 2, (b.MinLongitude + b.MaxLongitude) / 2
+}
+
+// Encode returns the hash of the cell of the given precision, between 1 and
+// MaxPrecision characters, holding a position.
+func Encode(latitude, longitude float64, precision int) string {
+	if precision < 1 {
+		precision = 1
+	}
+	if precision > MaxPrecision {
+		precision = MaxPrecision
+	}
+
+	lat := [2]float64{-90, 90}
+	lon := [2]float64{-180, 180}
+	hash := make([]byte, 0, precision)
+	// bits alternate between longitude and latitude, longitude first
+	even := true
+	for len(hash) < precision {
+		idx := 0
+		for bit := 0; bit < 5; bit++ {
+			r, v := &lat, latitude
+			if even {
+				r, v = &lon, longitude
+			}
+			mid := (r[0] + r[1]) / 2
+			idx <<= 1
+			if v >= mid {
+				idx |= 1
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+		hash = append(hash, alphabet[idx])
+	}
+	return string(hash)
+}
+
+// Decode returns the cell of a hash, read without regard to case.
+func Decode(hash string) (Box, error) {
+	if hash == "" || len(hash) > MaxPrecision {
+		return Box{}, ErrInvalidHash
+	}
+
+	box := Box{MinLatitude: -90, MinLongitude: -180, MaxLatitude: 90, MaxLongitude: 180}
+	even := true
+	for _, c := range strings.ToLower(hash) {
+		idx := strings.IndexRune(alphabet, c)
+		if idx < 0 {
+			return Box{}, ErrInvalidHash
+		}
+		for bit := 4; bit >= 0; bit-- {
+			min, max := &box.MinLatitude, &box.MaxLatitude
+			if even {
+				min, max = &box.MinLongitude, &box.MaxLongitude
+			}
+			mid := (*min + *max) / 2
+			if idx>>bit&1 == 1 {
+				*min = mid
+			} else {
+				*max = mid
+			}
+			even = !even
+		}
+	}
+	return box, nil
+}
@@ -0,0 +1,36 @@
+package geohash
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	// the examples of the original geohash.org announcement and Wikipedia
+	assert.Equal(t, "ezs42", Encode(42.6, -5.6, 5))
+	assert.Equal(t, "u4pruydqqvj", Encode(57.64911, 10.40744, 11))
+	assert.Equal(t, "dr4e", Encode(39.9518, -75.16845, 4))
+
+	assert.Equal(t, "d", Encode(39.9518, -75.16845, 0))
+	assert.Len(t, Encode(39.9518, -75.16845, 20), MaxPrecision)
+	assert.Equal(t, "zzzz", Encode(90, 180, 4))
+	assert.Equal(t, "0000", Encode(-90, -180, 4))
+}
+
+func TestDecode(t *testing.T) {
+	box, err := Decode("EZS42")
+	require.NoError(t, err)
+	assert.InDelta(t, 42.583, box.MinLatitude, 0.001)
+	assert.InDelta(t, 42.627, box.MaxLatitude, 0.001)
+	assert.InDelta(t, -5.625, box.MinLongitude, 0.001)
+	assert.InDelta(t, -5.581, box.MaxLongitude, 0.001)
+
+	lat, lon := box.Center()
+	assert.Equal(t, "ezs42", Encode(lat, lon, 5))
+
+	for _, hash := range []string{"", "ezs4a", "0123456789bcd"} {
+		_, err = Decode(hash)
+		assert.ErrorIs(t, err, ErrInvalidHash, hash)
+	}
+}
@@ -0,0 +1,127 @@
+// Package geojson renders sensors as GeoJSON (RFC 7946) features and reads
+// them back.
+package geojson
+
+import (
+	"errors"
+	"sensor-metadata-api/internal/db"
+	"time"
+)
+
+// MediaType is the registered media type of GeoJSON documents.
+const MediaType = "application/geo+json"
+
+const (
+	TypeFeature           = "Feature"
+	TypeFeatureCollection = "FeatureCollection"
+	TypePoint             = "Point"
+)
+
+var ErrInvalidFeature = errors.New("body must be a GeoJSON Feature with a Point geometry")
+
+// Point is a GeoJSON Point geometry. Coordinates are longitude, latitude and an
+// optional altitude, which sensors do not store.
+type Point struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// Properties are the sensor fields carried next to the geometry.
+type Properties struct {
+	Name           string     `json:"name"`
+	Description    string     `json:"description"`
+	Tags           []string   `json:"tags"`
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	Version        int        `json:"version,omitempty"`
+	DistanceMeters *float64   `json:"distance_meters,omitempty"`
+}
+
+// Feature is one sensor.
+type Feature struct {
+	Type       string     `json:"type"`
+	ID         string     `json:"id,omitempty"`
+	Geometry   *Point     `json:"geometry"`
+	Properties Properties `json:"properties"`
+}
+
+// FeatureCollection is a list of sensors. NextCursor and Links are foreign
+// members carrying the pagination of list responses.
+type FeatureCollection struct {
+	Type       string            `json:"type"`
+	Features   []Feature         `json:"features"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	Links      map[string]string `json:"links,omitempty"`
+}
+
+// FromSensor renders a sensor as a Feature.
+func FromSensor(sensor *db.SensorMetadata) Feature {
+	tags := []string(sensor.Tags)
+	if tags == nil {
+		tags = []string{}
+	}
+
+	f := Feature{
+		Type: TypeFeature,
+		ID:   sensor.ID.String(),
+		Geometry: &Point{
+			Type:        TypePoint,
+			Coordinates: []float64{sensor.Location.Longitude, sensor.Location.Latitude},
+		},
+		Properties: Properties{
+			Name:        sensor.Name,
+			Description: sensor.Description,
+			Tags:        tags,
+			CreatedAt:   &sensor.CreatedAt,
+			UpdatedAt:   &sensor.UpdatedAt,
+			Version:     sensor.Version,
+		},
+	}
+	if sensor.DeletedAt.Valid {
+		f.Properties.DeletedAt = &sensor.DeletedAt.Time
+	}
+	return f
+}
+
+// FromSensors renders sensors as a FeatureCollection.
+func FromSensors(sensors []db.SensorMetadata) FeatureCollection {
+	fc := FeatureCollection{Type: TypeFeatureCollection, Features: make([]Feature, 0, len(sensors))}
+	for i := range sensors {
+		fc.Features = append(fc.Features, FromSensor(&sensors[i]))
+	}
+	return fc
+}
+
+// FromDistances renders the result of a distance search as a FeatureCollection,
+// with the distance of each sensor in its properties.
+func FromDistances(results []db.SensorDistance) FeatureCollection {
+	fc := FeatureCollection{Type: TypeFeatureCollection, Features: make([]Feature, 0, len(results))}
+	for i := range results {
+		f := FromSensor(&results[i].SensorMetadata)
+		f.Properties.DistanceMeters = &results[i].DistanceMeters
+		fc.Features = append(fc.Features, f)
+	}
+	return fc
+}
+
+// Sensor reads the sensor described by f. Server managed properties such as the
+// id, timestamps and version are ignored.
+func (f *Feature) Sensor() (*db.SensorMetadata, error) {
+	if f.Type != TypeFeature || f.Geometry == nil || f.Geometry.Type != TypePoint {
+		return nil, ErrInvalidFeature
+	}
+	if n := len(f.Geometry.Coordinates); n != 2 && n != 3 {
+		return nil, ErrInvalidFeature
+	}
+
+	return &db.SensorMetadata{
+		Name:        f.Properties.Name,
+		Description: f.Properties.Description,
+		Location: db.Location{
+			Latitude:  f.Geometry.Coordinates[1],
+			Longitude: f.Geometry.Coordinates[0],
+		},
+		Tags: f.Properties.Tags,
+	}, nil
+}
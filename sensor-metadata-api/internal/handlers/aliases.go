@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"net/http"
+	"net/url"
+	"sensor-metadata-api/internal/db"
+	"strings"
+	"time"
+)
+
+// aliasRedirect is returned in place of a sensor looked up by one of its former
+// names. ErrorHandler answers it with a redirect to the current name.
+type aliasRedirect struct {
+	Location string
+}
+
+func (r *aliasRedirect) Error() string {
+	return "sensor metadata moved to " + r.Location
+}
+
+// status is 301 for reads; other methods get 308 so clients repeat them with
+// the same method and body.
+func (r *aliasRedirect) status(method string) int {
+	if method == http.MethodGet || method == http.MethodHead {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusPermanentRedirect
+}
+
+// findSensor loads the sensor addressed by the :uuid or the :name path
+// parameter. unscoped also finds a soft-deleted sensor.
+func findSensor(c *fiber.Ctx, database db.SensorMetadataDB, unscoped bool) (*db.SensorMetadata, error) {
+	if param := c.Params("uuid"); param != "" {
+		id, err := uuid.Parse(param)
+		if err != nil {
+			return nil, sensorLookupError(gorm.ErrRecordNotFound)
+		}
+
+		var sensor *db.SensorMetadata
+		if unscoped {
+			sensor, err = database.GetSensorMetadataByIDUnscoped(id)
+		} else {
+			sensor, err = database.GetSensorMetadataByID(id)
+		}
+		if err != nil {
+			return nil, sensorLookupError(err)
+		}
+		return sensor, nil
+	}
+
+	name := c.Params("name")
+	var sensor *db.SensorMetadata
+	var err error
+	if unscoped {
+		sensor, err = database.GetSensorMetadataByNameUnscoped(name)
+	} else {
+		sensor, err = database.GetSensorMetadataByName(name)
+	}
+	if err == gorm.ErrRecordNotFound {
+		return nil, resolveAlias(c, database, name, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sensor, nil
+}
+
+// resolveAlias redirects to the sensor name is a former name of. Otherwise the
+// sensor is reported as not found, with notFound.
+func resolveAlias(c *fiber.Ctx, database db.SensorMetadataDB, name string, notFound error) error {
+	sensor, err := database.ResolveSensorAlias(name)
+	if err == gorm.ErrRecordNotFound {
+		return sensorLookupError(notFound)
+	}
+	if err != nil {
+		return err
+	}
+	return &aliasRedirect{Location: aliasLocation(c, sensor.Name)}
+}
+
+// aliasLocation is the request URL with the :name segment replaced by name.
+// The path is unescaped by the router, so every segment is escaped again.
+func aliasLocation(c *fiber.Ctx, name string) string {
+	route := strings.Split(c.Route().Path, "/")
+	path := strings.Split(c.Path(), "/")
+	for i := range path {
+		path[i] = url.PathEscape(path[i])
+	}
+	for i, segment := range route {
+		// keeps the suffix of routes like /:name.geojson
+		if strings.HasPrefix(segment, ":name") && i < len(path) {
+			path[i] = url.PathEscape(name) + strings.TrimPrefix(segment, ":name")
+		}
+	}
+
+	location := strings.Join(path, "/")
+	if query := c.Request().URI().QueryString(); len(query) > 0 {
+		location += "?" + string(query)
+	}
+	return location
+}
+
+type renameRequest struct {
+	Name string `json:"name"`
+}
+
+// RenameSensorMetadataHandler godoc
+// @Summary      Rename a sensor
+// @Description  Give a sensor a new name. The old name stays an alias that redirects to the sensor, and that no other sensor can take, until it is released.
+// @Tags         update
+// @Accept       json
+// @Produce      json
+// @Param        name   path     string   true    "Sensor Name"
+// @Param        rename   body     handlers.renameRequest   true    "The new name"
+// @Param        If-Match   header     string   false    "ETag of the version being renamed"
+// @Success      200  {object}  db.SensorMetadata
+// @Header       200  {string}   ETag  "New version of the sensor"
+// @Failure      400  {object}  handlers.Problem
+// @Failure      404  {object}  handlers.Problem
+// @Failure      409  {object}  handlers.Problem
+// @Failure      412  {object}  handlers.Problem
+// @Failure      428  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/{name}/rename [post]
+func RenameSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sensor, err := findSensor(c, database, false)
+		if err != nil {
+			return err
+		}
+
+		var req renameRequest
+		if err := c.BodyParser(&req); err != nil {
+			return invalidJSON()
+		}
+		if req.Name == "" {
+			return validationFailed([]FieldError{{Field: "name", Message: "is required"}})
+		}
+		if err := validateSensorUpdate(&db.SensorMetadata{Name: req.Name}, false); err != nil {
+			return err
+		}
+
+		if !ifMatch(c, sensor) {
+			return db.ErrVersionConflict
+		}
+
+		sensor.Name = req.Name
+		sensor.UpdatedAt = time.Now()
+		if err = database.UpdateSensorMetadata(sensor); err != nil {
+			return err
+		}
+
+		c.Set(fiber.HeaderETag, sensorETag(sensor))
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": sensor,
+		})
+	}
+}
+
+// ListSensorAliasesHandler godoc
+// @Summary      List the aliases of a sensor
+// @Description  List the former names that still redirect to a sensor, oldest first
+// @Tags         get
+// @Accept       json
+// @Produce      json
+// @Param        name   path     string   true    "Sensor Name"
+// @Success      200  {array}   db.SensorAlias
+// @Failure      404  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/{name}/aliases [get]
+func ListSensorAliasesHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sensor, err := findSensor(c, database, true)
+		if err != nil {
+			return err
+		}
+
+		aliases, err := database.ListSensorAliases(sensor.ID)
+		if err != nil {
+			return err
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": aliases,
+		})
+	}
+}
+
+// ReleaseSensorAliasHandler godoc
+// @Summary      Release an alias of a sensor
+// @Description  Stop redirecting a former name to the sensor, so another sensor can take it. Aliases are not versioned, so If-Match is neither checked nor required.
+// @Tags         delete
+// @Accept       json
+// @Produce      json
+// @Param        name   path     string   true    "Sensor Name"
+// @Param        alias  path     string   true    "Former name of the sensor"
+// @Success      200  {object}  interface{}
+// @Failure      404  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/{name}/aliases/{alias} [delete]
+func ReleaseSensorAliasHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sensor, err := findSensor(c, database, true)
+		if err != nil {
+			return err
+		}
+
+		if err = database.ReleaseSensorAlias(sensor.ID, c.Params("alias")); err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return NewProblem(http.StatusNotFound, CodeAliasNotFound, "the sensor has no such alias")
+			}
+			return err
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": map[string]string{"message": "successfully released sensor alias"},
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"strings"
+	"testing"
+)
+
+func TestSensorAliasHandlers(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	sensor := &db.SensorMetadata{Name: "Roof-1", Location: db.Location{Latitude: 40.0, Longitude: -80.0}}
+	require.NoError(t, database.CreateSensorMetadata(sensor))
+	require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{Name: "cellar-1", Description: "cellar"}))
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata/id/:uuid", GetSensorMetadataHandler(database))
+	app.Put("/sensor-metadata/id/:uuid", UpdateSensorMetadataHandler(database))
+	app.Get("/sensor-metadata/:name.geojson", GetSensorMetadataHandler(database))
+	app.Get("/sensor-metadata/:name", GetSensorMetadataHandler(database))
+	app.Put("/sensor-metadata/:name", UpdateSensorMetadataHandler(database))
+	app.Post("/sensor-metadata/:name/rename", RenameSensorMetadataHandler(database))
+	app.Get("/sensor-metadata/:name/aliases", ListSensorAliasesHandler(database))
+	app.Delete("/sensor-metadata/:name/aliases/:alias", ReleaseSensorAliasHandler(database))
+
+	do := func(method, url, body string, header ...string) *http.Response {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Case_Insensitive_And_By_ID", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/sensor-metadata/roof-1", "").StatusCode)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/sensor-metadata/id/"+sensor.ID.String(), "").StatusCode)
+
+		resp := do(http.MethodGet, "/sensor-metadata/id/not-a-uuid", "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, CodeSensorNotFound, decodeProblem(t, resp).Code)
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		resp := do(http.MethodPost, "/sensor-metadata/ROOF-1/rename", `{"name": "attic-1"}`, fiber.HeaderIfMatch, `"1"`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"2"`, resp.Header.Get(fiber.HeaderETag))
+
+		var body struct {
+			Payload db.SensorMetadata `json:"payload"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "attic-1", body.Payload.Name)
+		assert.Equal(t, sensor.ID, body.Payload.ID)
+	})
+
+	t.Run("Old_Name_Redirects", func(t *testing.T) {
+		resp := do(http.MethodGet, "/sensor-metadata/roof-1?include_deleted=true", "")
+		assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+		assert.Equal(t, "/sensor-metadata/attic-1?include_deleted=true", resp.Header.Get(fiber.HeaderLocation))
+
+		resp = do(http.MethodGet, "/sensor-metadata/Roof-1.geojson", "")
+		assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+		assert.Equal(t, "/sensor-metadata/attic-1.geojson", resp.Header.Get(fiber.HeaderLocation))
+
+		// other methods keep theirs
+		resp = do(http.MethodPut, "/sensor-metadata/roof-1", `{"tags": ["a"]}`)
+		assert.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+		assert.Equal(t, "/sensor-metadata/attic-1", resp.Header.Get(fiber.HeaderLocation))
+
+		resp = do(http.MethodGet, "/sensor-metadata/attic-1/aliases", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var body struct {
+			Payload []db.SensorAlias `json:"payload"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		require.Len(t, body.Payload, 1)
+		assert.Equal(t, "Roof-1", body.Payload[0].Name)
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		tests := map[string]struct {
+			url    string
+			body   string
+			status int
+			code   string
+		}{
+			"Reserved_Name":  {"/sensor-metadata/cellar-1/rename", `{"name": "roof-1"}`, http.StatusConflict, CodeNameReserved},
+			"Taken_Name":     {"/sensor-metadata/cellar-1/rename", `{"name": "ATTIC-1"}`, http.StatusConflict, CodeDuplicateName},
+			"Missing_Name":   {"/sensor-metadata/cellar-1/rename", `{}`, http.StatusBadRequest, CodeValidationFailed},
+			"Invalid_Name":   {"/sensor-metadata/cellar-1/rename", `{"name": "a/b"}`, http.StatusBadRequest, CodeValidationFailed},
+			"Unknown_Sensor": {"/sensor-metadata/nowhere/rename", `{"name": "b"}`, http.StatusNotFound, CodeSensorNotFound},
+		}
+
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				resp := do(http.MethodPost, tt.url, tt.body)
+				assert.Equal(t, tt.status, resp.StatusCode)
+				assert.Equal(t, tt.code, decodeProblem(t, resp).Code)
+			})
+		}
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		resp := do(http.MethodDelete, "/sensor-metadata/cellar-1/aliases/roof-1", "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, CodeAliasNotFound, decodeProblem(t, resp).Code)
+
+		resp = do(http.MethodDelete, "/sensor-metadata/attic-1/aliases/roof-1", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/sensor-metadata/roof-1", "").StatusCode)
+
+		resp = do(http.MethodPost, "/sensor-metadata/cellar-1/rename", `{"name": "roof-1"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestSensorNamesInURLs(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+
+	// the server unescapes paths, so names with spaces and non-ASCII letters can be addressed
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler, UnescapePath: true})
+	app.Post("/sensor-metadata", CreateSensorMetadataHandler(database))
+	app.Get("/sensor-metadata/:name", GetSensorMetadataHandler(database))
+	app.Post("/sensor-metadata/:name/rename", RenameSensorMetadataHandler(database))
+
+	do := func(method, url, body string) *http.Response {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/sensor-metadata", `{"name": "my sensor", "location": {"latitude": 1, "longitude": 2}}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/sensor-metadata/my%20sensor", "").StatusCode)
+
+	resp = do(http.MethodPost, "/sensor-metadata/my%20sensor/rename", `{"name": "café sensor"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = do(http.MethodGet, "/sensor-metadata/my%20sensor", "")
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	location := resp.Header.Get(fiber.HeaderLocation)
+	assert.Equal(t, "/sensor-metadata/caf%C3%A9%20sensor", location)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, location, "").StatusCode)
+}
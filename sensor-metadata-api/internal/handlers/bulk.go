@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/geojson"
+	"strings"
+)
+
+// BulkWriteSensorMetadataHandler godoc
+// @Summary      Create or update many sensors
+// @Description  Write up to 1000 sensors in one transaction and report the outcome of each one by its index. With atomic=true nothing is written unless every sensor succeeds; otherwise the failing sensors are skipped.
+// @Tags         create
+// @Accept       json,application/geo+json
+// @Produce      json
+// @Param        mode     query    string   false   "insert (default), upsert or replace"
+// @Param        atomic   query    bool     false   "Write all sensors or none"
+// @Param        sensors  body     []db.SensorMetadata   true    "Sensors, or a GeoJSON FeatureCollection of Point features"
+// @Param        Idempotency-Key   header     string   false    "Replays the response of an earlier request with the same key instead of running it again"
+// @Success      207  {array}   db.BulkResult
+// @Failure      400  {object}  handlers.Problem
+// @Failure      422  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/bulk [post]
+func BulkWriteSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sensors, located, err := parseBulkBody(c)
+		if err != nil {
+			return badRequest(err.Error())
+		}
+
+		// inserts and replacements are whole sensors, checked like a single
+		// create; an upsert may leave out the fields of a sensor that exists,
+		// which the database checks and reports the same way
+		opts := db.BulkOptions{Mode: c.Query("mode"), Atomic: c.QueryBool("atomic")}
+		rejected := make([]error, len(sensors))
+		for i := range sensors {
+			if opts.Mode == db.BulkUpsert {
+				rejected[i] = validateSensorUpdate(&sensors[i], located[i])
+			} else {
+				rejected[i] = validateSensor(&sensors[i], located[i])
+			}
+		}
+
+		results, err := bulkWriteChecked(database, sensors, located, rejected, opts)
+		if err != nil {
+			return err
+		}
+
+		committed := true
+		for _, r := range results {
+			if r.Outcome == db.OutcomeRolledBack || (opts.Atomic && r.Outcome == db.OutcomeFailed) {
+				committed = false
+				break
+			}
+		}
+
+		return c.Status(http.StatusMultiStatus).JSON(fiber.Map{
+			"code": http.StatusMultiStatus,
+			"payload": fiber.Map{
+				"committed": committed,
+				"results":   results,
+			},
+		})
+	}
+}
+
+// parseBulkBody reads a JSON array of sensors, or a GeoJSON FeatureCollection
+// when the request is sent as application/geo+json. located tells which of the
+// sensors set a location.
+func parseBulkBody(c *fiber.Ctx) (sensors []db.SensorMetadata, located []bool, err error) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), geojson.MediaType) {
+		var items []json.RawMessage
+		if err := json.Unmarshal(c.Body(), &items); err != nil {
+			return nil, nil, errors.New("body must be a JSON array of sensors")
+		}
+		sensors = make([]db.SensorMetadata, len(items))
+		located = make([]bool, len(items))
+		for i, item := range items {
+			if err := json.Unmarshal(item, &sensors[i]); err != nil {
+				return nil, nil, errors.New("body must be a JSON array of sensors")
+			}
+			located[i] = jsonHasLocation(item)
+		}
+		return sensors, located, nil
+	}
+
+	var fc geojson.FeatureCollection
+	if err := json.Unmarshal(c.Body(), &fc); err != nil || fc.Type != geojson.TypeFeatureCollection {
+		return nil, nil, errors.New("body must be a GeoJSON FeatureCollection")
+	}
+	sensors = make([]db.SensorMetadata, 0, len(fc.Features))
+	located = make([]bool, 0, len(fc.Features))
+	for i := range fc.Features {
+		sensor, err := fc.Features[i].Sensor()
+		if err != nil {
+			return nil, nil, err
+		}
+		sensors = append(sensors, *sensor)
+		located = append(located, true)
+	}
+	return sensors, located, nil
+}
+
+// bulkWriteChecked writes the sensors that passed validation, located[i]
+// telling whether sensors[i] sets its location and rejected[i] being its
+// validation error, and returns one result per sensor. An atomic batch with a
+// rejected sensor writes nothing.
+func bulkWriteChecked(database db.SensorMetadataDB, sensors []db.SensorMetadata, located []bool, rejected []error, opts db.BulkOptions) ([]db.BulkResult, error) {
+	if len(sensors) > db.MaxBulkItems {
+		return nil, db.ErrTooManyItems
+	}
+
+	results := make([]db.BulkResult, len(sensors))
+	valid := make([]db.SensorMetadata, 0, len(sensors))
+	positions := make([]int, 0, len(sensors))
+	opts.Located = make([]bool, 0, len(sensors))
+	anyRejected := false
+	for i := range sensors {
+		if rejected[i] != nil {
+			results[i] = db.BulkResult{Index: i, Name: sensors[i].Name, Outcome: db.OutcomeFailed, Err: rejected[i]}
+			anyRejected = true
+			continue
+		}
+		valid = append(valid, sensors[i])
+		opts.Located = append(opts.Located, located[i])
+		positions = append(positions, i)
+	}
+
+	dryRun := opts.DryRun
+	opts.DryRun = dryRun || (opts.Atomic && anyRejected)
+	written, err := database.BulkWriteSensorMetadata(valid, opts)
+	if err != nil {
+		return nil, err
+	}
+	for j, r := range written {
+		r.Index = positions[j]
+		results[r.Index] = r
+	}
+	// failures are reported with the problem a single write would answer, so
+	// storage errors do not leak
+	for i := range results {
+		if results[i].Err != nil {
+			p := toProblem(results[i].Err)
+			results[i].Code, results[i].Error = p.Code, p.Error()
+		}
+	}
+
+	if opts.Atomic && anyRejected && !dryRun {
+		for i := range results {
+			if results[i].Outcome != db.OutcomeFailed {
+				results[i].Outcome = db.OutcomeRolledBack
+				results[i].Version = 0
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/geojson"
+	"strings"
+	"testing"
+)
+
+type bulkResponse struct {
+	Code    int `json:"code"`
+	Payload struct {
+		Committed bool            `json:"committed"`
+		Results   []db.BulkResult `json:"results"`
+	} `json:"payload"`
+}
+
+func bulkOutcomes(body bulkResponse) []string {
+	out := make([]string, 0, len(body.Payload.Results))
+	for _, r := range body.Payload.Results {
+		out = append(out, r.Outcome)
+	}
+	return out
+}
+
+func TestBulkWriteSensorMetadataHandler(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/sensor-metadata/bulk", BulkWriteSensorMetadataHandler(database))
+
+	post := func(url, contentType, body string) (int, bulkResponse) {
+		req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		var out bulkResponse
+		if resp.StatusCode >= http.StatusBadRequest {
+			decodeProblem(t, resp)
+			return resp.StatusCode, out
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return resp.StatusCode, out
+	}
+
+	status, body := post("/sensor-metadata/bulk", fiber.MIMEApplicationJSON, `[
+		{"name": "sensor-1", "description": "first", "location": {"latitude": 1, "longitude": 2}},
+		{"name": "sensor-2", "description": "second"}
+	]`)
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.True(t, body.Payload.Committed)
+	require.Len(t, body.Payload.Results, 2)
+	assert.Equal(t, db.OutcomeCreated, body.Payload.Results[0].Outcome)
+	assert.Equal(t, db.OutcomeFailed, body.Payload.Results[1].Outcome)
+	// with the problem a single create gets
+	assert.Equal(t, CodeValidationFailed, body.Payload.Results[1].Code)
+	assert.Equal(t, "location is required", body.Payload.Results[1].Error)
+
+	// upserts leave the check of a missing location to the database, which
+	// reports it the same way for new sensors
+	status, body = post("/sensor-metadata/bulk?mode=upsert", fiber.MIMEApplicationJSON, `[
+		{"name": "sensor-1", "description": "first, upserted"},
+		{"name": "sensor-2", "description": "second"},
+		{"description": "nameless"}
+	]`)
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.Equal(t, []string{db.OutcomeUpdated, db.OutcomeFailed, db.OutcomeFailed}, bulkOutcomes(body))
+	for _, r := range body.Payload.Results[1:] {
+		assert.Equal(t, CodeValidationFailed, r.Code)
+	}
+	assert.Equal(t, "location is required", body.Payload.Results[1].Error)
+	assert.Equal(t, "name is required; location is required", body.Payload.Results[2].Error)
+
+	status, _ = post("/sensor-metadata/bulk?mode=upsert&atomic=true", geojson.MediaType, `{
+		"type": "FeatureCollection",
+		"features": [
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [3, 4]}, "properties": {"name": "sensor-1"}},
+			{"type": "Feature", "geometry": null, "properties": {"name": "sensor-3"}}
+		]
+	}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, body = post("/sensor-metadata/bulk?mode=upsert&atomic=true", fiber.MIMEApplicationJSON, `[
+		{"name": "sensor-1", "location": {"latitude": 3, "longitude": 4}},
+		{"name": "sensor-3"}
+	]`)
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.False(t, body.Payload.Committed)
+	assert.Equal(t, db.OutcomeRolledBack, body.Payload.Results[0].Outcome)
+
+	sensor, err := database.GetSensorMetadataByName("sensor-1")
+	require.NoError(t, err)
+	assert.Equal(t, db.Location{Latitude: 1, Longitude: 2}, sensor.Location)
+
+	// items are validated like a single create, and (0, 0) is a location
+	status, body = post("/sensor-metadata/bulk", fiber.MIMEApplicationJSON, `[
+		{"name": "null-island", "description": "null island", "location": {"latitude": 0, "longitude": 0}},
+		{"name": "off-globe", "location": {"latitude": 91, "longitude": 0}}
+	]`)
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.Equal(t, db.OutcomeCreated, body.Payload.Results[0].Outcome)
+	assert.Equal(t, db.OutcomeFailed, body.Payload.Results[1].Outcome)
+	assert.Equal(t, "location.latitude must be between -90 and 90", body.Payload.Results[1].Error)
+	assert.Equal(t, CodeValidationFailed, body.Payload.Results[1].Code)
+
+	// whether a location is set is told per sensor, not for the whole batch
+	status, body = post("/sensor-metadata/bulk?mode=upsert", fiber.MIMEApplicationJSON, `[
+		{"name": "sensor-1", "description": "kept in place"},
+		{"name": "null-island-2", "description": "null island again", "location": {"latitude": 0, "longitude": 0}}
+	]`)
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.Equal(t, db.OutcomeUpdated, body.Payload.Results[0].Outcome)
+	assert.Equal(t, db.OutcomeCreated, body.Payload.Results[1].Outcome)
+	sensor, err = database.GetSensorMetadataByName("sensor-1")
+	require.NoError(t, err)
+	assert.Equal(t, db.Location{Latitude: 1, Longitude: 2}, sensor.Location)
+
+	// storage errors are reported as the problem a single write would get
+	status, body = post("/sensor-metadata/bulk", fiber.MIMEApplicationJSON, `[
+		{"name": "sensor-1", "description": "taken", "location": {"latitude": 1, "longitude": 2}}
+	]`)
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.Equal(t, db.OutcomeFailed, body.Payload.Results[0].Outcome)
+	assert.Equal(t, CodeDuplicateName, body.Payload.Results[0].Code)
+	assert.Equal(t, "a sensor with this name already exists", body.Payload.Results[0].Error)
+
+	status, _ = post("/sensor-metadata/bulk?mode=merge", fiber.MIMEApplicationJSON, `[]`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = post("/sensor-metadata/bulk", fiber.MIMEApplicationJSON, `{"name": "not-an-array"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/sensorcsv"
+	"strings"
+	"unicode/utf8"
+)
+
+// csvSuffix selects the CSV export of the list route.
+const csvSuffix = ".csv"
+
+// importRow is the outcome of one data row of a CSV import.
+type importRow struct {
+	Line    int    `json:"line"`
+	Name    string `json:"name"`
+	Outcome string `json:"outcome"`
+	Version int    `json:"version,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ImportSensorMetadataCSVHandler godoc
+// @Summary      Import sensors from CSV
+// @Description  Create or update sensors from the rows of a CSV file, in one transaction. Every row is validated like a single create, and reported by its line number. With dry_run=true the outcome of each row is reported without writing anything.
+// @Tags         create
+// @Accept       text/csv
+// @Produce      json
+// @Param        mode       query    string   false   "insert (default), upsert or replace"
+// @Param        atomic     query    bool     false   "Import all rows or none"
+// @Param        dry_run    query    bool     false   "Preview the outcome of each row without writing"
+// @Param        mapping    query    string   false   "Headers of the fields when they differ from the export, e.g. name=Sensor,latitude=Lat,longitude=Lon"
+// @Param        delimiter  query    string   false   "Field delimiter (default ,)"
+// @Param        Idempotency-Key   header     string   false    "Replays the response of an earlier request with the same key instead of running it again"
+// @Success      200  {object}  interface{}  "Dry run"
+// @Success      207  {object}  interface{}
+// @Failure      400  {object}  handlers.Problem
+// @Failure      422  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/import [post]
+func ImportSensorMetadataCSVHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		mapping, err := sensorcsv.ParseMapping(c.Query("mapping"))
+		if err != nil {
+			return badRequest(err.Error())
+		}
+
+		comma := ','
+		if d := c.Query("delimiter"); d != "" {
+			r, size := utf8.DecodeRuneInString(d)
+			if size != len(d) || r == '"' || r == '\n' || r == '\r' {
+				return badRequest("delimiter must be a single character")
+			}
+			comma = r
+		}
+
+		rows, err := sensorcsv.Read(bytes.NewReader(c.Body()), mapping, comma)
+		if err != nil {
+			return badRequest(err.Error())
+		}
+		// rows that fail validation never reach the database
+		sensors := make([]db.SensorMetadata, len(rows))
+		located := make([]bool, len(rows))
+		rejected := make([]error, len(rows))
+		for i, row := range rows {
+			sensors[i] = row.Sensor
+			located[i] = row.HasLocation
+			if row.Err != nil {
+				rejected[i] = NewProblem(http.StatusBadRequest, CodeValidationFailed, row.Err.Error())
+			} else {
+				rejected[i] = validateSensor(&row.Sensor, row.HasLocation)
+			}
+		}
+
+		dryRun := c.QueryBool("dry_run")
+		atomic := c.QueryBool("atomic")
+		opts := db.BulkOptions{
+			Mode:   c.Query("mode"),
+			Atomic: atomic,
+			DryRun: dryRun,
+		}
+		results, err := bulkWriteChecked(database, sensors, located, rejected, opts)
+		if err != nil {
+			return err
+		}
+
+		report := make([]importRow, len(rows))
+		failed := false
+		for i, r := range results {
+			report[i] = importRow{Line: rows[i].Line, Name: r.Name, Outcome: r.Outcome, Version: r.Version, Code: r.Code, Error: r.Error}
+			failed = failed || r.Outcome == db.OutcomeFailed
+		}
+		committed := !dryRun && !(atomic && failed)
+
+		summary := map[string]int{}
+		for _, r := range report {
+			summary[r.Outcome]++
+		}
+
+		status := http.StatusMultiStatus
+		if dryRun {
+			status = http.StatusOK
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"code": status,
+			"payload": fiber.Map{
+				"dry_run":   dryRun,
+				"committed": committed,
+				"summary":   summary,
+				"rows":      report,
+			},
+		})
+	}
+}
+
+// wantsCSV reports whether the client asked for the CSV export, through the
+// .csv suffix of the route or the Accept header.
+func wantsCSV(c *fiber.Ctx) bool {
+	if strings.HasSuffix(c.Path(), csvSuffix) {
+		return true
+	}
+	return c.Accepts(fiber.MIMEApplicationJSON, sensorcsv.MediaType) == sensorcsv.MediaType
+}
+
+// exportCSV writes every sensor matching opts, following the pages of the listing.
+func exportCSV(c *fiber.Ctx, database db.SensorMetadataDB, opts db.ListOptions) error {
+	var buf bytes.Buffer
+	w := sensorcsv.NewWriter(&buf)
+	if err := w.WriteHeader(); err != nil {
+		return err
+	}
+
+	opts.Limit = db.MaxListLimit
+	opts.Cursor = ""
+	for {
+		page, err := database.ListSensorMetadata(opts)
+		if err != nil {
+			return err
+		}
+		for i := range page.Items {
+			if err = w.Write(&page.Items[i]); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, sensorcsv.MediaType+"; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="sensor-metadata.csv"`)
+	return c.Status(http.StatusOK).Send(buf.Bytes())
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"strings"
+	"testing"
+)
+
+type importResponse struct {
+	Payload struct {
+		DryRun    bool           `json:"dry_run"`
+		Committed bool           `json:"committed"`
+		Summary   map[string]int `json:"summary"`
+		Rows      []importRow    `json:"rows"`
+	} `json:"payload"`
+}
+
+func TestCSVExport(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	for i := 0; i < db.MaxListLimit+2; i++ {
+		require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{
+			Name:        fmt.Sprintf("sensor-%04d", i),
+			Description: fmt.Sprintf("sensor %d", i),
+			Location:    db.Location{Latitude: 1, Longitude: 2},
+			Tags:        []string{"a", "b"},
+		}))
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata.csv", ListSensorMetadataHandler(database))
+	app.Get("/sensor-metadata", ListSensorMetadataHandler(database))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/sensor-metadata.csv?limit=1", nil),
+		httptest.NewRequest(http.MethodGet, "/sensor-metadata", nil),
+	} {
+		req.Header.Set("Accept", "text/csv")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		// the header and every sensor, across list pages
+		assert.Len(t, lines, db.MaxListLimit+3)
+		assert.True(t, strings.HasPrefix(lines[1], "sensor-0000,sensor 0,1,2,a;b,"), lines[1])
+	}
+}
+
+func TestImportSensorMetadataCSVHandler(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{
+		Name:        "existing",
+		Description: "existing",
+		Location:    db.Location{Latitude: 1, Longitude: 1},
+	}))
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/sensor-metadata/import", ImportSensorMetadataCSVHandler(database))
+
+	post := func(url, body string) (int, importResponse) {
+		req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		var out importResponse
+		if resp.StatusCode >= http.StatusBadRequest {
+			decodeProblem(t, resp)
+			return resp.StatusCode, out
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return resp.StatusCode, out
+	}
+
+	file := "name,latitude,longitude,tags\n" +
+		"new,2,2,x;y\n" +
+		"existing,3,3,\n" +
+		"no-location,,,\n" +
+		"bad,two,2,\n"
+
+	status, body := post("/sensor-metadata/import?mode=upsert&dry_run=true", file)
+	assert.Equal(t, http.StatusOK, status)
+	assert.False(t, body.Payload.Committed)
+	assert.Equal(t, map[string]int{"created": 1, "updated": 1, "failed": 2}, body.Payload.Summary)
+	require.Len(t, body.Payload.Rows, 4)
+	assert.Equal(t, importRow{Line: 4, Name: "no-location", Outcome: "failed", Code: CodeValidationFailed, Error: "location is required"}, body.Payload.Rows[2])
+	assert.Equal(t, 5, body.Payload.Rows[3].Line)
+
+	// the preview wrote nothing
+	_, err := database.GetSensorMetadataByName("new")
+	assert.Error(t, err)
+
+	status, body = post("/sensor-metadata/import?mode=upsert&atomic=true", file)
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.False(t, body.Payload.Committed)
+	assert.Equal(t, map[string]int{"rolled_back": 2, "failed": 2}, body.Payload.Summary)
+	_, err = database.GetSensorMetadataByName("new")
+	assert.Error(t, err)
+
+	status, body = post("/sensor-metadata/import?mode=upsert", file)
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.True(t, body.Payload.Committed)
+	assert.Equal(t, map[string]int{"created": 1, "updated": 1, "failed": 2}, body.Payload.Summary)
+
+	sensor, err := database.GetSensorMetadataByName("new")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x", "y"}, []string(sensor.Tags))
+
+	for _, url := range []string{
+		"/sensor-metadata/import?mapping=colour=Color",
+		"/sensor-metadata/import?delimiter=ab",
+		"/sensor-metadata/import?mode=merge",
+	} {
+		status, _ := post(url, file)
+		assert.Equal(t, http.StatusBadRequest, status, url)
+	}
+	status, _ = post("/sensor-metadata/import", "id,lat\n1,2\n")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+)
+
+// DeleteSensorMetadataHandler godoc
+// @Summary      Delete a sensor
+// @Description  Soft-delete a sensor. It is hidden from reads until restored, or listed with include_deleted=true.
+// @Tags         delete
+// @Accept       json
+// @Produce      json
+// @Param        name   path     string   true    "Sensor Name"
+// @Param        If-Match   header     string   false    "ETag of the version being deleted"
+// @Success      200  {object}  interface{}
+// @Failure      404  {object}  handlers.Problem
+// @Failure      412  {object}  handlers.Problem
+// @Failure      428  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/{name} [delete]
+func DeleteSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sensor, err := findSensor(c, database, false)
+		if err != nil {
+			return err
+		}
+		if !ifMatch(c, sensor) {
+			return db.ErrVersionConflict
+		}
+
+		if err = database.DeleteSensorMetadata(sensor.Name, sensor.Version); err != nil {
+			return sensorLookupError(err)
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": map[string]string{"message": "successfully deleted sensor metadata"},
+		})
+	}
+}
+
+// RestoreSensorMetadataHandler godoc
+// @Summary      Restore a deleted sensor
+// @Description  Restore a soft-deleted sensor
+// @Tags         delete
+// @Accept       json
+// @Produce      json
+// @Param        name       path       string   true     "Sensor Name"
+// @Param        If-Match   header     string   false    "ETag of the deleted version being restored"
+// @Success      200  {object}  db.SensorMetadata
+// @Failure      404  {object}  handlers.Problem
+// @Failure      412  {object}  handlers.Problem
+// @Failure      428  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/{name}/restore [post]
+func RestoreSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sensorName := c.Params("name")
+
+		version := 0
+		current, err := database.GetSensorMetadataByNameUnscoped(sensorName)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if current != nil {
+			if !ifMatch(c, current) {
+				return db.ErrVersionConflict
+			}
+			version = current.Version
+		}
+
+		sensor, err := database.RestoreSensorMetadata(sensorName, version)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return resolveAlias(c, database, sensorName,
+					NewProblem(http.StatusNotFound, CodeSensorNotFound, "deleted sensor metadata not found"))
+			}
+			return err
+		}
+
+		c.Set(fiber.HeaderETag, sensorETag(sensor))
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": sensor,
+		})
+	}
+}
+
+// PurgeSensorMetadataHandler godoc
+// @Summary      Purge a sensor
+// @Description  Permanently delete a sensor, whether soft-deleted or not. Its history is replaced by a single purge revision, which is also sent as an event. Requires the admin token.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        name   path     string   true    "Sensor Name"
+// @Param        Authorization  header  string  true  "Bearer admin token"
+// @Success      200  {object}  interface{}
+// @Failure      401  {object}  handlers.Problem
+// @Failure      404  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /admin/sensor-metadata/{name} [delete]
+func PurgeSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := database.PurgeSensorMetadata(c.Params("name")); err != nil {
+			return sensorLookupError(err)
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": map[string]string{"message": "successfully purged sensor metadata"},
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"testing"
+)
+
+func TestDeleteRestorePurgeHandlers(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{
+		Name:     "sensor-1",
+		Location: db.Location{Latitude: 40.0, Longitude: -80.0},
+	}))
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata/:name", GetSensorMetadataHandler(database))
+	app.Delete("/sensor-metadata/:name", DeleteSensorMetadataHandler(database))
+	app.Post("/sensor-metadata/:name/restore", RestoreSensorMetadataHandler(database))
+	app.Delete("/admin/sensor-metadata/:name", PurgeSensorMetadataHandler(database))
+
+	status := func(method, url string) int {
+		resp, err := app.Test(httptest.NewRequest(method, url, nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, status(http.MethodDelete, "/sensor-metadata/sensor-1"))
+	assert.Equal(t, http.StatusNotFound, status(http.MethodDelete, "/sensor-metadata/sensor-1"))
+	assert.Equal(t, http.StatusNotFound, status(http.MethodGet, "/sensor-metadata/sensor-1"))
+	assert.Equal(t, http.StatusOK, status(http.MethodGet, "/sensor-metadata/sensor-1?include_deleted=true"))
+
+	// restoring checks If-Match against the deleted version
+	req := httptest.NewRequest(http.MethodPost, "/sensor-metadata/sensor-1/restore", nil)
+	req.Header.Set("If-Match", `"1"`)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	assert.Equal(t, http.StatusOK, status(http.MethodPost, "/sensor-metadata/sensor-1/restore"))
+	assert.Equal(t, http.StatusNotFound, status(http.MethodPost, "/sensor-metadata/sensor-1/restore"))
+	assert.Equal(t, http.StatusOK, status(http.MethodGet, "/sensor-metadata/sensor-1"))
+
+	assert.Equal(t, http.StatusOK, status(http.MethodDelete, "/admin/sensor-metadata/sensor-1"))
+	assert.Equal(t, http.StatusNotFound, status(http.MethodGet, "/sensor-metadata/sensor-1?include_deleted=true"))
+	assert.Equal(t, http.StatusNotFound, status(http.MethodDelete, "/admin/sensor-metadata/sensor-1"))
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequireIfMatch answers 428 Precondition Required to requests without an
+// If-Match header, so clients cannot skip the version check. It guards the
+// routes that change a sensor, whatever their method.
+func RequireIfMatch() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get(fiber.HeaderIfMatch) == "" {
+			return NewProblem(http.StatusPreconditionRequired, CodePreconditionRequired, "If-Match header is required")
+		}
+		return c.Next()
+	}
+}
+
+// sensorETag returns the strong entity tag of the sensor's current version.
+func sensorETag(sensor *db.SensorMetadata) string {
+	return `"` + strconv.Itoa(sensor.Version) + `"`
+}
+
+// Renderings served from the same URL besides JSON, told apart by renderingETag.
+const (
+	renderingGeoJSON = "geojson"
+	renderingCSV     = "csv"
+)
+
+// renderingETag returns the entity tag of one rendering of the resource whose
+// JSON rendering is tagged etag. Each rendering gets its own strong tag, so a
+// cache never serves one in place of another.
+func renderingETag(etag, rendering string) string {
+	if rendering == "" {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + rendering + `"`
+}
+
+// ifMatch evaluates the If-Match header against the current sensor, in any of
+// its renderings. A missing header matches; weak tags never do, as If-Match
+// uses strong comparison.
+func ifMatch(c *fiber.Ctx, sensor *db.SensorMetadata) bool {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		return true
+	}
+	etag := sensorETag(sensor)
+	return etagMatches(header, etag, false) || etagMatches(header, renderingETag(etag, renderingGeoJSON), false)
+}
+
+// etagMatches reports whether a list of entity tags, as sent in If-Match and
+// If-None-Match, holds etag or "*". The weak comparison of If-None-Match also
+// accepts W/ tags.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// changeETag returns the entity tag of a listing taken at the given state of
+// the collection.
+func changeETag(token db.ChangeToken) string {
+	return `"` + token.String() + `"`
+}
+
+// notModified sets the validators of the representation about to be sent and
+// reports whether the conditional GET headers show the client already holds
+// it, in which case 304 Not Modified is answered instead. If-Modified-Since is
+// only evaluated without If-None-Match, and is skipped when lastModified is zero.
+func notModified(c *fiber.Ctx, etag string, lastModified time.Time) bool {
+	c.Set(fiber.HeaderETag, etag)
+	if !lastModified.IsZero() {
+		c.Set(fiber.HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+	}
+	// the JSON, GeoJSON and CSV renderings are chosen by Accept, see renderingETag
+	c.Vary(fiber.HeaderAccept)
+
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return false
+	}
+	if header := c.Get(fiber.HeaderIfNoneMatch); header != "" {
+		return etagMatches(header, etag, true)
+	}
+	if header := c.Get(fiber.HeaderIfModifiedSince); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		// Last-Modified has a resolution of one second
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/geojson"
+	"sensor-metadata-api/internal/sensorcsv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{
+		Name:     "sensor-1",
+		Location: db.Location{Latitude: 40.0, Longitude: -80.0},
+	}))
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata/:name", GetSensorMetadataHandler(database))
+	app.Put("/sensor-metadata/:name", UpdateSensorMetadataHandler(database))
+	app.Delete("/sensor-metadata/:name", DeleteSensorMetadataHandler(database))
+
+	do := func(method, ifMatch, body string) *http.Response {
+		req := httptest.NewRequest(method, "/sensor-metadata/sensor-1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := do(http.MethodGet, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+
+	resp = do(http.MethodPut, `"1"`, `{"tags": ["first"]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+	// a client still holding version 1 is turned away
+	resp = do(http.MethodPut, `"1"`, `{"tags": ["stale"]}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodPut, `W/"2"`, `{}`).StatusCode)
+	assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodDelete, `"1"`, "").StatusCode)
+
+	got, err := database.GetSensorMetadataByName("sensor-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first"}, []string(got.Tags))
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, `"7", "2"`, `{"tags": ["second"]}`).StatusCode)
+	// the tag of the GeoJSON rendering names the same version
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, `"3-geojson"`, `{"tags": ["third"]}`).StatusCode)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "*", "").StatusCode)
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) }
+	app.Get("/", ok)
+	app.Post("/", RequireIfMatch(), ok)
+	app.Put("/", RequireIfMatch(), ok)
+	app.Patch("/", RequireIfMatch(), ok)
+	app.Delete("/", RequireIfMatch(), ok)
+
+	status := func(method, ifMatch string) int {
+		req := httptest.NewRequest(method, "/", nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, status(http.MethodGet, ""))
+	assert.Equal(t, http.StatusPreconditionRequired, status(http.MethodPost, ""))
+	assert.Equal(t, http.StatusOK, status(http.MethodPost, `"3"`))
+	assert.Equal(t, http.StatusPreconditionRequired, status(http.MethodPut, ""))
+	assert.Equal(t, http.StatusPreconditionRequired, status(http.MethodPatch, ""))
+	assert.Equal(t, http.StatusPreconditionRequired, status(http.MethodDelete, ""))
+	assert.Equal(t, http.StatusOK, status(http.MethodDelete, `"3"`))
+}
+
+func TestConditionalGet(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{
+		Name:      "sensor-1",
+		Location:  db.Location{Latitude: 40.0, Longitude: -80.0},
+		UpdatedAt: time.Date(2023, 8, 1, 12, 0, 0, 500, time.UTC),
+	}))
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata", ListSensorMetadataHandler(database))
+	app.Get("/sensor-metadata/:name", GetSensorMetadataHandler(database))
+
+	get := func(url string, header ...string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Sensor", func(t *testing.T) {
+		resp := get("/sensor-metadata/sensor-1")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"1"`, resp.Header.Get(fiber.HeaderETag))
+		assert.Equal(t, "Tue, 01 Aug 2023 12:00:00 GMT", resp.Header.Get(fiber.HeaderLastModified))
+
+		tests := map[string]struct {
+			header []string
+			status int
+		}{
+			"Matching_ETag":       {[]string{fiber.HeaderIfNoneMatch, `"1"`}, http.StatusNotModified},
+			"Weak_ETag":           {[]string{fiber.HeaderIfNoneMatch, `"9", W/"1"`}, http.StatusNotModified},
+			"Other_ETag":          {[]string{fiber.HeaderIfNoneMatch, `"0"`}, http.StatusOK},
+			"Not_Modified_Since":  {[]string{fiber.HeaderIfModifiedSince, "Tue, 01 Aug 2023 12:00:00 GMT"}, http.StatusNotModified},
+			"Modified_Since":      {[]string{fiber.HeaderIfModifiedSince, "Tue, 01 Aug 2023 11:59:59 GMT"}, http.StatusOK},
+			"Invalid_Date":        {[]string{fiber.HeaderIfModifiedSince, "yesterday"}, http.StatusOK},
+			"ETag_Takes_Priority": {[]string{fiber.HeaderIfNoneMatch, `"0"`, fiber.HeaderIfModifiedSince, "Tue, 01 Aug 2023 12:00:00 GMT"}, http.StatusOK},
+		}
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				resp := get("/sensor-metadata/sensor-1", tt.header...)
+				assert.Equal(t, tt.status, resp.StatusCode)
+				assert.Equal(t, `"1"`, resp.Header.Get(fiber.HeaderETag))
+			})
+		}
+	})
+
+	t.Run("Renderings", func(t *testing.T) {
+		// every rendering of a URL has its own tag, so one is never served for another
+		resp := get("/sensor-metadata/sensor-1", fiber.HeaderAccept, geojson.MediaType, fiber.HeaderIfNoneMatch, `"1"`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"1-geojson"`, resp.Header.Get(fiber.HeaderETag))
+		resp = get("/sensor-metadata/sensor-1", fiber.HeaderAccept, geojson.MediaType, fiber.HeaderIfNoneMatch, `"1-geojson"`)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+		tags := map[string]bool{}
+		for _, accept := range []string{fiber.MIMEApplicationJSON, geojson.MediaType, sensorcsv.MediaType} {
+			resp := get("/sensor-metadata", fiber.HeaderAccept, accept)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			tags[resp.Header.Get(fiber.HeaderETag)] = true
+		}
+		assert.Len(t, tags, 3)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		resp := get("/sensor-metadata")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		etag := resp.Header.Get(fiber.HeaderETag)
+		require.NotEmpty(t, etag)
+
+		assert.Equal(t, http.StatusNotModified, get("/sensor-metadata", fiber.HeaderIfNoneMatch, etag).StatusCode)
+		assert.Equal(t, http.StatusNotModified, get("/sensor-metadata?limit=1", fiber.HeaderIfNoneMatch, etag).StatusCode)
+
+		// any change to any sensor moves the token
+		sensor, err := database.GetSensorMetadataByName("sensor-1")
+		require.NoError(t, err)
+		sensor.Description = "moved"
+		require.NoError(t, database.UpdateSensorMetadata(sensor))
+
+		resp = get("/sensor-metadata", fiber.HeaderIfNoneMatch, etag)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEqual(t, etag, resp.Header.Get(fiber.HeaderETag))
+	})
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"net"
+	"sensor-metadata-api/internal/db"
+	"strconv"
+	"time"
+)
+
+const (
+	// MediaTypeEventStream is the media type of server-sent events.
+	MediaTypeEventStream = "text/event-stream"
+
+	// eventBatchSize is the number of revisions read at once.
+	eventBatchSize = 100
+	// eventRetry is the reconnection delay suggested to clients, in milliseconds.
+	eventRetry = 1000
+)
+
+// EventStreamOptions tell how event streams are paced and how long they last.
+type EventStreamOptions struct {
+	// Poll is how often a stream looks for new revisions.
+	Poll time.Duration
+	// Heartbeat is how long a quiet stream waits before sending a comment, so
+	// proxies do not close it as idle.
+	Heartbeat time.Duration
+	// Duration is how long a stream lasts before the client has to reconnect.
+	Duration time.Duration
+	// WriteTimeout bounds each write of a stream in place of the server's write
+	// timeout, which would bound the whole stream; 0 leaves writes unbounded.
+	WriteTimeout time.Duration
+}
+
+// SensorEvent is a change of a sensor, sent as the data of a server-sent event
+// whose id is the sequence number of the revision and whose name is its type.
+type SensorEvent struct {
+	// Type is the operation of the revision: create, update, delete, restore, rollback or purge.
+	Type string `json:"type"`
+	// Sensor is the sensor as it was right after the change.
+	Sensor    db.SensorMetadata `json:"sensor"`
+	Revision  int               `json:"revision"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// eventFilter selects the events of the sensors carrying every tag of Tags
+// and, when Box is set, located inside it.
+type eventFilter struct {
+	Tags []string
+	Box  *db.BoundingBox
+}
+
+func (f *eventFilter) matches(sensor *db.SensorMetadata) bool {
+	for _, tag := range f.Tags {
+		if !sensor.Tags.Contains(tag) {
+			return false
+		}
+	}
+	return f.Box == nil || f.Box.Contains(sensor.Location)
+}
+
+// SensorEventsHandler godoc
+// @Summary      Stream sensor changes
+// @Description  Stream the changes of sensors as server-sent events. Each event is named after its type (create, update, delete, restore, rollback or purge), its id is the sequence number of the revision, and its data a SensorEvent with the sensor as it was right after the change. A client resumes after the last event it received with the Last-Event-ID header, which EventSource sends on reconnection, or the last_event_id parameter; without either the stream starts with the next change. Streams end after the configured event_stream_sec and are meant to be reconnected; quiet streams send a comment every 15 seconds. A purged sensor ends with a purge event holding it as it was, marked deleted.
+// @Tags         revisions
+// @Produce      text/event-stream
+// @Param        Last-Event-ID   header   int      false   "Resume after this event"
+// @Param        last_event_id   query    int      false   "Resume after this event, when the header can not be set"
+// @Param        tags            query    string   false   "Comma separated tags a sensor must all carry"
+// @Param        min_lat         query    number   false   "Southern edge of the area of the sensors, with the other edges"
+// @Param        min_lon         query    number   false   "Western edge"
+// @Param        max_lat         query    number   false   "Northern edge"
+// @Param        max_lon         query    number   false   "Eastern edge"
+// @Success      200  {object}  handlers.SensorEvent  "as the data of each event"
+// @Failure      400  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/events [get]
+func SensorEventsHandler(database db.SensorMetadataDB, opts EventStreamOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filter, err := parseEventFilter(c)
+		if err != nil {
+			return badRequest(err.Error())
+		}
+
+		id := c.Get("Last-Event-ID")
+		if id == "" {
+			id = c.Query("last_event_id")
+		}
+		var after int64
+		if id != "" {
+			if after, err = strconv.ParseInt(id, 10, 64); err != nil || after < 0 {
+				return badRequest("Last-Event-ID must be a non-negative integer")
+			}
+		} else {
+			token, err := database.GetChangeToken()
+			if err != nil {
+				return err
+			}
+			after = token.Seq
+		}
+
+		c.Set(fiber.HeaderContentType, MediaTypeEventStream)
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		// keep reverse proxies from buffering the stream
+		c.Set("X-Accel-Buffering", "no")
+		conn := c.Context().Conn()
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			streamEvents(w, conn, database, filter, after, opts)
+		})
+		return nil
+	}
+}
+
+// streamEvents writes the events after seq to w, polling for new revisions
+// until the stream is over, the client is gone or reading fails. The backends
+// make revisions visible in seq order, so a client reconnecting with the id of
+// the last event it got misses nothing.
+func streamEvents(w *bufio.Writer, conn net.Conn, database db.SensorMetadataDB, filter *eventFilter, seq int64, opts EventStreamOptions) {
+	deadline := time.Now().Add(opts.Duration)
+	ticker := time.NewTicker(opts.Poll)
+	defer ticker.Stop()
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry)
+	lastWrite := time.Now()
+	for {
+		// the server set the write deadline once for the whole response
+		if conn != nil && opts.WriteTimeout > 0 {
+			_ = conn.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+		}
+		for {
+			revisions, err := database.ListRevisionsSince(seq, eventBatchSize)
+			if err != nil {
+				return
+			}
+			for i := range revisions {
+				rev := &revisions[i]
+				seq = rev.Seq
+				if !filter.matches(&rev.Snapshot) {
+					continue
+				}
+				if err := writeEvent(w, rev); err != nil {
+					return
+				}
+				lastWrite = time.Now()
+			}
+			if len(revisions) < eventBatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastWrite) >= opts.Heartbeat {
+			w.WriteString(": heartbeat\n\n")
+			lastWrite = time.Now()
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+		if time.Until(deadline) < opts.Poll {
+			return
+		}
+		<-ticker.C
+	}
+}
+
+// writeEvent writes a revision as a server-sent event.
+func writeEvent(w *bufio.Writer, rev *db.SensorRevision) error {
+	data, err := json.Marshal(SensorEvent{
+		Type:      rev.Operation,
+		Sensor:    rev.Snapshot,
+		Revision:  rev.Revision,
+		Timestamp: rev.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", rev.Seq, rev.Operation, data)
+	return err
+}
+
+// parseEventFilter reads the tags and the optional box of an event stream;
+// the box needs all of its edges.
+func parseEventFilter(c *fiber.Ctx) (*eventFilter, error) {
+	filter := &eventFilter{Tags: splitList(c.Query("tags"))}
+	if c.Query("min_lat") == "" && c.Query("min_lon") == "" && c.Query("max_lat") == "" && c.Query("max_lon") == "" {
+		return filter, nil
+	}
+
+	box, err := queryBox(c)
+	if err != nil {
+		return nil, err
+	}
+	min := db.Location{Latitude: box.MinLatitude, Longitude: box.MinLongitude}
+	max := db.Location{Latitude: box.MaxLatitude, Longitude: box.MaxLongitude}
+	if !min.Valid() || !max.Valid() || box.MinLatitude > box.MaxLatitude {
+		return nil, errors.New("the box must lie on earth, min_lat below max_lat")
+	}
+	filter.Box = &box
+	return filter, nil
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sentEvent is a server-sent event as read by a client.
+type sentEvent struct {
+	ID   int64
+	Name string
+	Data SensorEvent
+}
+
+func TestSensorEventsHandler(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	for _, s := range []db.SensorMetadata{
+		{Name: "philadelphia-1", Description: "a", Location: db.Location{Latitude: 39.95, Longitude: -75.17}, Tags: []string{"outdoor"}},
+		{Name: "pittsburgh-1", Description: "b", Location: db.Location{Latitude: 40.44, Longitude: -79.99}},
+	} {
+		s := s
+		require.NoError(t, database.CreateSensorMetadata(&s))
+	}
+	require.NoError(t, database.DeleteSensorMetadata("philadelphia-1", 0))
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	opts := EventStreamOptions{Poll: 10 * time.Millisecond, Heartbeat: time.Minute, Duration: 300 * time.Millisecond}
+	app.Get("/sensor-metadata/events", SensorEventsHandler(database, opts))
+
+	stream := func(url, lastEventID string) []sentEvent {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := app.Test(req, 5000)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, MediaTypeEventStream, resp.Header.Get(fiber.HeaderContentType))
+		return readEvents(t, resp)
+	}
+	names := func(events []sentEvent) []string {
+		names := make([]string, 0, len(events))
+		for _, e := range events {
+			names = append(names, e.Name+" "+e.Data.Sensor.Name)
+		}
+		return names
+	}
+
+	t.Run("Resume", func(t *testing.T) {
+		events := stream("/sensor-metadata/events", "1")
+		assert.Equal(t, []string{"create pittsburgh-1", "delete philadelphia-1"}, names(events))
+		assert.Equal(t, int64(2), events[0].ID)
+		assert.Equal(t, int64(3), events[1].ID)
+		assert.Equal(t, db.OpDelete, events[1].Data.Type)
+		assert.Equal(t, 2, events[1].Data.Revision)
+		assert.True(t, events[1].Data.Sensor.DeletedAt.Valid)
+		assert.False(t, events[1].Data.Timestamp.IsZero())
+
+		events = stream("/sensor-metadata/events?last_event_id=0", "")
+		assert.Len(t, events, 3)
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		events := stream("/sensor-metadata/events?tags=outdoor", "0")
+		assert.Equal(t, []string{"create philadelphia-1", "delete philadelphia-1"}, names(events))
+
+		events = stream("/sensor-metadata/events?min_lat=40&min_lon=-81&max_lat=41&max_lon=-79", "0")
+		assert.Equal(t, []string{"create pittsburgh-1"}, names(events))
+	})
+
+	t.Run("Live", func(t *testing.T) {
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			assert.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{Name: "harrisburg-1", Description: "c"}))
+		}()
+		events := stream("/sensor-metadata/events", "")
+		assert.Equal(t, []string{"create harrisburg-1"}, names(events))
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			assert.NoError(t, database.PurgeSensorMetadata("pittsburgh-1"))
+		}()
+		events := stream("/sensor-metadata/events", "")
+		assert.Equal(t, []string{"purge pittsburgh-1"}, names(events))
+		require.Len(t, events, 1)
+		assert.True(t, events[0].Data.Sensor.DeletedAt.Valid)
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		quiet := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		quiet.Get("/sensor-metadata/events", SensorEventsHandler(database, EventStreamOptions{
+			Poll:      10 * time.Millisecond,
+			Heartbeat: 50 * time.Millisecond,
+			Duration:  300 * time.Millisecond,
+		}))
+		resp, err := quiet.Test(httptest.NewRequest(http.MethodGet, "/sensor-metadata/events", nil), 5000)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), ": heartbeat\n\n")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, url := range []string{
+			"/sensor-metadata/events?last_event_id=abc",
+			"/sensor-metadata/events?last_event_id=-1",
+			"/sensor-metadata/events?min_lat=40",
+			"/sensor-metadata/events?min_lat=41&min_lon=-81&max_lat=40&max_lon=-79",
+		} {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, url)
+			assert.Equal(t, CodeInvalidRequest, decodeProblem(t, resp).Code)
+		}
+	})
+}
+
+func TestStreamEventsWriteDeadline(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	conn := &deadlineConn{}
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	// the deadline moves along with the stream, which outlasts the timeout
+	streamEvents(w, conn, database, &eventFilter{}, 0, EventStreamOptions{
+		Poll:         10 * time.Millisecond,
+		Heartbeat:    time.Minute,
+		Duration:     100 * time.Millisecond,
+		WriteTimeout: 20 * time.Millisecond,
+	})
+	require.GreaterOrEqual(t, len(conn.deadlines), 5)
+	for i := 1; i < len(conn.deadlines); i++ {
+		assert.True(t, conn.deadlines[i].After(conn.deadlines[i-1]))
+	}
+	assert.True(t, strings.HasPrefix(buf.String(), "retry: "))
+}
+
+// deadlineConn records the write deadlines set on it.
+type deadlineConn struct {
+	net.Conn
+	deadlines []time.Time
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.deadlines = append(c.deadlines, t)
+	return nil
+}
+
+// readEvents parses the events of a stream, skipping comments and fields
+// without an event.
+func readEvents(t *testing.T, resp *http.Response) []sentEvent {
+	t.Helper()
+	events := make([]sentEvent, 0)
+	var event sentEvent
+	var data string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ": ")
+		switch field {
+		case "id":
+			id, err := strconv.ParseInt(value, 10, 64)
+			require.NoError(t, err)
+			event.ID = id
+		case "event":
+			event.Name = value
+		case "data":
+			data = value
+		case "":
+			if data != "" {
+				require.NoError(t, json.Unmarshal([]byte(data), &event.Data))
+				events = append(events, event)
+			}
+			event, data = sentEvent{}, ""
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/geojson"
+	"strconv"
+)
+
+// defaultNearest is the number of sensors a nearest query returns without k.
+const defaultNearest = 10
+
+// FindSensorMetadataInBoxHandler godoc
+// @Summary      Find sensors in a bounding box
+// @Description  List the sensors located inside a bounding box, edges included. A box whose min_lon is greater than its max_lon crosses the antimeridian.
+// @Tags         geo
+// @Accept       json
+// @Produce      json,application/geo+json
+// @Param        min_lat   query    number   true    "Southern edge"
+// @Param        min_lon   query    number   true    "Western edge"
+// @Param        max_lat   query    number   true    "Northern edge"
+// @Param        max_lon   query    number   true    "Eastern edge"
+// @Success      200  {array}   db.SensorMetadata
+// @Failure      400  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/geo/bbox [get]
+func FindSensorMetadataInBoxHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		box, err := queryBox(c)
+		if err != nil {
+			return badRequest(err.Error())
+		}
+
+		sensors, err := database.FindSensorMetadataInBox(box)
+		if err != nil {
+			return geoQueryError(err)
+		}
+		if wantsGeoJSON(c) {
+			return sendGeoJSON(c, http.StatusOK, geojson.FromSensors(sensors))
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": sensors,
+		})
+	}
+}
+
+// FindSensorClustersHandler godoc
+// @Summary      Cluster the sensors of a map view
+// @Description  Group the sensors inside a bounding box by geohash cells sized for the zoom level, about a quarter of a map tile wide. A cell holding several sensors is returned as a cluster with its cell id, the centroid of its sensors, their count and a sample of their names; a sensor alone in its cell is returned as itself.
+// @Tags         geo
+// @Accept       json
+// @Produce      json
+// @Param        min_lat   query    number   true    "Southern edge"
+// @Param        min_lon   query    number   true    "Western edge"
+// @Param        max_lat   query    number   true    "Northern edge"
+// @Param        max_lon   query    number   true    "Eastern edge"
+// @Param        zoom      query    int      true    "Map zoom level, 0 to 22"
+// @Param        sample    query    int      false   "Names listed per cluster (default 3, max 20)"
+// @Success      200  {object}  db.SensorClusters
+// @Failure      400  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/geo/clusters [get]
+func FindSensorClustersHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		box, err := queryBox(c)
+		if err != nil {
+			return badRequest(err.Error())
+		}
+		zoom, err := strconv.Atoi(c.Query("zoom"))
+		if err != nil || zoom < 0 || zoom > db.MaxZoom {
+			return badRequest("zoom must be an integer between 0 and 22")
+		}
+		sample := db.DefaultClusterSample
+		if v := c.Query("sample"); v != "" {
+			if sample, err = strconv.Atoi(v); err != nil || sample < 0 || sample > db.MaxClusterSample {
+				return badRequest("sample must be an integer between 0 and 20")
+			}
+		}
+
+		sensors, err := database.FindSensorMetadataInBox(box)
+		if err != nil {
+			return geoQueryError(err)
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": db.ClusterSensors(sensors, zoom, sample),
+		})
+	}
+}
+
+// FindSensorMetadataWithinRadiusHandler godoc
+// @Summary      Find sensors around a point
+// @Description  List the sensors within a great-circle distance of a point, nearest first
+// @Tags         geo
+// @Accept       json
+// @Produce      json,application/geo+json
+// @Param        lat      query    number   true    "Latitude of the center"
+// @Param        lon      query    number   true    "Longitude of the center"
+// @Param        radius   query    number   true    "Radius in meters"
+// @Success      200  {array}   db.SensorDistance
+// @Failure      400  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/geo/radius [get]
+func FindSensorMetadataWithinRadiusHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		center, err := queryLocation(c)
+		if err != nil {
+			return badRequest(err.Error())
+		}
+		radius, err := queryFloat(c, "radius")
+		if err != nil {
+			return badRequest(err.Error())
+		}
+
+		sensors, err := database.FindSensorMetadataWithinRadius(center, radius)
+		if err != nil {
+			return geoQueryError(err)
+		}
+		if wantsGeoJSON(c) {
+			return sendGeoJSON(c, http.StatusOK, geojson.FromDistances(sensors))
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": sensors,
+		})
+	}
+}
+
+// FindNearestSensorMetadataHandler godoc
+// @Summary      Find the sensors nearest to a point
+// @Description  List the k sensors closest to a point with their distance in meters, nearest first
+// @Tags         geo
+// @Accept       json
+// @Produce      json,application/geo+json
+// @Param        lat   query    number   true    "Latitude of the point"
+// @Param        lon   query    number   true    "Longitude of the point"
+// @Param        k     query    int      false   "Number of sensors (default 10, max 500)"
+// @Success      200  {array}   db.SensorDistance
+// @Failure      400  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/geo/nearest [get]
+func FindNearestSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		center, err := queryLocation(c)
+		if err != nil {
+			return badRequest(err.Error())
+		}
+
+		k := defaultNearest
+		if v := c.Query("k"); v != "" {
+			if k, err = strconv.Atoi(v); err != nil {
+				return badRequest("k must be an integer")
+			}
+		}
+
+		sensors, err := database.FindNearestSensorMetadata(center, k)
+		if err != nil {
+			return geoQueryError(err)
+		}
+		if wantsGeoJSON(c) {
+			return sendGeoJSON(c, http.StatusOK, geojson.FromDistances(sensors))
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": sensors,
+		})
+	}
+}
+
+// queryBox reads the min_lat, min_lon, max_lat and max_lon query parameters.
+func queryBox(c *fiber.Ctx) (db.BoundingBox, error) {
+	var box db.BoundingBox
+	var err error
+	for param, dst := range map[string]*float64{
+		"min_lat": &box.MinLatitude,
+		"min_lon": &box.MinLongitude,
+		"max_lat": &box.MaxLatitude,
+		"max_lon": &box.MaxLongitude,
+	} {
+		if *dst, err = queryFloat(c, param); err != nil {
+			return box, err
+		}
+	}
+	return box, nil
+}
+
+// queryLocation reads the lat and lon query parameters.
+func queryLocation(c *fiber.Ctx) (db.Location, error) {
+	lat, err := queryFloat(c, "lat")
+	if err != nil {
+		return db.Location{}, err
+	}
+	lon, err := queryFloat(c, "lon")
+	if err != nil {
+		return db.Location{}, err
+	}
+	return db.Location{Latitude: lat, Longitude: lon}, nil
+}
+
+// queryFloat parses a required numeric query parameter.
+func queryFloat(c *fiber.Ctx, param string) (float64, error) {
+	v, err := strconv.ParseFloat(c.Query(param), 64)
+	if err != nil {
+		return 0, errors.New(param + " must be a number")
+	}
+	return v, nil
+}
+
+// geoQueryError explains the bounds of a rejected spatial query.
+func geoQueryError(err error) error {
+	if errors.Is(err, db.ErrInvalidGeoQuery) {
+		return badRequest("coordinates must lie on earth, radius must be positive and k between 1 and 500")
+	}
+	return err
+}
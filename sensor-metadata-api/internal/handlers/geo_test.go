@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"testing"
+)
+
+func TestGeoHandlers(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	for name, loc := range map[string]db.Location{
+		"pittsburgh":   {Latitude: 40.4406, Longitude: -79.9959},
+		"philadelphia": {Latitude: 39.9526, Longitude: -75.1652},
+		"new-york":     {Latitude: 40.7128, Longitude: -74.0060},
+	} {
+		require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{Name: name, Description: name, Location: loc}))
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata/geo/bbox", FindSensorMetadataInBoxHandler(database))
+	app.Get("/sensor-metadata/geo/clusters", FindSensorClustersHandler(database))
+	app.Get("/sensor-metadata/geo/radius", FindSensorMetadataWithinRadiusHandler(database))
+	app.Get("/sensor-metadata/geo/nearest", FindNearestSensorMetadataHandler(database))
+
+	get := func(url string, payload interface{}) int {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+		require.NoError(t, err)
+		if resp.StatusCode >= http.StatusBadRequest {
+			*payload.(*Problem) = decodeProblem(t, resp)
+			return resp.StatusCode
+		}
+		body := struct {
+			Payload interface{} `json:"payload"`
+		}{Payload: payload}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode
+	}
+
+	var sensors []db.SensorMetadata
+	assert.Equal(t, http.StatusOK, get("/sensor-metadata/geo/bbox?min_lat=39&min_lon=-81&max_lat=42&max_lon=-74.5", &sensors))
+	assert.Len(t, sensors, 2)
+
+	var clusters db.SensorClusters
+	assert.Equal(t, http.StatusOK, get("/sensor-metadata/geo/clusters?min_lat=39&min_lon=-81&max_lat=42&max_lon=-73&zoom=3", &clusters))
+	assert.Equal(t, 2, clusters.Precision)
+	require.Len(t, clusters.Clusters, 1)
+	assert.Equal(t, 2, clusters.Clusters[0].Count)
+	assert.Equal(t, []string{"new-york", "philadelphia"}, clusters.Clusters[0].Sample)
+	require.Len(t, clusters.Sensors, 1)
+	assert.Equal(t, "pittsburgh", clusters.Sensors[0].Name)
+
+	var within []db.SensorDistance
+	assert.Equal(t, http.StatusOK, get("/sensor-metadata/geo/radius?lat=39.9526&lon=-75.1652&radius=150000", &within))
+	require.Len(t, within, 2)
+	assert.Equal(t, "new-york", within[1].Name)
+	assert.InDelta(t, 130_000, within[1].DistanceMeters, 2_000)
+
+	var nearest []db.SensorDistance
+	assert.Equal(t, http.StatusOK, get("/sensor-metadata/geo/nearest?lat=40.44&lon=-80&k=1", &nearest))
+	require.Len(t, nearest, 1)
+	assert.Equal(t, "pittsburgh", nearest[0].Name)
+
+	for _, url := range []string{
+		"/sensor-metadata/geo/bbox?min_lat=39&min_lon=-81&max_lat=42",
+		"/sensor-metadata/geo/bbox?min_lat=50&min_lon=-81&max_lat=42&max_lon=-74",
+		"/sensor-metadata/geo/clusters?min_lat=39&min_lon=-81&max_lat=42&max_lon=-74.5",
+		"/sensor-metadata/geo/clusters?min_lat=39&min_lon=-81&max_lat=42&max_lon=-74.5&zoom=23",
+		"/sensor-metadata/geo/clusters?min_lat=39&min_lon=-81&max_lat=42&max_lon=-74.5&zoom=3&sample=-1",
+		"/sensor-metadata/geo/clusters?min_lat=39&min_lon=-81&max_lat=95&max_lon=-74.5&zoom=3",
+		"/sensor-metadata/geo/radius?lat=39.9&lon=-75.1",
+		"/sensor-metadata/geo/radius?lat=95&lon=-75.1&radius=10",
+		"/sensor-metadata/geo/nearest?lat=40&lon=-80&k=many",
+		"/sensor-metadata/geo/nearest?lat=40&lon=-80&k=0",
+	} {
+		var problem Problem
+		assert.Equal(t, http.StatusBadRequest, get(url, &problem), url)
+		assert.Equal(t, CodeInvalidRequest, problem.Code, url)
+		assert.NotEmpty(t, problem.Detail, url)
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/geojson"
+	"strings"
+)
+
+// geoJSONSuffix selects the GeoJSON representation of a route.
+const geoJSONSuffix = ".geojson"
+
+// wantsGeoJSON reports whether the client asked for GeoJSON, through the
+// .geojson suffix of the route or the Accept header.
+func wantsGeoJSON(c *fiber.Ctx) bool {
+	if strings.HasSuffix(c.Path(), geoJSONSuffix) {
+		return true
+	}
+	return c.Accepts(fiber.MIMEApplicationJSON, geojson.MediaType) == geojson.MediaType
+}
+
+// sendGeoJSON writes a GeoJSON document. Unlike the JSON responses it is not
+// wrapped in the code/payload envelope, so map tools can read it as is.
+func sendGeoJSON(c *fiber.Ctx, status int, document interface{}) error {
+	if err := c.Status(status).JSON(document); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, geojson.MediaType)
+	return nil
+}
+
+// parseSensorBody reads a sensor from a JSON body, or from a GeoJSON Feature
+// when the request is sent as application/geo+json. It reports whether the
+// body set a location, which a Feature always does.
+func parseSensorBody(c *fiber.Ctx, sensor *db.SensorMetadata) (bool, error) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), geojson.MediaType) {
+		if err := c.BodyParser(sensor); err != nil {
+			return false, err
+		}
+		return jsonHasLocation(c.Body()) || sensor.Location != (db.Location{}), nil
+	}
+
+	var feature geojson.Feature
+	if err := json.Unmarshal(c.Body(), &feature); err != nil {
+		return false, err
+	}
+	parsed, err := feature.Sensor()
+	if err != nil {
+		return false, err
+	}
+	*sensor = *parsed
+	return true, nil
+}
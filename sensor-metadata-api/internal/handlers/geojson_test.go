@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/geojson"
+	"strings"
+	"testing"
+)
+
+func TestGeoJSONRepresentation(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata.geojson", ListSensorMetadataHandler(database))
+	app.Get("/sensor-metadata", ListSensorMetadataHandler(database))
+	app.Post("/sensor-metadata", CreateSensorMetadataHandler(database))
+	app.Get("/sensor-metadata/:name.geojson", GetSensorMetadataHandler(database))
+	app.Get("/sensor-metadata/:name", GetSensorMetadataHandler(database))
+
+	do := func(method, url, accept, contentType, body string) *http.Response {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Create_From_Feature", func(t *testing.T) {
+		resp := do(http.MethodPost, "/sensor-metadata", "", geojson.MediaType, `{
+			"type": "Feature",
+			"geometry": {"type": "Point", "coordinates": [13.3888599, 52.5170365]},
+			"properties": {"name": "berlin.1", "description": "Berlin", "tags": ["city"]}
+		}`)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		sensor, err := database.GetSensorMetadataByName("berlin.1")
+		require.NoError(t, err)
+		assert.Equal(t, db.Location{Latitude: 52.5170365, Longitude: 13.3888599}, sensor.Location)
+		assert.Equal(t, []string{"city"}, []string(sensor.Tags))
+	})
+
+	t.Run("Create_Rejects_Other_Geometries", func(t *testing.T) {
+		resp := do(http.MethodPost, "/sensor-metadata", "", geojson.MediaType, `{
+			"type": "Feature",
+			"geometry": {"type": "LineString", "coordinates": [[1, 2], [3, 4]]},
+			"properties": {"name": "line"}
+		}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Get_Feature", func(t *testing.T) {
+		for _, resp := range []*http.Response{
+			do(http.MethodGet, "/sensor-metadata/berlin.1", geojson.MediaType, "", ""),
+			do(http.MethodGet, "/sensor-metadata/berlin.1.geojson", "", "", ""),
+		} {
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, geojson.MediaType, resp.Header.Get("Content-Type"))
+
+			var feature geojson.Feature
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&feature))
+			assert.Equal(t, "Feature", feature.Type)
+			assert.Equal(t, []float64{13.3888599, 52.5170365}, feature.Geometry.Coordinates)
+			assert.Equal(t, "berlin.1", feature.Properties.Name)
+			assert.NotNil(t, feature.Properties.CreatedAt)
+		}
+
+		// plain JSON stays the default
+		resp := do(http.MethodGet, "/sensor-metadata/berlin.1", "*/*", "", "")
+		assert.Equal(t, fiber.MIMEApplicationJSON, resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("List_FeatureCollection", func(t *testing.T) {
+		for _, resp := range []*http.Response{
+			do(http.MethodGet, "/sensor-metadata?limit=1", geojson.MediaType, "", ""),
+			do(http.MethodGet, "/sensor-metadata.geojson?limit=1", "", "", ""),
+		} {
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var fc geojson.FeatureCollection
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&fc))
+			assert.Equal(t, "FeatureCollection", fc.Type)
+			require.Len(t, fc.Features, 1)
+			assert.Equal(t, "berlin.1", fc.Features[0].Properties.Name)
+			assert.NotEmpty(t, fc.Links["self"])
+		}
+	})
+}
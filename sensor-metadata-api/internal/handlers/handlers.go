@@ -2,11 +2,10 @@ package handlers
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
 	"net/http"
 	"sensor-metadata-api/internal/db"
 	_ "sensor-metadata-api/internal/db"
-	"strings"
+	"sensor-metadata-api/internal/geojson"
 	"time"
 )
 
@@ -14,38 +13,36 @@ import (
 // @Summary      Create a new sensor metadata
 // @Description  Create a new sensor metadata
 // @Tags         create
-// @Accept       json
+// @Accept       json,application/geo+json
 // @Produce      json
-// @Param        db_config.SensorMetadata   body     db.SensorMetadata   true    "SensorMetadata"
+// @Param        db_config.SensorMetadata   body     db.SensorMetadata   true    "SensorMetadata, or a GeoJSON Feature with a Point geometry"
+// @Param        Idempotency-Key   header     string   false    "Replays the response of an earlier request with the same key instead of running it again"
 // @Success      201  {object}   interface{}
-// @Failure      400  {object}  interface{}
-// @Failure      500  {object}  interface{}
+// @Failure      400  {object}  handlers.Problem
+// @Failure      409  {object}  handlers.Problem
+// @Failure      422  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
 // @Router       /sensor-metadata [post]
 func CreateSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var sensor db.SensorMetadata
-		if err := c.BodyParser(&sensor); err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-				"code":    http.StatusBadRequest,
-				"payload": map[string]string{"error": "invalid JSON"},
-			})
+		hasLocation, err := parseSensorBody(c, &sensor)
+		if err != nil {
+			if err == geojson.ErrInvalidFeature {
+				return badRequest(err.Error())
+			}
+			return invalidJSON()
 		}
 
-		if sensor.Name == "" || sensor.Location == (db.Location{}) {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-				"code":    http.StatusBadRequest,
-				"payload": map[string]string{"error": "sensor name and location are required"},
-			})
+		if err := validateSensor(&sensor, hasLocation); err != nil {
+			return err
 		}
 
 		sensor.CreatedAt = time.Now()
 		sensor.UpdatedAt = time.Now()
 
 		if err := database.CreateSensorMetadata(&sensor); err != nil {
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"code":    http.StatusInternalServerError,
-				"payload": map[string]string{"error": "failed to insert sensor metadata: " + err.Error()},
-			})
+			return err
 		}
 
 		return c.Status(http.StatusCreated).JSON(fiber.Map{
@@ -60,31 +57,36 @@ func CreateSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
 // @Description  Get info for a sensor
 // @Tags         get
 // @Accept       json
-// @Produce      json
+// @Produce      json,application/geo+json
 // @Param        name   path     string   true    "Sensor Name"
-// @Success      200  {object}   db.SensorMetadata
-// @Failure      404  {object}  interface{}
-// @Failure      500  {object}  interface{}
+// @Param        include_deleted   query     bool   false    "Also return a soft-deleted sensor"
+// @Param        If-None-Match   header     string   false    "ETag of the version the client holds"
+// @Param        If-Modified-Since   header     string   false    "Last-Modified of the version the client holds"
+// @Success      200  {object}   db.SensorMetadata  "A GeoJSON Feature when application/geo+json is accepted, or with the .geojson suffix"
+// @Header       200  {string}   ETag  "Current version of the sensor"
+// @Header       200  {string}   Last-Modified  "Time of the last change to the sensor"
+// @Success      304  "The sensor did not change"
+// @Failure      404  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
 // @Router       /sensor-metadata/{name} [get]
 func GetSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		sensorName := c.Params("name")
-		sn := strings.ToLower(sensorName)
-
-		sensor, err := database.GetSensorMetadataByName(sn)
+		sensor, err := findSensor(c, database, c.QueryBool("include_deleted"))
 		if err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return c.Status(http.StatusNotFound).JSON(fiber.Map{
-					"code":    http.StatusNotFound,
-					"payload": map[string]string{"error": "sensor metadata not found"},
-				})
-			}
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"code":    http.StatusInternalServerError,
-				"payload": map[string]string{"error": "failed to fetch sensor metadata"},
-			})
+			return err
 		}
 
+		geoJSON := wantsGeoJSON(c)
+		etag := sensorETag(sensor)
+		if geoJSON {
+			etag = renderingETag(etag, renderingGeoJSON)
+		}
+		if notModified(c, etag, sensor.UpdatedAt) {
+			return c.SendStatus(http.StatusNotModified)
+		}
+		if geoJSON {
+			return sendGeoJSON(c, http.StatusOK, geojson.FromSensor(sensor))
+		}
 		return c.Status(http.StatusOK).JSON(fiber.Map{
 			"code":    http.StatusOK,
 			"payload": sensor,
@@ -100,42 +102,39 @@ func GetSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
 // @Produce      json
 // @Param        name   path     string   true    "Sensor Name"
 // @Param        name   body     db.SensorMetadata   true    "SensorMetadata"
+// @Param        If-Match   header     string   false    "ETag of the version being updated"
 // @Success      200  {object}   interface{}
-// @Failure      404  {object}  interface{}
-// @Failure      400  {object}  interface{}
-// @Failure      500  {object}  interface{}
+// @Header       200  {string}   ETag  "New version of the sensor"
+// @Failure      404  {object}  handlers.Problem
+// @Failure      400  {object}  handlers.Problem
+// @Failure      412  {object}  handlers.Problem
+// @Failure      428  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
 // @Router       /sensor-metadata/{name} [put]
 func UpdateSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		sensorName := c.Params("name")
-		sensorName = strings.ToLower(sensorName)
-
-		sensor, err := database.GetSensorMetadataByName(sensorName)
+		sensor, err := findSensor(c, database, false)
 		if err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return c.Status(http.StatusNotFound).JSON(fiber.Map{
-					"code":    http.StatusNotFound,
-					"payload": map[string]string{"error": "sensor metadata not found"},
-				})
-			}
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"code":    http.StatusInternalServerError,
-				"payload": map[string]string{"error": "failed to fetch sensor metadata"},
-			})
+			return err
 		}
 
 		var updatedSensor db.SensorMetadata
 		if err := c.BodyParser(&updatedSensor); err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-				"code":    http.StatusBadRequest,
-				"payload": map[string]string{"error": "invalid JSON"},
-			})
+			return invalidJSON()
+		}
+		hasLocation := jsonHasLocation(c.Body()) || updatedSensor.Location != (db.Location{})
+		if err := validateSensorUpdate(&updatedSensor, hasLocation); err != nil {
+			return err
+		}
+
+		if !ifMatch(c, sensor) {
+			return db.ErrVersionConflict
 		}
 
 		if updatedSensor.Name != "" {
 			sensor.Name = updatedSensor.Name
 		}
-		if updatedSensor.Location != (db.Location{}) {
+		if hasLocation {
 			sensor.Location = updatedSensor.Location
 		}
 		if len(updatedSensor.Tags) > 0 {
@@ -144,13 +143,11 @@ func UpdateSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
 
 		sensor.UpdatedAt = time.Now()
 
-		if err = database.CreateSensorMetadata(sensor); err != nil {
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"code":    http.StatusInternalServerError,
-				"payload": map[string]string{"error": "failed to update sensor metadata"},
-			})
+		if err = database.UpdateSensorMetadata(sensor); err != nil {
+			return err
 		}
 
+		c.Set(fiber.HeaderETag, sensorETag(sensor))
 		return c.Status(http.StatusOK).JSON(fiber.Map{
 			"code":    http.StatusOK,
 			"payload": map[string]string{"message": "successfully updated sensor metadata"},
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"gorm.io/gorm"
@@ -33,11 +34,167 @@ func (m *MockSensorMetadataDB) GetSensorMetadataByName(name string) (*db.SensorM
 	return args.Get(0).(*db.SensorMetadata), nil
 }
 
+func (m *MockSensorMetadataDB) GetSensorMetadataByNameUnscoped(name string) (*db.SensorMetadata, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*db.SensorMetadata), nil
+}
+
+func (m *MockSensorMetadataDB) GetSensorMetadataByID(id uuid.UUID) (*db.SensorMetadata, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*db.SensorMetadata), nil
+}
+
+func (m *MockSensorMetadataDB) GetSensorMetadataByIDUnscoped(id uuid.UUID) (*db.SensorMetadata, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*db.SensorMetadata), nil
+}
+
+func (m *MockSensorMetadataDB) DeleteSensorMetadata(name string, version int) error {
+	args := m.Called(name, version)
+	return args.Error(0)
+}
+
+func (m *MockSensorMetadataDB) RestoreSensorMetadata(name string, version int) (*db.SensorMetadata, error) {
+	args := m.Called(name, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*db.SensorMetadata), nil
+}
+
+func (m *MockSensorMetadataDB) PurgeSensorMetadata(name string) error {
+	args := m.Called(name)
+	return args.Error(0)
+}
+
 func (m *MockSensorMetadataDB) UpdateSensorMetadata(sensor *db.SensorMetadata) error {
 	args := m.Called(sensor)
 	return args.Error(0)
 }
 
+func (m *MockSensorMetadataDB) ListSensorMetadata(opts db.ListOptions) (*db.SensorMetadataPage, error) {
+	args := m.Called(opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*db.SensorMetadataPage), nil
+}
+
+func (m *MockSensorMetadataDB) GetChangeToken() (db.ChangeToken, error) {
+	args := m.Called()
+	return args.Get(0).(db.ChangeToken), args.Error(1)
+}
+
+func (m *MockSensorMetadataDB) ListSensorRevisions(sensorID uuid.UUID) ([]db.SensorRevision, error) {
+	args := m.Called(sensorID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]db.SensorRevision), nil
+}
+
+func (m *MockSensorMetadataDB) ListRevisionsSince(seq int64, limit int) ([]db.SensorRevision, error) {
+	args := m.Called(seq, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]db.SensorRevision), nil
+}
+
+func (m *MockSensorMetadataDB) GetSensorRevision(sensorID uuid.UUID, revision int) (*db.SensorRevision, error) {
+	args := m.Called(sensorID, revision)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*db.SensorRevision), nil
+}
+
+func (m *MockSensorMetadataDB) RollbackSensorMetadata(sensorID uuid.UUID, revision int, version int) (*db.SensorMetadata, error) {
+	args := m.Called(sensorID, revision, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*db.SensorMetadata), nil
+}
+
+func (m *MockSensorMetadataDB) FindSensorMetadataInBox(box db.BoundingBox) ([]db.SensorMetadata, error) {
+	args := m.Called(box)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]db.SensorMetadata), nil
+}
+
+func (m *MockSensorMetadataDB) FindSensorMetadataWithinRadius(center db.Location, radius float64) ([]db.SensorDistance, error) {
+	args := m.Called(center, radius)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]db.SensorDistance), nil
+}
+
+func (m *MockSensorMetadataDB) SearchSensorMetadata(opts db.SearchOptions) ([]db.SearchResult, error) {
+	args := m.Called(opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]db.SearchResult), nil
+}
+
+func (m *MockSensorMetadataDB) GetSensorStats(opts db.StatsOptions) (*db.SensorStats, error) {
+	args := m.Called(opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*db.SensorStats), nil
+}
+
+func (m *MockSensorMetadataDB) FindNearestSensorMetadata(center db.Location, k int) ([]db.SensorDistance, error) {
+	args := m.Called(center, k)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]db.SensorDistance), nil
+}
+
+func (m *MockSensorMetadataDB) BulkWriteSensorMetadata(sensors []db.SensorMetadata, opts db.BulkOptions) ([]db.BulkResult, error) {
+	args := m.Called(sensors, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]db.BulkResult), nil
+}
+
+func (m *MockSensorMetadataDB) ResolveSensorAlias(name string) (*db.SensorMetadata, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*db.SensorMetadata), nil
+}
+
+func (m *MockSensorMetadataDB) ListSensorAliases(sensorID uuid.UUID) ([]db.SensorAlias, error) {
+	args := m.Called(sensorID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]db.SensorAlias), nil
+}
+
+func (m *MockSensorMetadataDB) ReleaseSensorAlias(sensorID uuid.UUID, name string) error {
+	args := m.Called(sensorID, name)
+	return args.Error(0)
+}
+
 func TestCreateSensorMetadataHandler_ValidInput(t *testing.T) {
 	// Create mock database
 	mockDB := new(MockSensorMetadataDB)
@@ -49,7 +206,7 @@ func TestCreateSensorMetadataHandler_ValidInput(t *testing.T) {
 	handler := CreateSensorMetadataHandler(mockDB)
 
 	// Create a new Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 
 	// Define the route and use the handler
 	app.Post("/sensor-metadata", handler)
@@ -88,7 +245,7 @@ func TestCreateSensorMetadataHandler_InvalidJSON(t *testing.T) {
 	handler := CreateSensorMetadataHandler(mockDB)
 
 	// Create a new Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 
 	// Define the route and use the handler
 	app.Post("/sensor-metadata", handler)
@@ -119,7 +276,7 @@ func TestCreateSensorMetadataHandler_MissingFields(t *testing.T) {
 	handler := CreateSensorMetadataHandler(mockDB)
 
 	// Create a new Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 
 	// Define the route and use the handler
 	app.Post("/sensor-metadata", handler)
@@ -155,7 +312,7 @@ func TestCreateSensorMetadataHandler_DatabaseError(t *testing.T) {
 	handler := CreateSensorMetadataHandler(mockDB)
 
 	// Create a new Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 
 	// Define the route and use the handler
 	app.Post("/sensor-metadata", handler)
@@ -196,7 +353,7 @@ func TestGetSensorMetadataHandler_Success(t *testing.T) {
 	handler := GetSensorMetadataHandler(mockDB)
 
 	// Create a new Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 
 	// Define the route and use the handler
 	app.Get("/sensor-metadata/:name", handler)
@@ -223,12 +380,13 @@ func TestGetSensorMetadataHandler_NotFound(t *testing.T) {
 
 	// Set expectations for not found scenario
 	mockDB.On("GetSensorMetadataByName", "unknownsensor").Return(nil, gorm.ErrRecordNotFound)
+	mockDB.On("ResolveSensorAlias", "unknownsensor").Return(nil, gorm.ErrRecordNotFound)
 
 	// Create handler instance with the mock database
 	handler := GetSensorMetadataHandler(mockDB)
 
 	// Create a new Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 
 	// Define the route and use the handler
 	app.Get("/sensor-metadata/:name", handler)
@@ -260,7 +418,7 @@ func TestGetSensorMetadataHandler_InternalServerError(t *testing.T) {
 	handler := GetSensorMetadataHandler(mockDB)
 
 	// Create a new Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 
 	// Define the route and use the handler
 	app.Get("/sensor-metadata/:name", handler)
@@ -300,10 +458,10 @@ func TestUpdateSensorMetadataHandler(t *testing.T) {
 
 		// Mock database method calls
 		mockDB.On("GetSensorMetadataByName", sensorName).Return(mockSensor, nil)
-		mockDB.On("CreateSensorMetadata", mock.Anything).Return(nil)
+		mockDB.On("UpdateSensorMetadata", mock.Anything).Return(nil)
 
 		// Create a new Fiber app
-		app := fiber.New()
+		app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 
 		// Define the route and use the handler
 		app.Put("/sensor-metadata/:name", handler)
@@ -332,7 +490,7 @@ func TestUpdateSensorMetadataHandler(t *testing.T) {
 		mockDB.On("GetSensorMetadataByName", sensorName).Return(nil, nil)
 
 		// Create a new Fiber app
-		app := fiber.New()
+		app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 
 		// Define the route and use the handler
 		app.Put("/sensor-metadata/:name", handler)
@@ -358,9 +516,10 @@ func TestUpdateSensorMetadataHandler(t *testing.T) {
 
 		// Mock database method call
 		mockDB.On("GetSensorMetadataByName", sensorName).Return(nil, gorm.ErrRecordNotFound)
+		mockDB.On("ResolveSensorAlias", sensorName).Return(nil, gorm.ErrRecordNotFound)
 
 		// Create a new Fiber app
-		app := fiber.New()
+		app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 
 		// Define the route and use the handler
 		app.Put("/sensor-metadata/:name", handler)
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"time"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed marks a response stored for an earlier request.
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	MaxIdempotencyKeyLength = 255
+
+	// idempotencyLockTimeout bounds how long a key stays claimed by a request
+	// that never finished, e.g. because the server stopped.
+	idempotencyLockTimeout = time.Minute
+)
+
+// idempotentHeaders are the response headers replayed along with the body.
+var idempotentHeaders = []string{fiber.HeaderContentType, fiber.HeaderETag, fiber.HeaderLocation}
+
+// Idempotency makes POST requests carrying an Idempotency-Key safe to retry.
+// The first request with a key runs as usual and its response is kept for
+// window; later requests with the same key and payload get that response back
+// without running again. Reusing a key for another payload is refused with 422.
+// Server errors are not kept, so the retry of a failed request runs anew. It
+// must be followed by RenderErrors to keep client errors.
+func Idempotency(store db.IdempotencyStore, window time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		if c.Method() != fiber.MethodPost || key == "" {
+			return c.Next()
+		}
+		if len(key) > MaxIdempotencyKeyLength {
+			return badRequest("Idempotency-Key must be at most 255 characters")
+		}
+
+		record := &db.IdempotencyRecord{
+			Key:         key,
+			Fingerprint: requestFingerprint(c),
+			ExpiresAt:   time.Now().Add(idempotencyLockTimeout),
+		}
+		stored, err := store.ReserveIdempotencyKey(record)
+		if err != nil {
+			return err
+		}
+		if stored != nil {
+			return replayResponse(c, stored, record.Fingerprint)
+		}
+
+		// errors come back rendered by RenderErrors, which sits behind
+		err = c.Next()
+		resp := c.Response()
+		if err != nil || resp.StatusCode() >= http.StatusInternalServerError {
+			_ = store.ReleaseIdempotencyKey(key)
+			return err
+		}
+
+		record.Status = resp.StatusCode()
+		record.Header = make(map[string]string)
+		for _, h := range idempotentHeaders {
+			if v := resp.Header.Peek(h); len(v) > 0 {
+				record.Header[h] = string(v)
+			}
+		}
+		record.Body = append([]byte(nil), resp.Body()...)
+		record.ExpiresAt = time.Now().Add(window)
+		// the request already succeeded; if its response cannot be kept a retry
+		// waits for the claim to time out and runs again
+		_ = store.SaveIdempotencyResponse(record)
+
+		return err
+	}
+}
+
+// replayResponse sends the response stored for an earlier request with the same
+// key, once it is known to be for the same payload.
+func replayResponse(c *fiber.Ctx, stored *db.IdempotencyRecord, fingerprint string) error {
+	if stored.Fingerprint != fingerprint {
+		return NewProblem(http.StatusUnprocessableEntity, CodeIdempotencyKeyReused,
+			"the Idempotency-Key was already used for a different request")
+	}
+	if stored.Status == 0 {
+		return NewProblem(http.StatusConflict, CodeIdempotencyKeyInProgress,
+			"a request with this Idempotency-Key is still being processed, retry later")
+	}
+
+	for h, v := range stored.Header {
+		c.Set(h, v)
+	}
+	c.Set(HeaderIdempotentReplayed, "true")
+	return c.Status(stored.Status).Send(stored.Body)
+}
+
+// requestFingerprint hashes what makes two requests the same one: the target,
+// the media type and the body.
+func requestFingerprint(c *fiber.Ctx) string {
+	h := sha256.New()
+	for _, part := range [][]byte{
+		[]byte(c.Method()),
+		[]byte(c.Path()),
+		c.Request().URI().QueryString(),
+		[]byte(c.Get(fiber.HeaderContentType)),
+		c.Body(),
+	} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
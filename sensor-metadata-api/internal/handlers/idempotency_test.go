@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotency(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(Idempotency(database, time.Hour), RenderErrors())
+	app.Post("/sensor-metadata", CreateSensorMetadataHandler(database))
+	failures := 0
+	app.Post("/flaky", func(c *fiber.Ctx) error {
+		if failures++; failures == 1 {
+			return errors.New("connection reset")
+		}
+		return c.SendStatus(http.StatusCreated)
+	})
+	started, release := make(chan struct{}), make(chan struct{})
+	app.Post("/slow", func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return c.SendStatus(http.StatusCreated)
+	})
+
+	send := func(url, key, body string) (*http.Response, string) {
+		req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(HeaderIdempotencyKey, key)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		out, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body = io.NopCloser(bytes.NewReader(out))
+		return resp, string(out)
+	}
+	sensor := `{"name": "sensor-1", "location": {"latitude": 1, "longitude": 2}}`
+
+	t.Run("Replay", func(t *testing.T) {
+		first, firstBody := send("/sensor-metadata", "key-1", sensor)
+		require.Equal(t, http.StatusCreated, first.StatusCode)
+		assert.Empty(t, first.Header.Get(HeaderIdempotentReplayed))
+
+		again, againBody := send("/sensor-metadata", "key-1", sensor)
+		assert.Equal(t, http.StatusCreated, again.StatusCode)
+		assert.Equal(t, "true", again.Header.Get(HeaderIdempotentReplayed))
+		assert.Equal(t, first.Header.Get(fiber.HeaderContentType), again.Header.Get(fiber.HeaderContentType))
+		assert.Equal(t, firstBody, againBody)
+
+		created, err := database.GetSensorMetadataByName("sensor-1")
+		require.NoError(t, err)
+		revisions, err := database.ListSensorRevisions(created.ID)
+		require.NoError(t, err)
+		assert.Len(t, revisions, 1, "the replay must not run the request again")
+	})
+
+	t.Run("Without_Key", func(t *testing.T) {
+		resp, _ := send("/sensor-metadata", "", sensor)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("Key_Reused_For_Other_Payload", func(t *testing.T) {
+		resp, _ := send("/sensor-metadata", "key-1", `{"name": "sensor-2", "location": {"latitude": 1, "longitude": 2}}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, CodeIdempotencyKeyReused, decodeProblem(t, resp).Code)
+	})
+
+	t.Run("Client_Errors_Are_Kept", func(t *testing.T) {
+		first, _ := send("/sensor-metadata", "key-2", sensor)
+		require.Equal(t, http.StatusConflict, first.StatusCode)
+
+		again, _ := send("/sensor-metadata", "key-2", sensor)
+		assert.Equal(t, http.StatusConflict, again.StatusCode)
+		assert.Equal(t, "true", again.Header.Get(HeaderIdempotentReplayed))
+		assert.Equal(t, ProblemMediaType, again.Header.Get(fiber.HeaderContentType))
+	})
+
+	t.Run("Server_Errors_Are_Retried", func(t *testing.T) {
+		first, _ := send("/flaky", "key-3", "")
+		require.Equal(t, http.StatusInternalServerError, first.StatusCode)
+
+		again, _ := send("/flaky", "key-3", "")
+		assert.Equal(t, http.StatusCreated, again.StatusCode)
+		assert.Empty(t, again.Header.Get(HeaderIdempotentReplayed))
+		assert.Equal(t, 2, failures)
+	})
+
+	t.Run("Request_In_Progress", func(t *testing.T) {
+		done := make(chan int)
+		go func() {
+			resp, _ := send("/slow", "key-4", "")
+			done <- resp.StatusCode
+		}()
+		<-started
+
+		resp, _ := send("/slow", "key-4", "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, CodeIdempotencyKeyInProgress, decodeProblem(t, resp).Code)
+
+		close(release)
+		assert.Equal(t, http.StatusCreated, <-done)
+	})
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/geojson"
+	"sensor-metadata-api/internal/tagquery"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListSensorMetadataHandler godoc
+// @Summary      List sensors
+// @Description  List sensors page by page. Pass the returned next_cursor, or follow links.next, to get the following page. As text/csv, or on /sensor-metadata.csv, every matching sensor is exported at once and limit and cursor are ignored.
+// @Tags         list
+// @Accept       json
+// @Produce      json,application/geo+json,text/csv
+// @Param        limit           query    int      false   "Page size (default 50, max 500)"
+// @Param        cursor          query    string   false   "Opaque cursor from a previous page"
+// @Param        sort            query    string   false   "name, created_at or updated_at, prefixed with - for descending order"
+// @Param        tags            query    string   false   "Comma separated tags a sensor must all carry"
+// @Param        tags_any        query    string   false   "Comma separated tags a sensor must carry at least one of"
+// @Param        tags_none       query    string   false   "Comma separated tags a sensor must not carry"
+// @Param        selector        query    string   false   "Label selector over key=value tags, e.g. env in (prod,staging), !deprecated, floor=3"
+// @Param        name_prefix     query    string   false   "Sensor name prefix"
+// @Param        created_after   query    string   false   "RFC 3339 time, inclusive"
+// @Param        created_before  query    string   false   "RFC 3339 time, exclusive"
+// @Param        updated_after   query    string   false   "RFC 3339 time, inclusive"
+// @Param        updated_before  query    string   false   "RFC 3339 time, exclusive"
+// @Param        include_deleted query    bool     false   "Also list soft-deleted sensors"
+// @Param        If-None-Match   header   string   false   "ETag of a listing the client holds"
+// @Success      200  {object}  interface{}  "A GeoJSON FeatureCollection when application/geo+json is accepted, or on /sensor-metadata.geojson"
+// @Header       200  {string}  ETag  "Changes whenever any sensor does"
+// @Success      304  "No sensor changed"
+// @Failure      400  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata [get]
+func ListSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		opts, err := parseListOptions(c)
+		if err != nil {
+			return badRequest(err.Error())
+		}
+
+		// taken before listing, so a change made meanwhile is seen by the next request
+		token, err := database.GetChangeToken()
+		if err != nil {
+			return err
+		}
+		rendering := ""
+		switch {
+		case wantsCSV(c):
+			rendering = renderingCSV
+		case wantsGeoJSON(c):
+			rendering = renderingGeoJSON
+		}
+		if notModified(c, renderingETag(changeETag(token), rendering), time.Time{}) {
+			return c.SendStatus(http.StatusNotModified)
+		}
+
+		if rendering == renderingCSV {
+			return exportCSV(c, database, opts)
+		}
+
+		page, err := database.ListSensorMetadata(opts)
+		if err != nil {
+			return err
+		}
+
+		links := map[string]string{"self": pageLink(c, opts.Cursor)}
+		if page.NextCursor != "" {
+			links["next"] = pageLink(c, page.NextCursor)
+		}
+
+		if rendering == renderingGeoJSON {
+			fc := geojson.FromSensors(page.Items)
+			fc.NextCursor = page.NextCursor
+			fc.Links = links
+			return sendGeoJSON(c, http.StatusOK, fc)
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code": http.StatusOK,
+			"payload": fiber.Map{
+				"items":       page.Items,
+				"next_cursor": page.NextCursor,
+				"links":       links,
+			},
+		})
+	}
+}
+
+// parseListOptions reads the list query parameters.
+func parseListOptions(c *fiber.Ctx) (db.ListOptions, error) {
+	opts := db.ListOptions{
+		Cursor:     c.Query("cursor"),
+		NamePrefix: c.Query("name_prefix"),
+		Tags:       splitList(c.Query("tags")),
+		TagsAny:    splitList(c.Query("tags_any")),
+		TagsNone:   splitList(c.Query("tags_none")),
+
+		IncludeDeleted: c.QueryBool("include_deleted"),
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return opts, errors.New("limit must be a positive integer")
+		}
+		opts.Limit = limit
+	}
+
+	if v := c.Query("sort"); v != "" {
+		opts.Descending = strings.HasPrefix(v, "-")
+		opts.SortBy = strings.TrimPrefix(v, "-")
+		switch opts.SortBy {
+		case db.SortByName, db.SortByCreatedAt, db.SortByUpdatedAt:
+		default:
+			return opts, errors.New("sort must be one of name, created_at, updated_at")
+		}
+	}
+
+	var err error
+	if v := strings.TrimSpace(c.Query("selector")); v != "" {
+		if opts.Selector, err = tagquery.Parse(v); err != nil {
+			return opts, err
+		}
+	}
+
+	for param, dst := range map[string]**time.Time{
+		"created_after":  &opts.CreatedAfter,
+		"created_before": &opts.CreatedBefore,
+		"updated_after":  &opts.UpdatedAfter,
+		"updated_before": &opts.UpdatedBefore,
+	} {
+		if *dst, err = queryTime(c, param); err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
+// queryTime parses an optional RFC 3339 query parameter.
+func queryTime(c *fiber.Ctx, param string) (*time.Time, error) {
+	v := c.Query(param)
+	if v == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return nil, errors.New(param + " must be an RFC 3339 time")
+	}
+	return &t, nil
+}
+
+// splitList splits a comma separated query parameter, dropping empty entries.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// pageLink returns the current request URL with the cursor replaced.
+func pageLink(c *fiber.Ctx, cursor string) string {
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+
+	c.Request().URI().QueryArgs().CopyTo(args)
+	if cursor == "" {
+		args.Del("cursor")
+	} else {
+		args.Set("cursor", cursor)
+	}
+
+	if args.Len() == 0 {
+		return c.Path()
+	}
+	return c.Path() + "?" + args.String()
+}
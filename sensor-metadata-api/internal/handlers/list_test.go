@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sensor-metadata-api/internal/db"
+	"testing"
+)
+
+type listResponse struct {
+	Code    int `json:"code"`
+	Payload struct {
+		Items      []db.SensorMetadata `json:"items"`
+		NextCursor string              `json:"next_cursor"`
+		Links      map[string]string   `json:"links"`
+	} `json:"payload"`
+}
+
+func getList(t *testing.T, app *fiber.App, url string) (int, listResponse) {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+
+	var body listResponse
+	if resp.StatusCode >= http.StatusBadRequest {
+		decodeProblem(t, resp)
+		return resp.StatusCode, body
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body
+}
+
+func TestListSensorMetadataHandler(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{
+			Name:        fmt.Sprintf("sensor-%d", i),
+			Description: fmt.Sprintf("sensor %d", i),
+			Location:    db.Location{Latitude: 40.0, Longitude: -80.0},
+			Tags:        []string{fmt.Sprintf("tag%d", i%2)},
+		}))
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata", ListSensorMetadataHandler(database))
+
+	t.Run("Follow_Next_Links", func(t *testing.T) {
+		var names []string
+		url := "/sensor-metadata?limit=2&sort=-name"
+		for url != "" {
+			status, body := getList(t, app, url)
+			require.Equal(t, http.StatusOK, status)
+			for _, s := range body.Payload.Items {
+				names = append(names, s.Name)
+			}
+			url = body.Payload.Links["next"]
+		}
+
+		assert.Equal(t, []string{"sensor-4", "sensor-3", "sensor-2", "sensor-1", "sensor-0"}, names)
+	})
+
+	t.Run("Filter_By_Tags", func(t *testing.T) {
+		status, body := getList(t, app, "/sensor-metadata?tags=tag1")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Len(t, body.Payload.Items, 2)
+		assert.Empty(t, body.Payload.Links["next"])
+		assert.Equal(t, "/sensor-metadata?tags=tag1", body.Payload.Links["self"])
+	})
+
+	t.Run("Filter_By_Selector", func(t *testing.T) {
+		status, body := getList(t, app, "/sensor-metadata?tags_any=tag0,tag2&tags_none=tag1")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Len(t, body.Payload.Items, 3)
+
+		status, body = getList(t, app, "/sensor-metadata?selector="+url.QueryEscape("tag0, !tag1"))
+		assert.Equal(t, http.StatusOK, status)
+		assert.Len(t, body.Payload.Items, 3)
+	})
+
+	t.Run("Bad_Parameters", func(t *testing.T) {
+		for _, url := range []string{
+			"/sensor-metadata?limit=zero",
+			"/sensor-metadata?sort=description",
+			"/sensor-metadata?created_after=yesterday",
+			"/sensor-metadata?cursor=garbage",
+			"/sensor-metadata?selector=env+in+prod",
+		} {
+			status, _ := getList(t, app, url)
+			assert.Equal(t, http.StatusBadRequest, status, url)
+		}
+	})
+}
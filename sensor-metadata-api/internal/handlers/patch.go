@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/patch"
+	"strings"
+	"time"
+)
+
+// patchDocument is the JSON document patches apply to: the fields of a sensor
+// a client may change. Every member is present, so tags can be appended to and
+// the location replaced member by member.
+type patchDocument struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Location    *patchLocation `json:"location"`
+	Tags        []string       `json:"tags"`
+}
+
+// patchLocation keeps the coordinates as pointers so a removed one is told
+// apart from a zero one.
+type patchLocation struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// PatchSensorMetadataHandler godoc
+// @Summary      Patch sensor metadata
+// @Description  Change the name, description, location or tags of a sensor with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902). Unlike PUT, a patch can clear tags, empty the description or move a sensor to 0. The patch applies as a whole or not at all.
+// @Tags         update
+// @Accept       application/merge-patch+json,application/json-patch+json
+// @Produce      json
+// @Param        name   path     string   true    "Sensor Name"
+// @Param        patch  body     object   true    "Merge patch object, or array of JSON Patch operations"
+// @Param        If-Match   header     string   false    "ETag of the version being patched"
+// @Success      200  {object}  db.SensorMetadata
+// @Header       200  {string}   ETag  "New version of the sensor"
+// @Failure      400  {object}  handlers.Problem
+// @Failure      404  {object}  handlers.Problem
+// @Failure      409  {object}  handlers.Problem
+// @Failure      412  {object}  handlers.Problem
+// @Failure      415  {object}  handlers.Problem
+// @Failure      428  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/{name} [patch]
+func PatchSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sensor, err := findSensor(c, database, false)
+		if err != nil {
+			return err
+		}
+		if !ifMatch(c, sensor) {
+			return db.ErrVersionConflict
+		}
+
+		doc, err := json.Marshal(toPatchDocument(sensor))
+		if err != nil {
+			return err
+		}
+
+		mediaType, _, _ := strings.Cut(c.Get(fiber.HeaderContentType), ";")
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case patch.MergePatchMediaType:
+			doc, err = patch.Merge(doc, c.Body())
+		case patch.JSONPatchMediaType:
+			doc, err = patch.Apply(doc, c.Body())
+		default:
+			return NewProblem(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+				"a patch is sent as "+patch.MergePatchMediaType+" or "+patch.JSONPatchMediaType)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = applyPatchDocument(doc, sensor); err != nil {
+			return err
+		}
+		sensor.UpdatedAt = time.Now()
+
+		if err = database.UpdateSensorMetadata(sensor); err != nil {
+			return err
+		}
+
+		c.Set(fiber.HeaderETag, sensorETag(sensor))
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": sensor,
+		})
+	}
+}
+
+func toPatchDocument(sensor *db.SensorMetadata) patchDocument {
+	lat, lon := sensor.Location.Latitude, sensor.Location.Longitude
+	tags := []string(sensor.Tags)
+	if tags == nil {
+		tags = []string{}
+	}
+	return patchDocument{
+		Name:        sensor.Name,
+		Description: sensor.Description,
+		Location:    &patchLocation{Latitude: &lat, Longitude: &lon},
+		Tags:        tags,
+	}
+}
+
+// applyPatchDocument copies a patched document onto sensor, once it passes
+// the rules of a new sensor.
+func applyPatchDocument(doc []byte, sensor *db.SensorMetadata) error {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+
+	var patched patchDocument
+	if err := dec.Decode(&patched); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return validationFailed([]FieldError{{Field: typeErr.Field, Message: "has the wrong type"}})
+		}
+		// only the members of patchDocument can be patched
+		return badRequest("the patched sensor is invalid: " + strings.TrimPrefix(err.Error(), "json: "))
+	}
+
+	next := *sensor
+	next.Name = patched.Name
+	next.Description = patched.Description
+	next.Tags = patched.Tags
+
+	hasLocation := patched.Location != nil
+	var fields []FieldError
+	if hasLocation {
+		if patched.Location.Latitude == nil {
+			fields = append(fields, FieldError{Field: "location.latitude", Message: "is required"})
+		} else {
+			next.Location.Latitude = *patched.Location.Latitude
+		}
+		if patched.Location.Longitude == nil {
+			fields = append(fields, FieldError{Field: "location.longitude", Message: "is required"})
+		} else {
+			next.Location.Longitude = *patched.Location.Longitude
+		}
+	}
+	fields = append(fields, checkSensor(&next, hasLocation, false)...)
+	if err := fieldErrors(fields); err != nil {
+		return err
+	}
+
+	*sensor = next
+	return nil
+}
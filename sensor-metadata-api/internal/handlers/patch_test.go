@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/patch"
+	"strings"
+	"testing"
+)
+
+func TestPatchSensorMetadataHandler(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{
+		Name:        "sensor-1",
+		Description: "roof",
+		Location:    db.Location{Latitude: 40.0, Longitude: -80.0},
+		Tags:        []string{"a", "b"},
+	}))
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Patch("/sensor-metadata/:name", PatchSensorMetadataHandler(database))
+
+	send := func(contentType, body string, header ...string) *http.Response {
+		req := httptest.NewRequest(http.MethodPatch, "/sensor-metadata/sensor-1", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+	current := func() *db.SensorMetadata {
+		sensor, err := database.GetSensorMetadataByName("sensor-1")
+		require.NoError(t, err)
+		return sensor
+	}
+
+	t.Run("Merge_Patch", func(t *testing.T) {
+		resp := send(patch.MergePatchMediaType, `{"description": null, "tags": [], "location": {"latitude": 0}}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"2"`, resp.Header.Get(fiber.HeaderETag))
+
+		var body struct {
+			Payload db.SensorMetadata `json:"payload"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "", body.Payload.Description)
+		assert.Empty(t, body.Payload.Tags)
+		assert.Equal(t, db.Location{Latitude: 0, Longitude: -80.0}, body.Payload.Location)
+		assert.Equal(t, body.Payload.Location, current().Location)
+	})
+
+	t.Run("JSON_Patch", func(t *testing.T) {
+		resp := send(patch.JSONPatchMediaType, `[
+			{"op": "test", "path": "/location/latitude", "value": 0},
+			{"op": "add", "path": "/tags/-", "value": "floor=3"},
+			{"op": "replace", "path": "/location", "value": {"latitude": 1.5, "longitude": 2.5}}
+		]`, fiber.HeaderIfMatch, `"2"`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		sensor := current()
+		assert.Equal(t, []string{"floor=3"}, []string(sensor.Tags))
+		assert.Equal(t, db.Location{Latitude: 1.5, Longitude: 2.5}, sensor.Location)
+		assert.Equal(t, 3, sensor.Version)
+	})
+
+	t.Run("Failed_Test_Applies_Nothing", func(t *testing.T) {
+		resp := send(patch.JSONPatchMediaType, `[
+			{"op": "remove", "path": "/tags/0"},
+			{"op": "test", "path": "/name", "value": "sensor-2"}
+		]`)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, CodePatchTestFailed, decodeProblem(t, resp).Code)
+		assert.Equal(t, []string{"floor=3"}, []string(current().Tags))
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		tests := map[string]struct {
+			contentType string
+			body        string
+			ifMatch     string
+			status      int
+			code        string
+		}{
+			"Plain_JSON":         {fiber.MIMEApplicationJSON, `{}`, "", http.StatusUnsupportedMediaType, CodeUnsupportedMediaType},
+			"Bad_Pointer":        {patch.JSONPatchMediaType, `[{"op": "remove", "path": "/tags/9"}]`, "", http.StatusBadRequest, CodeInvalidPatch},
+			"Read_Only_Field":    {patch.MergePatchMediaType, `{"version": 7}`, "", http.StatusBadRequest, CodeInvalidRequest},
+			"Remove_Location":    {patch.JSONPatchMediaType, `[{"op": "remove", "path": "/location/longitude"}]`, "", http.StatusBadRequest, CodeValidationFailed},
+			"Out_Of_Range":       {patch.MergePatchMediaType, `{"location": {"latitude": 91}}`, "", http.StatusBadRequest, CodeValidationFailed},
+			"Wrong_Type":         {patch.MergePatchMediaType, `{"tags": "a"}`, "", http.StatusBadRequest, CodeValidationFailed},
+			"Stale_Precondition": {patch.MergePatchMediaType, `{"description": "x"}`, `"1"`, http.StatusPreconditionFailed, CodeVersionMismatch},
+		}
+
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				var resp *http.Response
+				if tt.ifMatch != "" {
+					resp = send(tt.contentType, tt.body, fiber.HeaderIfMatch, tt.ifMatch)
+				} else {
+					resp = send(tt.contentType, tt.body)
+				}
+				assert.Equal(t, tt.status, resp.StatusCode)
+				assert.Equal(t, tt.code, decodeProblem(t, resp).Code)
+			})
+		}
+		assert.Equal(t, 3, current().Version)
+	})
+}
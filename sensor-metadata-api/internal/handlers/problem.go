@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/logger"
+	"sensor-metadata-api/internal/patch"
+	"sensor-metadata-api/internal/sensorcsv"
+	"strings"
+)
+
+// ProblemMediaType is the media type of RFC 7807 error responses.
+const ProblemMediaType = "application/problem+json"
+
+// problemTypePrefix turns a problem code into its type URI.
+const problemTypePrefix = "urn:sensor-metadata:problem:"
+
+// Problem codes. They are part of the API and must not change.
+const (
+	CodeInvalidRequest           = "invalid-request"
+	CodeInvalidJSON              = "invalid-json"
+	CodeInvalidPatch             = "invalid-patch"
+	CodePatchTestFailed          = "patch-test-failed"
+	CodeUnsupportedMediaType     = "unsupported-media-type"
+	CodeValidationFailed         = "validation-failed"
+	CodeUnauthorized             = "unauthorized"
+	CodeNotFound                 = "not-found"
+	CodeSensorNotFound           = "sensor-not-found"
+	CodeRevisionNotFound         = "revision-not-found"
+	CodeAliasNotFound            = "alias-not-found"
+	CodeDuplicateName            = "duplicate-name"
+	CodeConflict                 = "conflict"
+	CodeNameReserved             = "name-reserved"
+	CodeSensorDeleted            = "sensor-deleted"
+	CodeIdempotencyKeyReused     = "idempotency-key-reused"
+	CodeIdempotencyKeyInProgress = "idempotency-key-in-progress"
+	CodeVersionMismatch          = "version-mismatch"
+	CodePreconditionRequired     = "precondition-required"
+	CodeInternal                 = "internal-error"
+)
+
+// Problem is an RFC 7807 problem details document. Handlers return it as their
+// error and ErrorHandler renders it.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError points at one invalid field of a request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// StatusCode is the status the problem is sent with.
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+// NewProblem builds the problem for an HTTP status and one of the problem codes.
+func NewProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   problemTypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// badRequest reports a malformed query parameter or body.
+func badRequest(detail string) *Problem {
+	return NewProblem(http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+// invalidJSON reports a body that could not be decoded.
+func invalidJSON() *Problem {
+	return NewProblem(http.StatusBadRequest, CodeInvalidJSON, "invalid JSON")
+}
+
+// ErrorHandler renders the errors returned by handlers as problem+json. Errors
+// that are not a Problem are mapped from the storage errors they wrap; anything
+// unknown becomes a 500 without leaking its text. A sensor addressed by a
+// former name is redirected instead.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var redirect *aliasRedirect
+	if errors.As(err, &redirect) {
+		status := redirect.status(c.Method())
+		c.Location(redirect.Location)
+		return c.Status(status).JSON(fiber.Map{
+			"code":    status,
+			"payload": map[string]string{"message": "sensor metadata was renamed", "location": redirect.Location},
+		})
+	}
+
+	p := *toProblem(err)
+	p.Instance = c.Path()
+	if id, ok := c.Locals(logger.RequestIdCtxKey).(string); ok {
+		p.RequestID = id
+	}
+
+	if err := c.Status(p.Status).JSON(&p); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, ProblemMediaType)
+	return nil
+}
+
+// RenderErrors renders the error returned by the rest of the chain with
+// ErrorHandler as soon as it comes back, so the middlewares in front of it,
+// like Idempotency, see the response that is sent. It is the one place the
+// errors of the API are rendered; the errors of the middlewares in front of
+// it are left to the app's ErrorHandler.
+func RenderErrors() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if err == nil {
+			return nil
+		}
+		logger.SetErrorForRequest(c, err)
+		return ErrorHandler(c, err)
+	}
+}
+
+func toProblem(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	// the fields a bulk write found missing, reported as a single create would
+	var incomplete *db.IncompleteSensorError
+	if errors.As(err, &incomplete) {
+		fields := make([]FieldError, 0, len(incomplete.Fields))
+		for _, f := range incomplete.Fields {
+			fields = append(fields, FieldError{Field: f, Message: "is required"})
+		}
+		return validationFailed(fields)
+	}
+
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		code := CodeInvalidRequest
+		switch {
+		case fe.Code == http.StatusNotFound:
+			code = CodeNotFound
+		case fe.Code >= http.StatusInternalServerError:
+			code = CodeInternal
+		}
+		return NewProblem(fe.Code, code, fe.Message)
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NewProblem(http.StatusNotFound, CodeNotFound, "resource not found")
+	case errors.Is(err, db.ErrDuplicateName):
+		return NewProblem(http.StatusConflict, CodeDuplicateName, "a sensor with this name already exists")
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		// another unique constraint, like the one on descriptions
+		return NewProblem(http.StatusConflict, CodeConflict, "a value of the request must be unique and is already taken")
+	case errors.Is(err, db.ErrNameReserved):
+		return NewProblem(http.StatusConflict, CodeNameReserved, "this name is still an alias of another sensor, it must be released first")
+	case errors.Is(err, db.ErrSensorDeleted):
+		return NewProblem(http.StatusConflict, CodeSensorDeleted, err.Error())
+	case errors.Is(err, db.ErrVersionConflict):
+		return NewProblem(http.StatusPreconditionFailed, CodeVersionMismatch, "sensor metadata was modified, fetch it again and retry")
+	case errors.Is(err, patch.ErrTestFailed):
+		return NewProblem(http.StatusConflict, CodePatchTestFailed, err.Error())
+	case errors.Is(err, patch.ErrInvalidPatch):
+		return NewProblem(http.StatusBadRequest, CodeInvalidPatch, err.Error())
+	case errors.Is(err, db.ErrInvalidCursor),
+		errors.Is(err, db.ErrInvalidSort),
+		errors.Is(err, db.ErrInvalidGeoQuery),
+		errors.Is(err, db.ErrInvalidBulkMode),
+		errors.Is(err, db.ErrTooManyItems),
+		errors.Is(err, sensorcsv.ErrInvalidMapping):
+		return badRequest(err.Error())
+	}
+
+	return NewProblem(http.StatusInternalServerError, CodeInternal, "the request could not be processed")
+}
+
+// validationFailed reports a body that decoded but breaks the sensor rules.
+func validationFailed(fields []FieldError) *Problem {
+	msgs := make([]string, 0, len(fields))
+	for _, f := range fields {
+		msgs = append(msgs, f.Field+" "+f.Message)
+	}
+	p := NewProblem(http.StatusBadRequest, CodeValidationFailed, strings.Join(msgs, "; "))
+	p.Errors = fields
+	return p
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"strings"
+	"testing"
+)
+
+// decodeProblem reads a problem+json response body.
+func decodeProblem(t *testing.T, resp *http.Response) Problem {
+	t.Helper()
+
+	assert.Equal(t, ProblemMediaType, resp.Header.Get(fiber.HeaderContentType))
+	var p Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+	assert.Equal(t, resp.StatusCode, p.Status)
+	assert.Equal(t, problemTypePrefix+p.Code, p.Type)
+	return p
+}
+
+func TestProblemResponses(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(requestid.New())
+	app.Post("/sensor-metadata", CreateSensorMetadataHandler(database))
+	app.Get("/sensor-metadata/:name", GetSensorMetadataHandler(database))
+
+	post := func(body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/sensor-metadata", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Duplicate_Name", func(t *testing.T) {
+		body := `{"name": "sensor-1", "location": {"latitude": 1, "longitude": 2}}`
+		require.Equal(t, http.StatusCreated, post(body).StatusCode)
+
+		resp := post(body)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		p := decodeProblem(t, resp)
+		assert.Equal(t, CodeDuplicateName, p.Code)
+		assert.Equal(t, "/sensor-metadata", p.Instance)
+		assert.NotEmpty(t, p.RequestID)
+		assert.Equal(t, resp.Header.Get(fiber.HeaderXRequestID), p.RequestID)
+	})
+
+	t.Run("Other_Unique_Constraint", func(t *testing.T) {
+		// the empty description of sensor-1, under a free name
+		body := `{"name": "sensor-2", "location": {"latitude": 1, "longitude": 2}}`
+		resp := post(body)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, CodeConflict, decodeProblem(t, resp).Code)
+	})
+
+	t.Run("Field_Errors", func(t *testing.T) {
+		resp := post(`{"description": "nameless"}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		p := decodeProblem(t, resp)
+		assert.Equal(t, CodeValidationFailed, p.Code)
+		assert.Equal(t, []FieldError{
+			{Field: "name", Message: "is required"},
+			{Field: "location", Message: "is required"},
+		}, p.Errors)
+	})
+
+	t.Run("Invalid_JSON", func(t *testing.T) {
+		resp := post(`{"name": `)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, CodeInvalidJSON, decodeProblem(t, resp).Code)
+	})
+
+	t.Run("Sensor_Not_Found", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/sensor-metadata/missing", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, CodeSensorNotFound, decodeProblem(t, resp).Code)
+	})
+
+	t.Run("Unknown_Route", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, CodeNotFound, decodeProblem(t, resp).Code)
+	})
+}
+
+func TestProblemHidesInternalErrors(t *testing.T) {
+	mockDB := new(MockSensorMetadataDB)
+	mockDB.On("CreateSensorMetadata", mock.Anything).Return(errors.New("pq: connection refused"))
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/sensor-metadata", CreateSensorMetadataHandler(mockDB))
+
+	req := httptest.NewRequest(http.MethodPost, "/sensor-metadata",
+		strings.NewReader(`{"name": "sensor-1", "location": {"latitude": 1, "longitude": 2}}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "connection refused")
+	assert.Contains(t, string(raw), `"code":"`+CodeInternal+`"`)
+	mockDB.AssertExpectations(t)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"strconv"
+)
+
+// ListSensorRevisionsHandler godoc
+// @Summary      List the revisions of a sensor
+// @Description  List every recorded revision of a sensor, oldest first. Soft-deleted sensors are included.
+// @Tags         revisions
+// @Accept       json
+// @Produce      json
+// @Param        name   path     string   true    "Sensor Name"
+// @Success      200  {array}   db.SensorRevision
+// @Failure      404  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/{name}/revisions [get]
+func ListSensorRevisionsHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sensor, err := findSensor(c, database, true)
+		if err != nil {
+			return err
+		}
+
+		revisions, err := database.ListSensorRevisions(sensor.ID)
+		if err != nil {
+			return err
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": revisions,
+		})
+	}
+}
+
+// GetSensorRevisionHandler godoc
+// @Summary      Get a revision of a sensor
+// @Description  Get a single revision of a sensor
+// @Tags         revisions
+// @Accept       json
+// @Produce      json
+// @Param        name       path     string   true    "Sensor Name"
+// @Param        revision   path     int      true    "Revision number"
+// @Success      200  {object}  db.SensorRevision
+// @Failure      400  {object}  handlers.Problem
+// @Failure      404  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/{name}/revisions/{revision} [get]
+func GetSensorRevisionHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		revision, err := strconv.Atoi(c.Params("revision"))
+		if err != nil {
+			return badRequest("revision must be a number")
+		}
+
+		sensor, err := findSensor(c, database, true)
+		if err != nil {
+			return err
+		}
+
+		rev, err := database.GetSensorRevision(sensor.ID, revision)
+		if err != nil {
+			return revisionLookupError(err)
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": rev,
+		})
+	}
+}
+
+// DiffSensorRevisionsHandler godoc
+// @Summary      Diff two revisions of a sensor
+// @Description  List the fields that changed between two revisions of a sensor
+// @Tags         revisions
+// @Accept       json
+// @Produce      json
+// @Param        name   path     string   true    "Sensor Name"
+// @Param        from   query    int      true    "Revision to diff from"
+// @Param        to     query    int      true    "Revision to diff to"
+// @Success      200  {object}  interface{}
+// @Failure      400  {object}  handlers.Problem
+// @Failure      404  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/{name}/revisions/diff [get]
+func DiffSensorRevisionsHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, errFrom := strconv.Atoi(c.Query("from"))
+		to, errTo := strconv.Atoi(c.Query("to"))
+		if errFrom != nil || errTo != nil {
+			return badRequest("from and to must be revision numbers")
+		}
+
+		sensor, err := findSensor(c, database, true)
+		if err != nil {
+			return err
+		}
+
+		fromRev, err := database.GetSensorRevision(sensor.ID, from)
+		if err != nil {
+			return revisionLookupError(err)
+		}
+		toRev, err := database.GetSensorRevision(sensor.ID, to)
+		if err != nil {
+			return revisionLookupError(err)
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code": http.StatusOK,
+			"payload": fiber.Map{
+				"from":    from,
+				"to":      to,
+				"changes": db.DiffSensorRevisions(fromRev, toRev),
+			},
+		})
+	}
+}
+
+// RollbackSensorMetadataHandler godoc
+// @Summary      Roll a sensor back to a revision
+// @Description  Restore the fields of an earlier revision. The result is recorded as a new revision.
+// @Tags         revisions
+// @Accept       json
+// @Produce      json
+// @Param        name       path     string   true    "Sensor Name"
+// @Param        revision   path     int      true    "Revision number"
+// @Param        If-Match   header   string   false   "ETag of the version being rolled back"
+// @Success      200  {object}  db.SensorMetadata
+// @Failure      400  {object}  handlers.Problem
+// @Failure      404  {object}  handlers.Problem
+// @Failure      412  {object}  handlers.Problem
+// @Failure      428  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/{name}/revisions/{revision}/rollback [post]
+func RollbackSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		revision, err := strconv.Atoi(c.Params("revision"))
+		if err != nil {
+			return badRequest("revision must be a number")
+		}
+
+		sensor, err := findSensor(c, database, false)
+		if err != nil {
+			return err
+		}
+
+		if !ifMatch(c, sensor) {
+			return db.ErrVersionConflict
+		}
+
+		sensor, err = database.RollbackSensorMetadata(sensor.ID, revision, sensor.Version)
+		if err != nil {
+			return revisionLookupError(err)
+		}
+
+		c.Set(fiber.HeaderETag, sensorETag(sensor))
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": sensor,
+		})
+	}
+}
+
+// sensorLookupError tells a missing sensor apart from other storage errors.
+func sensorLookupError(err error) error {
+	if err == gorm.ErrRecordNotFound {
+		return NewProblem(http.StatusNotFound, CodeSensorNotFound, "sensor metadata not found")
+	}
+	return err
+}
+
+// revisionLookupError tells a missing revision apart from other storage errors.
+func revisionLookupError(err error) error {
+	if err == gorm.ErrRecordNotFound {
+		return NewProblem(http.StatusNotFound, CodeRevisionNotFound, "sensor revision not found")
+	}
+	return err
+}
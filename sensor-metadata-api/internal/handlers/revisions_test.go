@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"strings"
+	"testing"
+)
+
+func TestRevisionHandlers(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{
+		Name:     "sensor-1",
+		Location: db.Location{Latitude: 40.0, Longitude: -80.0},
+		Tags:     []string{"tag1"},
+	}))
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Put("/sensor-metadata/:name", UpdateSensorMetadataHandler(database))
+	app.Get("/sensor-metadata/:name/revisions", ListSensorRevisionsHandler(database))
+	app.Get("/sensor-metadata/:name/revisions/diff", DiffSensorRevisionsHandler(database))
+	app.Get("/sensor-metadata/:name/revisions/:revision", GetSensorRevisionHandler(database))
+	app.Post("/sensor-metadata/:name/revisions/:revision/rollback", RollbackSensorMetadataHandler(database))
+
+	do := func(method, url, body string) (int, map[string]any) {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		var out map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return resp.StatusCode, out
+	}
+
+	status, _ := do(http.MethodPut, "/sensor-metadata/sensor-1", `{"tags": ["tag2"]}`)
+	require.Equal(t, http.StatusOK, status)
+
+	status, body := do(http.MethodGet, "/sensor-metadata/sensor-1/revisions", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, body["payload"], 2)
+
+	status, body = do(http.MethodGet, "/sensor-metadata/sensor-1/revisions/diff?from=1&to=2", "")
+	assert.Equal(t, http.StatusOK, status)
+	changes := body["payload"].(map[string]any)["changes"].([]any)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "tags", changes[0].(map[string]any)["field"])
+
+	status, _ = do(http.MethodGet, "/sensor-metadata/sensor-1/revisions/2", "")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = do(http.MethodGet, "/sensor-metadata/sensor-1/revisions/9", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = do(http.MethodGet, "/sensor-metadata/sensor-1/revisions/latest", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// a rollback based on a stale version is refused
+	req := httptest.NewRequest(http.MethodPost, "/sensor-metadata/sensor-1/revisions/1/rollback", nil)
+	req.Header.Set("If-Match", `"1"`)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	status, body = do(http.MethodPost, "/sensor-metadata/sensor-1/revisions/1/rollback", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []any{"tag1"}, body["payload"].(map[string]any)["tags"])
+
+	status, body = do(http.MethodGet, "/sensor-metadata/sensor-1/revisions", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, body["payload"], 3)
+
+	status, _ = do(http.MethodGet, "/sensor-metadata/unknown/revisions", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
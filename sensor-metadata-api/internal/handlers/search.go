@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"strconv"
+)
+
+// SearchSensorMetadataHandler godoc
+// @Summary      Search sensors
+// @Description  Full-text search over the names, tags and descriptions of the sensors. Every word of q must match the start of a word of a sensor, without regard to case. Results are ranked, a match in the name weighing more than one in the tags, and one in the tags more than one in the description. The highlights hold HTML escaped excerpts of the matching fields with the matched words in <mark> elements.
+// @Tags         search
+// @Accept       json
+// @Produce      json
+// @Param        q        query    string   true    "Words to look for"
+// @Param        limit    query    int      false   "Number of results (default 20, max 100)"
+// @Param        offset   query    int      false   "Number of results to skip"
+// @Success      200  {array}   db.SearchResult
+// @Failure      400  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/search [get]
+func SearchSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		opts := db.SearchOptions{Query: c.Query("q")}
+		var err error
+		for param, dst := range map[string]*int{
+			"limit":  &opts.Limit,
+			"offset": &opts.Offset,
+		} {
+			v := c.Query(param)
+			if v == "" {
+				continue
+			}
+			if *dst, err = strconv.Atoi(v); err != nil || *dst < 0 {
+				return badRequest(param + " must be a non-negative integer")
+			}
+		}
+
+		results, err := database.SearchSensorMetadata(opts)
+		if errors.Is(err, db.ErrInvalidSearch) {
+			return badRequest("q must hold at least one word")
+		}
+		if err != nil {
+			return err
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": results,
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"testing"
+)
+
+func TestSearchSensorMetadataHandler(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	for _, s := range []db.SensorMetadata{
+		{Name: "roof-1", Description: "Temperature on the roof"},
+		{Name: "cellar-1", Description: "Humidity below the roof terrace"},
+	} {
+		s := s
+		require.NoError(t, database.CreateSensorMetadata(&s))
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata/search", SearchSensorMetadataHandler(database))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/sensor-metadata/search?q=ROO&limit=5", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Payload []db.SearchResult `json:"payload"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Payload, 2)
+	assert.Equal(t, "roof-1", body.Payload[0].Name)
+	assert.Equal(t, "<mark>roof</mark>-1", body.Payload[0].Highlights["name"])
+	assert.Equal(t, "Humidity below the <mark>roof</mark> terrace", body.Payload[1].Highlights["description"])
+
+	for _, url := range []string{
+		"/sensor-metadata/search",
+		"/sensor-metadata/search?q=+,+",
+		"/sensor-metadata/search?q=roof&offset=-1",
+		"/sensor-metadata/search?q=roof&limit=many",
+	} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, url)
+		assert.Equal(t, CodeInvalidRequest, decodeProblem(t, resp).Code, url)
+	}
+}
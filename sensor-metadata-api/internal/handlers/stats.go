@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"strconv"
+	"time"
+)
+
+// GetSensorStatsHandler godoc
+// @Summary      Sensor statistics
+// @Description  Count the sensors matching the list filters, with the range of their creation and update times. group_by splits the count by tag (a sensor counting once for each of its tags), by creation or update time bucket, or by geohash cell. Time buckets are keyed by their RFC 3339 start in UTC and listed in chronological order, weeks starting on Monday; other groups are listed by decreasing count.
+// @Tags         list
+// @Accept       json
+// @Produce      json
+// @Param        group_by        query    string   false   "tag, created, updated or geohash"
+// @Param        interval        query    string   false   "hour, day, week, month or year, for created and updated (default day)"
+// @Param        precision       query    int      false   "Length of the geohash cells, 1 to 12 (default 4)"
+// @Param        tags            query    string   false   "Comma separated tags a sensor must all carry"
+// @Param        tags_any        query    string   false   "Comma separated tags a sensor must carry at least one of"
+// @Param        tags_none       query    string   false   "Comma separated tags a sensor must not carry"
+// @Param        selector        query    string   false   "Label selector over key=value tags"
+// @Param        name_prefix     query    string   false   "Sensor name prefix"
+// @Param        created_after   query    string   false   "RFC 3339 time, inclusive"
+// @Param        created_before  query    string   false   "RFC 3339 time, exclusive"
+// @Param        updated_after   query    string   false   "RFC 3339 time, inclusive"
+// @Param        updated_before  query    string   false   "RFC 3339 time, exclusive"
+// @Param        include_deleted query    bool     false   "Also count soft-deleted sensors"
+// @Param        If-None-Match   header   string   false   "ETag of stats the client holds"
+// @Success      200  {object}  db.SensorStats
+// @Header       200  {string}  ETag  "Changes whenever any sensor does"
+// @Success      304  "No sensor changed"
+// @Failure      400  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/stats [get]
+func GetSensorStatsHandler(database db.SensorMetadataDB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		list, err := parseListOptions(c)
+		if err != nil {
+			return badRequest(err.Error())
+		}
+		opts := db.StatsOptions{
+			ListOptions: list,
+			GroupBy:     c.Query("group_by"),
+			Interval:    c.Query("interval"),
+		}
+		if v := c.Query("precision"); v != "" {
+			if opts.Precision, err = strconv.Atoi(v); err != nil {
+				return badRequest("precision must be an integer")
+			}
+		}
+
+		token, err := database.GetChangeToken()
+		if err != nil {
+			return err
+		}
+		if notModified(c, changeETag(token), time.Time{}) {
+			return c.SendStatus(http.StatusNotModified)
+		}
+
+		stats, err := database.GetSensorStats(opts)
+		if errors.Is(err, db.ErrInvalidStats) {
+			return badRequest("group_by must be one of tag, created, updated, geohash; " +
+				"interval one of hour, day, week, month, year; and precision between 1 and 12")
+		}
+		if err != nil {
+			return err
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code":    http.StatusOK,
+			"payload": stats,
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"testing"
+)
+
+func TestGetSensorStatsHandler(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	for _, s := range []db.SensorMetadata{
+		{Name: "roof-1", Description: "roof 1", Tags: []string{"outdoor", "env=prod"}, Location: db.Location{Latitude: 39.95, Longitude: -75.17}},
+		{Name: "roof-2", Description: "roof 2", Tags: []string{"outdoor"}, Location: db.Location{Latitude: 39.96, Longitude: -75.16}},
+		{Name: "cellar-1", Description: "cellar 1", Tags: []string{"indoor", "env=prod"}, Location: db.Location{Latitude: 40.44, Longitude: -80.0}},
+	} {
+		s := s
+		require.NoError(t, database.CreateSensorMetadata(&s))
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata/stats", GetSensorStatsHandler(database))
+
+	get := func(url string, header ...string) (*http.Response, db.SensorStats) {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		var body struct {
+			Payload db.SensorStats `json:"payload"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		}
+		return resp, body.Payload
+	}
+
+	resp, stats := get("/sensor-metadata/stats?group_by=tag&selector=env=prod")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(2), stats.Count)
+	assert.Equal(t, []db.StatsGroup{{Key: "env=prod", Count: 2}, {Key: "indoor", Count: 1}, {Key: "outdoor", Count: 1}}, stats.Groups)
+
+	resp, stats = get("/sensor-metadata/stats?group_by=geohash&precision=3")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []db.StatsGroup{{Key: "dr4", Count: 2}, {Key: "dpp", Count: 1}}, stats.Groups)
+
+	resp, _ = get("/sensor-metadata/stats", fiber.HeaderIfNoneMatch, resp.Header.Get(fiber.HeaderETag))
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	for _, url := range []string{
+		"/sensor-metadata/stats?group_by=name",
+		"/sensor-metadata/stats?group_by=created&interval=fortnight",
+		"/sensor-metadata/stats?group_by=geohash&precision=x",
+		"/sensor-metadata/stats?created_after=yesterday",
+	} {
+		resp, _ = get(url)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, url)
+		assert.Equal(t, CodeInvalidRequest, decodeProblem(t, resp).Code, url)
+	}
+}
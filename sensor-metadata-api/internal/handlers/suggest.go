@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/suggest"
+	"strconv"
+	"sync"
+)
+
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 50
+)
+
+// SuggestSensorMetadataHandler godoc
+// @Summary      Suggest sensor names and tags
+// @Description  Complete a prefix with the names of live sensors, shortest first, and their tags, most used first, with the number of sensors carrying each. A prefix of three characters or more also finds completions with a typo, one for prefixes up to five characters and two beyond, ranked after the exact ones by their distance. Suggestions come from an in-memory index that follows the revisions of the sensors.
+// @Tags         search
+// @Accept       json
+// @Produce      json
+// @Param        q       query    string   false   "Prefix to complete, the most used tags without it"
+// @Param        limit   query    int      false   "Number of names and of tags (default 10, max 50)"
+// @Success      200  {object}  interface{}  "names and tags, lists of suggest.Completion"
+// @Failure      400  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/suggest [get]
+func SuggestSensorMetadataHandler(database db.SensorMetadataDB) fiber.Handler {
+	s := &suggester{database: database}
+	return func(c *fiber.Ctx) error {
+		limit := defaultSuggestLimit
+		if v := c.Query("limit"); v != "" {
+			var err error
+			if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+				return badRequest("limit must be a positive integer")
+			}
+			if limit > maxSuggestLimit {
+				limit = maxSuggestLimit
+			}
+		}
+
+		index, err := s.index()
+		if err != nil {
+			return err
+		}
+
+		prefix := c.Query("q")
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"code": http.StatusOK,
+			"payload": fiber.Map{
+				"names": index.Names(prefix, limit),
+				"tags":  index.Tags(prefix, limit),
+			},
+		})
+	}
+}
+
+// suggestBatchSize is the number of revisions a suggester reads at once.
+const suggestBatchSize = 500
+
+// suggester keeps the suggest index of the live sensors. It loads them once,
+// then follows the revisions recorded since, so a change only costs reading
+// that revision and replacing the entries of its sensor.
+type suggester struct {
+	database db.SensorMetadataDB
+
+	mu sync.Mutex
+	// seq is the latest revision applied to the index
+	seq int64
+	// sensors are the live sensors as indexed, to take them out on a change
+	sensors map[uuid.UUID]db.SensorMetadata
+	built   *suggest.Index
+}
+
+// index returns the suggest index, first applying the revisions recorded since
+// the last call.
+func (s *suggester) index() (*suggest.Index, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.built == nil {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		revisions, err := s.database.ListRevisionsSince(s.seq, suggestBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for i := range revisions {
+			rev := &revisions[i]
+			if old, ok := s.sensors[rev.SensorID]; ok {
+				s.built.Remove(old.Name, old.Tags)
+				delete(s.sensors, rev.SensorID)
+			}
+			if !rev.Snapshot.DeletedAt.Valid {
+				s.built.Add(rev.Snapshot.Name, rev.Snapshot.Tags)
+				s.sensors[rev.SensorID] = rev.Snapshot
+			}
+			s.seq = rev.Seq
+		}
+		if len(revisions) < suggestBatchSize {
+			break
+		}
+	}
+
+	return s.built, nil
+}
+
+// load indexes every live sensor, as of the latest revision or later; the
+// revisions replayed after it bring the sensors read later up to date too.
+func (s *suggester) load() error {
+	token, err := s.database.GetChangeToken()
+	if err != nil {
+		return err
+	}
+
+	sensors := make(map[uuid.UUID]db.SensorMetadata)
+	var names, tags []string
+	opts := db.ListOptions{Limit: db.MaxListLimit}
+	for {
+		page, err := s.database.ListSensorMetadata(opts)
+		if err != nil {
+			return err
+		}
+		for _, sensor := range page.Items {
+			sensors[sensor.ID] = sensor
+			names = append(names, sensor.Name)
+			tags = append(tags, sensor.Tags...)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	s.seq, s.sensors, s.built = token.Seq, sensors, suggest.Build(names, tags)
+	return nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/suggest"
+	"testing"
+)
+
+func TestSuggestSensorMetadataHandler(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	for _, s := range []db.SensorMetadata{
+		{Name: "roof-1", Description: "roof 1", Tags: []string{"outdoor", "env=prod"}},
+		{Name: "roof-2", Description: "roof 2", Tags: []string{"outdoor"}},
+		{Name: "cellar-1", Description: "cellar 1", Tags: []string{"indoor", "env=prod"}},
+	} {
+		s := s
+		require.NoError(t, database.CreateSensorMetadata(&s))
+	}
+
+	counted := &listCountingDB{SensorMetadataDB: database}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata/suggest", SuggestSensorMetadataHandler(counted))
+
+	type suggestions struct {
+		Names []suggest.Completion `json:"names"`
+		Tags  []suggest.Completion `json:"tags"`
+	}
+	get := func(url string) suggestions {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body struct {
+			Payload suggestions `json:"payload"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body.Payload
+	}
+
+	got := get("/sensor-metadata/suggest?q=R")
+	assert.Equal(t, []suggest.Completion{{Text: "roof-1"}, {Text: "roof-2"}}, got.Names)
+	assert.Empty(t, got.Tags)
+
+	got = get("/sensor-metadata/suggest?limit=2")
+	assert.Equal(t, []suggest.Completion{{Text: "env=prod", Count: 2}, {Text: "outdoor", Count: 2}}, got.Tags)
+
+	// the index follows the changes
+	require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{Name: "Cellar-2", Tags: []string{"indoor"}}))
+	require.NoError(t, database.DeleteSensorMetadata("roof-2", 0))
+
+	got = get("/sensor-metadata/suggest?q=clelar")
+	assert.Equal(t, []suggest.Completion{{Text: "cellar-1", Distance: 1}, {Text: "Cellar-2", Distance: 1}}, got.Names)
+
+	got = get("/sensor-metadata/suggest?q=OUT")
+	assert.Equal(t, []suggest.Completion{{Text: "outdoor", Count: 1}}, got.Tags)
+
+	sensor, err := database.GetSensorMetadataByName("cellar-1")
+	require.NoError(t, err)
+	sensor.Tags = []string{"outdoor"}
+	require.NoError(t, database.UpdateSensorMetadata(sensor))
+	require.NoError(t, database.PurgeSensorMetadata("roof-1"))
+
+	got = get("/sensor-metadata/suggest?q=OUT")
+	assert.Equal(t, []suggest.Completion{{Text: "outdoor", Count: 1}}, got.Tags)
+	got = get("/sensor-metadata/suggest?q=roof")
+	assert.Empty(t, got.Names)
+
+	// the sensors are listed once, later changes are read from the revisions
+	assert.Equal(t, 1, counted.lists)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/sensor-metadata/suggest?limit=0", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, CodeInvalidRequest, decodeProblem(t, resp).Code)
+}
+
+// listCountingDB counts the listings of the sensors.
+type listCountingDB struct {
+	db.SensorMetadataDB
+	lists int
+}
+
+func (d *listCountingDB) ListSensorMetadata(opts db.ListOptions) (*db.SensorMetadataPage, error) {
+	d.lists++
+	return d.SensorMetadataDB.ListSensorMetadata(opts)
+}
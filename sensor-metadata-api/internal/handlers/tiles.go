@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/mvt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tileLayer is the name of the layer holding the sensors.
+	tileLayer = "sensors"
+	// tileBuffer is the margin in tile units around a tile whose sensors are
+	// drawn too, so markers on an edge are not cut in half.
+	tileBuffer = 64
+	// maxCachedTiles bounds the tile cache; the oldest tiles are dropped first.
+	maxCachedTiles = 1024
+)
+
+// SensorTileHandler godoc
+// @Summary      Sensor vector tile
+// @Description  Render the live sensors of a slippy map tile as a Mapbox Vector Tile, with one point feature per sensor in the "sensors" layer, carrying the name and the comma separated tags of the sensor as attributes. Sensors just across the edges of the tile are included, 64 units out of 4096. Tiles are cached until any sensor changes.
+// @Tags         geo
+// @Produce      application/vnd.mapbox-vector-tile
+// @Param        z               path     int      true    "Zoom level, 0 to 22"
+// @Param        x               path     int      true    "Column, from the antimeridian eastwards"
+// @Param        y               path     int      true    "Row, from the north"
+// @Param        If-None-Match   header   string   false   "ETag of the tile the client holds"
+// @Success      200  {file}    binary
+// @Header       200  {string}  ETag  "Changes whenever any sensor does"
+// @Success      304  "No sensor changed"
+// @Failure      400  {object}  handlers.Problem
+// @Failure      500  {object}  handlers.Problem
+// @Router       /sensor-metadata/tiles/{z}/{x}/{y}.mvt [get]
+func SensorTileHandler(database db.SensorMetadataDB) fiber.Handler {
+	cache := &tileCache{}
+	return func(c *fiber.Ctx) error {
+		var tile mvt.Tile
+		var err error
+		for param, dst := range map[string]*int{"z": &tile.Z, "x": &tile.X, "y": &tile.Y} {
+			if *dst, err = strconv.Atoi(c.Params(param)); err != nil {
+				return badRequest(param + " must be an integer")
+			}
+		}
+		if !tile.Valid() {
+			return badRequest("z must be between 0 and 22, and x and y between 0 and 2^z-1")
+		}
+
+		token, err := database.GetChangeToken()
+		if err != nil {
+			return err
+		}
+		if notModified(c, changeETag(token), time.Time{}) {
+			return c.SendStatus(http.StatusNotModified)
+		}
+
+		data, ok := cache.get(token, tile)
+		if !ok {
+			if data, err = renderTile(database, tile); err != nil {
+				return err
+			}
+			cache.put(token, tile, data)
+		}
+
+		c.Set(fiber.HeaderContentType, mvt.MediaType)
+		return c.Status(http.StatusOK).Send(data)
+	}
+}
+
+// renderTile encodes the sensors of tile.
+func renderTile(database db.SensorMetadataDB, tile mvt.Tile) ([]byte, error) {
+	minLat, minLon, maxLat, maxLon := tile.Bounds(mvt.DefaultExtent, tileBuffer)
+	sensors, err := database.FindSensorMetadataInBox(db.BoundingBox{
+		MinLatitude:  minLat,
+		MinLongitude: minLon,
+		MaxLatitude:  maxLat,
+		MaxLongitude: maxLon,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	layer := mvt.NewLayer(tileLayer, mvt.DefaultExtent)
+	for _, s := range sensors {
+		x, y := tile.Project(s.Location.Latitude, s.Location.Longitude, mvt.DefaultExtent)
+		layer.AddPoint(x, y,
+			mvt.Attribute{Key: "name", Value: s.Name},
+			mvt.Attribute{Key: "tags", Value: strings.Join(s.Tags, ",")},
+		)
+	}
+	return mvt.Encode(layer), nil
+}
+
+// tileCache keeps the tiles rendered as of a change token, and drops them all
+// once a sensor changed.
+type tileCache struct {
+	mu    sync.Mutex
+	token db.ChangeToken
+	tiles map[mvt.Tile][]byte
+	// order lists the cached tiles from the oldest
+	order []mvt.Tile
+}
+
+func (tc *tileCache) get(token db.ChangeToken, tile mvt.Tile) ([]byte, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.token != token {
+		return nil, false
+	}
+	data, ok := tc.tiles[tile]
+	return data, ok
+}
+
+func (tc *tileCache) put(token db.ChangeToken, tile mvt.Tile, data []byte) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	// a tile rendered before the latest change must not evict the newer ones
+	if tc.tiles != nil && token.Seq < tc.token.Seq {
+		return
+	}
+	if tc.tiles == nil || tc.token != token {
+		tc.token, tc.tiles, tc.order = token, make(map[mvt.Tile][]byte), nil
+	}
+	if _, ok := tc.tiles[tile]; ok {
+		return
+	}
+	if len(tc.order) >= maxCachedTiles {
+		delete(tc.tiles, tc.order[0])
+		tc.order = tc.order[1:]
+	}
+	tc.tiles[tile] = data
+	tc.order = append(tc.order, tile)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/mvt"
+	"testing"
+)
+
+func TestSensorTileHandler(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+	for _, s := range []db.SensorMetadata{
+		{Name: "philadelphia-1", Description: "a", Location: db.Location{Latitude: 39.95, Longitude: -75.17}, Tags: []string{"outdoor", "env=prod"}},
+		{Name: "pittsburgh-1", Description: "b", Location: db.Location{Latitude: 40.44, Longitude: -79.99}},
+	} {
+		s := s
+		require.NoError(t, database.CreateSensorMetadata(&s))
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sensor-metadata/tiles/:z/:x/:y.mvt", SensorTileHandler(database))
+
+	get := func(url, etag string) (*http.Response, string) {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if etag != "" {
+			req.Header.Set(fiber.HeaderIfNoneMatch, etag)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	// Philadelphia is in tile 10/298/387
+	resp, body := get("/sensor-metadata/tiles/10/298/387.mvt", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, mvt.MediaType, resp.Header.Get(fiber.HeaderContentType))
+	assert.Contains(t, body, "sensors")
+	assert.Contains(t, body, "philadelphia-1")
+	assert.Contains(t, body, "outdoor,env=prod")
+	assert.NotContains(t, body, "pittsburgh-1")
+	etag := resp.Header.Get(fiber.HeaderETag)
+	require.NotEmpty(t, etag)
+
+	resp, _ = get("/sensor-metadata/tiles/10/298/387.mvt", etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// the whole world at zoom 0
+	_, body = get("/sensor-metadata/tiles/0/0/0.mvt", "")
+	assert.Contains(t, body, "philadelphia-1")
+	assert.Contains(t, body, "pittsburgh-1")
+
+	// the tiles along the antimeridian hold the sensors just across it
+	require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{
+		Name: "taveuni-1", Description: "d", Location: db.Location{Latitude: 1, Longitude: 179.9},
+	}))
+	require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{
+		Name: "taveuni-2", Description: "e", Location: db.Location{Latitude: 1, Longitude: -179.9},
+	}))
+	for _, url := range []string{"/sensor-metadata/tiles/3/0/3.mvt", "/sensor-metadata/tiles/3/7/3.mvt"} {
+		_, body = get(url, "")
+		assert.Contains(t, body, "taveuni-1", url)
+		assert.Contains(t, body, "taveuni-2", url)
+	}
+
+	// a change drops the cached tiles
+	require.NoError(t, database.CreateSensorMetadata(&db.SensorMetadata{
+		Name: "philadelphia-2", Description: "c", Location: db.Location{Latitude: 39.96, Longitude: -75.16},
+	}))
+	resp, body = get("/sensor-metadata/tiles/10/298/387.mvt", etag)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "philadelphia-2")
+	assert.NotEqual(t, etag, resp.Header.Get(fiber.HeaderETag))
+
+	for _, url := range []string{
+		"/sensor-metadata/tiles/23/0/0.mvt",
+		"/sensor-metadata/tiles/1/2/0.mvt",
+		"/sensor-metadata/tiles/1/0/-1.mvt",
+		"/sensor-metadata/tiles/a/0/0.mvt",
+	} {
+		resp, _ := get(url, "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, url)
+	}
+}
+
+func TestTileCache_KeepsNewest(t *testing.T) {
+	tile := mvt.Tile{Z: 1}
+	older, newer := db.ChangeToken{Seq: 1, Sensors: 1}, db.ChangeToken{Seq: 2, Sensors: 1}
+
+	cache := &tileCache{}
+	cache.put(newer, tile, []byte("new"))
+	// a slow render of an earlier state comes in last
+	cache.put(older, tile, []byte("old"))
+
+	data, ok := cache.get(newer, tile)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("new"), data)
+	_, ok = cache.get(older, tile)
+	assert.False(t, ok)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sensor-metadata-api/internal/db"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits of the sensor fields accepted by create, update and import.
+const (
+	MaxNameLength        = 128
+	MaxDescriptionLength = 4096
+	MaxTags              = 32
+	MaxTagLength         = 64
+)
+
+var (
+	// names are used in URLs: letters, digits and inner spaces, dots,
+	// underscores and dashes
+	namePattern = regexp.MustCompile(`^[\p{L}\p{N}]([\p{L}\p{N} ._-]*[\p{L}\p{N}._-])?$`)
+	// tags may also hold the ':', '/' and '=' of key=value labels
+	tagPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}._:/=-]*$`)
+
+	// reservedNames are the routes under /sensor-metadata that would shadow
+	// /sensor-metadata/:name, compared in lower case like the routes are
+	reservedNames = map[string]bool{
+		"search":  true,
+		"suggest": true,
+		"stats":   true,
+		"events":  true,
+	}
+)
+
+// validateSensor applies the rules every new sensor must satisfy, naming each
+// field that breaks them. hasLocation tells whether the request set a location,
+// as the zero Location is also the valid position (0, 0).
+func validateSensor(sensor *db.SensorMetadata, hasLocation bool) error {
+	return fieldErrors(checkSensor(sensor, hasLocation, false))
+}
+
+// validateSensorUpdate applies the same rules to the fields an update sets,
+// leaving out the ones it does not.
+func validateSensorUpdate(sensor *db.SensorMetadata, hasLocation bool) error {
+	return fieldErrors(checkSensor(sensor, hasLocation, true))
+}
+
+func fieldErrors(fields []FieldError) error {
+	if len(fields) > 0 {
+		return validationFailed(fields)
+	}
+	return nil
+}
+
+func checkSensor(sensor *db.SensorMetadata, hasLocation, partial bool) []FieldError {
+	var fields []FieldError
+	add := func(field, format string, args ...interface{}) {
+		fields = append(fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case sensor.Name == "":
+		if !partial {
+			add("name", "is required")
+		}
+	case utf8.RuneCountInString(sensor.Name) > MaxNameLength:
+		add("name", "must be at most %d characters", MaxNameLength)
+	case !namePattern.MatchString(sensor.Name):
+		add("name", "must start with a letter or digit and hold only letters, digits, spaces, '.', '_' and '-'")
+	case reservedNames[strings.ToLower(sensor.Name)]:
+		add("name", "is reserved for the /sensor-metadata/%s route", strings.ToLower(sensor.Name))
+	}
+
+	if !utf8.ValidString(sensor.Description) {
+		add("description", "must be valid UTF-8")
+	} else if utf8.RuneCountInString(sensor.Description) > MaxDescriptionLength {
+		add("description", "must be at most %d characters", MaxDescriptionLength)
+	}
+
+	switch {
+	case hasLocation:
+		// written so that NaN fails too
+		if !(sensor.Location.Latitude >= -90 && sensor.Location.Latitude <= 90) {
+			add("location.latitude", "must be between -90 and 90")
+		}
+		if !(sensor.Location.Longitude >= -180 && sensor.Location.Longitude <= 180) {
+			add("location.longitude", "must be between -180 and 180")
+		}
+	case !partial:
+		add("location", "is required")
+	}
+
+	if len(sensor.Tags) > MaxTags {
+		add("tags", "must hold at most %d tags", MaxTags)
+	}
+	for i, tag := range sensor.Tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		switch {
+		case utf8.RuneCountInString(tag) > MaxTagLength:
+			add(field, "must be at most %d characters", MaxTagLength)
+		case !tagPattern.MatchString(tag):
+			add(field, "must start with a letter or digit and hold only letters, digits, '.', '_', '-', ':', '/' and '='")
+		}
+	}
+
+	return fields
+}
+
+// jsonHasLocation reports whether a JSON object sets a non-null location.
+func jsonHasLocation(body []byte) bool {
+	var probe struct {
+		Location json.RawMessage `json:"location"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return false
+	}
+	return len(probe.Location) > 0 && strings.TrimSpace(string(probe.Location)) != "null"
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sensor-metadata-api/internal/db"
+	"strings"
+	"testing"
+)
+
+func TestCheckSensor(t *testing.T) {
+	valid := db.SensorMetadata{
+		Name:        "Sensor 1",
+		Description: "roof, north side",
+		Tags:        []string{"outdoor", "floor=3", "app.example.com/team"},
+	}
+
+	tests := map[string]struct {
+		edit        func(s *db.SensorMetadata)
+		hasLocation bool
+		partial     bool
+		fields      []string
+	}{
+		"Valid":                 {edit: func(s *db.SensorMetadata) {}, hasLocation: true},
+		"Null_Island":           {edit: func(s *db.SensorMetadata) { s.Location = db.Location{} }, hasLocation: true},
+		"Missing_Location":      {edit: func(s *db.SensorMetadata) {}, fields: []string{"location"}},
+		"Partial_Skips_Missing": {edit: func(s *db.SensorMetadata) { s.Name = "" }, partial: true},
+		"Latitude_Range": {
+			edit:        func(s *db.SensorMetadata) { s.Location = db.Location{Latitude: 500, Longitude: -181} },
+			hasLocation: true,
+			fields:      []string{"location.latitude", "location.longitude"},
+		},
+		"Name_Charset": {
+			edit:        func(s *db.SensorMetadata) { s.Name = "roof/north" },
+			hasLocation: true,
+			fields:      []string{"name"},
+		},
+		"Name_Trailing_Space": {
+			edit:        func(s *db.SensorMetadata) { s.Name = "sensor " },
+			hasLocation: true,
+			fields:      []string{"name"},
+		},
+		"Name_Length": {
+			edit:        func(s *db.SensorMetadata) { s.Name = strings.Repeat("a", MaxNameLength+1) },
+			hasLocation: true,
+			fields:      []string{"name"},
+		},
+		"Name_Reserved": {
+			edit:        func(s *db.SensorMetadata) { s.Name = "Search" },
+			hasLocation: true,
+			fields:      []string{"name"},
+		},
+		"Description_Length": {
+			edit:        func(s *db.SensorMetadata) { s.Description = strings.Repeat("é", MaxDescriptionLength+1) },
+			hasLocation: true,
+			fields:      []string{"description"},
+		},
+		"Tags": {
+			edit: func(s *db.SensorMetadata) {
+				s.Tags = []string{"ok", "", "has space", strings.Repeat("t", MaxTagLength+1)}
+			},
+			hasLocation: true,
+			fields:      []string{"tags[1]", "tags[2]", "tags[3]"},
+		},
+		"Tag_Count": {
+			edit:        func(s *db.SensorMetadata) { s.Tags = repeatTag(MaxTags + 1) },
+			hasLocation: true,
+			fields:      []string{"tags"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sensor := valid
+			sensor.Location = db.Location{Latitude: 40.4406, Longitude: -79.9959}
+			tt.edit(&sensor)
+
+			var fields []string
+			for _, f := range checkSensor(&sensor, tt.hasLocation, tt.partial) {
+				fields = append(fields, f.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func repeatTag(n int) db.StringArray {
+	tags := make(db.StringArray, n)
+	for i := range tags {
+		tags[i] = "tag"
+	}
+	return tags
+}
+
+func TestValidationOnWrite(t *testing.T) {
+	database := db.NewMemorySensorMetadataDB()
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/sensor-metadata", CreateSensorMetadataHandler(database))
+	app.Put("/sensor-metadata/:name", UpdateSensorMetadataHandler(database))
+
+	send := func(method, url, body string) *http.Response {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := send(http.MethodPost, "/sensor-metadata", `{"name": "null-island", "location": {"latitude": 0, "longitude": 0}}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = send(http.MethodPost, "/sensor-metadata", `{"name": "sensor-1", "location": {"latitude": 500, "longitude": 2}, "tags": ["a b"]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	p := decodeProblem(t, resp)
+	assert.Equal(t, CodeValidationFailed, p.Code)
+	assert.Equal(t, []FieldError{
+		{Field: "location.latitude", Message: "must be between -90 and 90"},
+		{Field: "tags[0]", Message: "must start with a letter or digit and hold only letters, digits, '.', '_', '-', ':', '/' and '='"},
+	}, p.Errors)
+
+	resp = send(http.MethodPost, "/sensor-metadata", `{"name": "sensor-1", "description": "sensor 1", "location": {"latitude": 1, "longitude": 2}}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// a sensor named like a route could never be read back
+	resp = send(http.MethodPost, "/sensor-metadata", `{"name": "search", "description": "search", "location": {"latitude": 1, "longitude": 2}}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	p = decodeProblem(t, resp)
+	assert.Equal(t, CodeValidationFailed, p.Code)
+	assert.Equal(t, []FieldError{{Field: "name", Message: "is reserved for the /sensor-metadata/search route"}}, p.Errors)
+	resp = send(http.MethodPost, "/sensor-metadata", `{"name": "Stats", "description": "stats", "location": {"latitude": 1, "longitude": 2}}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "name", decodeProblem(t, resp).Errors[0].Field)
+	resp = send(http.MethodPost, "/sensor-metadata", `{"name": "Events", "description": "events", "location": {"latitude": 1, "longitude": 2}}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "name", decodeProblem(t, resp).Errors[0].Field)
+	resp = send(http.MethodPost, "/sensor-metadata", `{"name": "Suggest", "description": "suggest", "location": {"latitude": 1, "longitude": 2}}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "name", decodeProblem(t, resp).Errors[0].Field)
+
+	// an update can move a sensor to (0, 0), but not off the globe
+	resp = send(http.MethodPut, "/sensor-metadata/sensor-1", `{"location": {"latitude": 0, "longitude": 0}}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	got, err := database.GetSensorMetadataByName("sensor-1")
+	require.NoError(t, err)
+	assert.Equal(t, db.Location{}, got.Location)
+
+	resp = send(http.MethodPut, "/sensor-metadata/sensor-1", `{"location": {"latitude": 1, "longitude": 200}}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "location.longitude", decodeProblem(t, resp).Errors[0].Field)
+}
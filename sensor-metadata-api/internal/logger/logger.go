@@ -2,6 +2,7 @@ package logger
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -15,6 +16,7 @@ import (
 const (
 	RequestIdCtxKey = "requestid"
 	loggerCtxKey    = "logger"
+	errorCtxKey     = "error"
 )
 
 func RequestId() fiber.Handler {
@@ -59,32 +61,48 @@ func WrapLogger() fiber.Handler {
 		// call the next handler in the chain
 		err := c.Next()
 		if err != nil {
-			l.Info(err.Error(),
+			// the app's ErrorHandler renders it once this returns
+			status := errorStatus(err)
+			levelFor(l, status)(err.Error(),
+				zap.Error(err),
 				zap.Int64("response_time", time.Since(reqTime).Milliseconds()),
-				zap.Int("response_size", len(c.Response().Body())),
-				zap.Int("status_code", c.Response().StatusCode()),
+				zap.Int("status_code", status),
 			)
 			return err
 		}
+		if err, ok := c.Locals(errorCtxKey).(error); ok {
+			// an error already rendered, see SetErrorForRequest
+			levelFor(l, c.Response().StatusCode())(err.Error(),
+				zap.Error(err),
+				zap.Int64("response_time", time.Since(reqTime).Milliseconds()),
+				zap.Int("response_size", responseSize(c)),
+				zap.Int("status_code", c.Response().StatusCode()),
+			)
+			return nil
+		}
 
 		// log the response
 		switch {
 		// log the error response sent to the client
-		case c.Response().StatusCode() != http.StatusOK && c.Response().StatusCode() != http.StatusFound:
+		case c.Response().StatusCode() >= http.StatusBadRequest:
 			r := map[string]any{}
 			if err = json.Unmarshal(c.Response().Body(), &r); err != nil {
 
 				l.Error("error unmarshalling payload response: "+err.Error(),
 					zap.Int64("response_time", time.Since(reqTime).Milliseconds()),
-					zap.Int("response_size", len(c.Response().Body())),
+					zap.Int("response_size", responseSize(c)),
 					zap.Int("status_code", c.Response().StatusCode()),
 				)
 				return err
 			}
 
-			l.Error(fmt.Sprintf("%s", r["payload"]),
+			msg := r["payload"]
+			if msg == nil {
+				msg = r["detail"]
+			}
+			levelFor(l, c.Response().StatusCode())(fmt.Sprintf("%s", msg),
 				zap.Int64("response_time", time.Since(reqTime).Milliseconds()),
-				zap.Int("response_size", len(c.Response().Body())),
+				zap.Int("response_size", responseSize(c)),
 				zap.Int("status_code", c.Response().StatusCode()),
 			)
 
@@ -92,15 +110,15 @@ func WrapLogger() fiber.Handler {
 			logger := l.Level()
 			switch logger.Get() {
 			case "Debug":
-				l.Info(string(c.Response().Body()),
+				l.Info(string(responseBody(c)),
 					zap.Int64("response_time", time.Since(reqTime).Milliseconds()),
-					zap.Int("response_size", len(c.Response().Body())),
+					zap.Int("response_size", responseSize(c)),
 					zap.Int("status_code", c.Response().StatusCode()),
 				)
 			default:
 				l.Info("sent response",
 					zap.Int64("response_time", time.Since(reqTime).Milliseconds()),
-					zap.Int("response_size", len(c.Response().Body())),
+					zap.Int("response_size", responseSize(c)),
 					zap.Int("status_code", c.Response().StatusCode()),
 				)
 			}
@@ -110,6 +128,52 @@ func WrapLogger() fiber.Handler {
 	}
 }
 
+// levelFor logs the responses of status: server errors as errors, and client
+// errors, which are the mistakes of callers, like any other response.
+func levelFor(l *zap.Logger, status int) func(string, ...zap.Field) {
+	if status >= http.StatusInternalServerError {
+		return l.Error
+	}
+	return l.Info
+}
+
+// errorStatus is the status an error returned to the app is answered with, as
+// told by the error; errors that do not tell are server errors.
+func errorStatus(err error) int {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return fe.Code
+	}
+	var coded interface{ StatusCode() int }
+	if errors.As(err, &coded) {
+		return coded.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
+
+// responseBody returns the body of the response, or nothing for a streamed
+// body, which reading would drain before it reaches the client.
+func responseBody(c *fiber.Ctx) []byte {
+	if c.Response().IsBodyStream() {
+		return nil
+	}
+	return c.Response().Body()
+}
+
+// responseSize is the length of the body of the response, -1 if streamed.
+func responseSize(c *fiber.Ctx) int {
+	if c.Response().IsBodyStream() {
+		return -1
+	}
+	return len(c.Response().Body())
+}
+
 func SetLoggerForRequest(c *fiber.Ctx, l *zap.Logger) {
 	c.Locals(loggerCtxKey, l)
 }
+
+// SetErrorForRequest keeps the error a response was rendered from, so the
+// request is logged with it rather than with the text sent to the client.
+func SetErrorForRequest(c *fiber.Ctx, err error) {
+	c.Locals(errorCtxKey, err)
+}
@@ -0,0 +1,233 @@
+// Package mvt encodes point layers as Mapbox Vector Tiles (version 2.1 of the
+// specification) on the Web Mercator tile grid of slippy maps. The protocol
+// buffer messages are written by hand, as only points and string attributes
+// are needed.
+package mvt
+
+import (
+	"math"
+)
+
+// MediaType is the registered media type of vector tiles.
+const MediaType = "application/vnd.mapbox-vector-tile"
+
+const (
+	// DefaultExtent is the number of units across a tile.
+	DefaultExtent = 4096
+	// MaxZoom is the deepest zoom level served.
+	MaxZoom = 22
+	// MaxLatitude is the latitude where Web Mercator ends, making the world square.
+	MaxLatitude = 85.05112877980659
+)
+
+// Tile is the tile at column X and row Y of zoom level Z, row 0 being the
+// northernmost.
+type Tile struct {
+	Z, X, Y int
+}
+
+// Valid reports whether the tile exists on the grid.
+func (t Tile) Valid() bool {
+	if t.Z < 0 || t.Z > MaxZoom {
+		return false
+	}
+	n := 1 << t.Z
+	return t.X >= 0 && t.X < n && t.Y >= 0 && t.Y < n
+}
+
+// Bounds returns the area covered by the tile, widened by buffer tile units on
+// each side so features just across the edge are drawn whole. The buffer of
+// the first and last columns wraps around the antimeridian, making minLon
+// greater than maxLon.
+func (t Tile) Bounds(extent, buffer int) (minLat, minLon, maxLat, maxLon float64) {
+	n := float64(int(1) << t.Z)
+	margin := float64(buffer) / float64(extent)
+
+	lon := func(x float64) float64 {
+		switch lon := x/n*360 - 180; {
+		case lon < -180:
+			return lon + 360
+		case lon > 180:
+			return lon - 360
+		default:
+			return lon
+		}
+	}
+	lat := func(y float64) float64 {
+		return math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+	}
+	if 1+2*margin >= n {
+		// the buffered tile goes all the way round
+		minLon, maxLon = -180, 180
+	} else {
+		minLon, maxLon = lon(float64(t.X)-margin), lon(float64(t.X+1)+margin)
+	}
+	minLat, maxLat = lat(math.Min(float64(t.Y+1)+margin, n)), lat(math.Max(float64(t.Y)-margin, 0))
+	return minLat, minLon, maxLat, maxLon
+}
+
+// Project returns the position of a point in tile units, from the top left
+// corner of the tile. Points outside the tile get coordinates below 0 or
+// beyond extent, on the side of the tile they are nearest to, across the
+// antimeridian if need be.
+func (t Tile) Project(latitude, longitude float64, extent int) (x, y int) {
+	n := float64(int(1) << t.Z)
+	latitude = math.Max(-MaxLatitude, math.Min(MaxLatitude, latitude))
+	phi := latitude * math.Pi / 180
+
+	wx := (longitude + 180) / 360 * n
+	switch dx := wx - float64(t.X) - 0.5; {
+	case dx > n/2:
+		wx -= n
+	case dx < -n/2:
+		wx += n
+	}
+	wy := (1 - math.Log(math.Tan(phi)+1/math.Cos(phi))/math.Pi) / 2 * n
+	return int(math.Round((wx - float64(t.X)) * float64(extent))),
+		int(math.Round((wy - float64(t.Y)) * float64(extent)))
+}
+
+// Attribute is a string property of a feature.
+type Attribute struct {
+	Key, Value string
+}
+
+// Layer is a named set of point features. Keys and values are stored once per
+// layer and referred to by index from the features.
+type Layer struct {
+	Name   string
+	Extent int
+
+	features [][]byte
+	keys     []string
+	keyIndex map[string]int
+	values   []string
+	valIndex map[string]int
+}
+
+// NewLayer returns an empty layer whose tiles are extent units across.
+func NewLayer(name string, extent int) *Layer {
+	return &Layer{
+		Name:     name,
+		Extent:   extent,
+		keyIndex: make(map[string]int),
+		valIndex: make(map[string]int),
+	}
+}
+
+// Len returns the number of features of the layer.
+func (l *Layer) Len() int {
+	return len(l.features)
+}
+
+// AddPoint adds a point feature at x, y in tile units.
+func (l *Layer) AddPoint(x, y int, attrs ...Attribute) {
+	tags := make([]uint64, 0, 2*len(attrs))
+	for _, a := range attrs {
+		tags = append(tags, uint64(intern(a.Key, &l.keys, l.keyIndex)), uint64(intern(a.Value, &l.values, l.valIndex)))
+	}
+	// a single MoveTo command, then the zigzag encoded coordinates
+	geometry := []uint64{commandMoveTo | 1<<3, zigzag(x), zigzag(y)}
+
+	var f buffer
+	f.packed(featureTags, tags)
+	f.uint(featureType, geomTypePoint)
+	f.packed(featureGeometry, geometry)
+	l.features = append(l.features, f)
+}
+
+// intern returns the index of s in list, appending it first if needed.
+func intern(s string, list *[]string, index map[string]int) int {
+	i, ok := index[s]
+	if !ok {
+		i = len(*list)
+		*list = append(*list, s)
+		index[s] = i
+	}
+	return i
+}
+
+// Encode writes a tile holding layers.
+func Encode(layers ...*Layer) []byte {
+	var tile buffer
+	for _, l := range layers {
+		var b buffer
+		b.uint(layerVersion, 2)
+		b.bytes(layerName, []byte(l.Name))
+		for _, f := range l.features {
+			b.bytes(layerFeatures, f)
+		}
+		for _, k := range l.keys {
+			b.bytes(layerKeys, []byte(k))
+		}
+		for _, v := range l.values {
+			var value buffer
+			value.bytes(valueString, []byte(v))
+			b.bytes(layerValues, value)
+		}
+		b.uint(layerExtent, uint64(l.Extent))
+		tile.bytes(tileLayers, b)
+	}
+	return tile
+}
+
+// Field numbers and enums of vector_tile.proto.
+const (
+	tileLayers = 3
+
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+	layerVersion  = 15
+
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	valueString = 1
+
+	geomTypePoint = 1
+	commandMoveTo = 1
+)
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// buffer is a protocol buffer message being written.
+type buffer []byte
+
+func (b *buffer) varint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+func (b *buffer) uint(field int, v uint64) {
+	b.varint(uint64(field)<<3 | wireVarint)
+	b.varint(v)
+}
+
+func (b *buffer) bytes(field int, data []byte) {
+	b.varint(uint64(field)<<3 | wireBytes)
+	b.varint(uint64(len(data)))
+	*b = append(*b, data...)
+}
+
+func (b *buffer) packed(field int, vs []uint64) {
+	var p buffer
+	for _, v := range vs {
+		p.varint(v)
+	}
+	b.bytes(field, p)
+}
+
+func zigzag(v int) uint64 {
+	n := int64(v)
+	return uint64((n << 1) ^ (n >> 63))
+}
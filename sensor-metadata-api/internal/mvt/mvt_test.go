@@ -0,0 +1,128 @@
+package mvt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTile(t *testing.T) {
+	assert.True(t, Tile{Z: 0}.Valid())
+	assert.True(t, Tile{Z: 3, X: 7, Y: 7}.Valid())
+	assert.False(t, Tile{Z: 3, X: 8, Y: 0}.Valid())
+	assert.False(t, Tile{Z: 23}.Valid())
+	assert.False(t, Tile{Z: 1, X: -1}.Valid())
+
+	minLat, minLon, maxLat, maxLon := Tile{Z: 0}.Bounds(DefaultExtent, 0)
+	assert.InDelta(t, -MaxLatitude, minLat, 1e-9)
+	assert.InDelta(t, MaxLatitude, maxLat, 1e-9)
+	assert.Equal(t, -180.0, minLon)
+	assert.Equal(t, 180.0, maxLon)
+
+	// Philadelphia is in tile 10/298/387
+	tile := Tile{Z: 10, X: 298, Y: 387}
+	minLat, minLon, maxLat, maxLon = tile.Bounds(DefaultExtent, 0)
+	assert.True(t, minLat < 39.95 && 39.95 < maxLat && minLon < -75.17 && -75.17 < maxLon)
+	bMinLat, bMinLon, bMaxLat, bMaxLon := tile.Bounds(DefaultExtent, 64)
+	assert.True(t, bMinLat < minLat && bMinLon < minLon && bMaxLat > maxLat && bMaxLon > maxLon)
+
+	x, y := tile.Project(39.95, -75.17, DefaultExtent)
+	assert.True(t, x >= 0 && x < DefaultExtent && y >= 0 && y < DefaultExtent, "%d, %d", x, y)
+	x, y = tile.Project(maxLat, minLon, DefaultExtent)
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+	x, y = tile.Project(minLat, maxLon, DefaultExtent)
+	assert.Equal(t, DefaultExtent, x)
+	assert.Equal(t, DefaultExtent, y)
+
+	// the buffer of the first and last columns wraps around the antimeridian
+	first, last := Tile{Z: 3, X: 0, Y: 3}, Tile{Z: 3, X: 7, Y: 3}
+	_, minLon, _, maxLon = first.Bounds(DefaultExtent, 64)
+	assert.InDelta(t, 180-45.0/64, minLon, 1e-9)
+	assert.InDelta(t, -135+45.0/64, maxLon, 1e-9)
+	_, minLon, _, maxLon = last.Bounds(DefaultExtent, 64)
+	assert.InDelta(t, 135-45.0/64, minLon, 1e-9)
+	assert.InDelta(t, -180+45.0/64, maxLon, 1e-9)
+	_, minLon, _, maxLon = Tile{Z: 0}.Bounds(DefaultExtent, 64)
+	assert.Equal(t, -180.0, minLon)
+	assert.Equal(t, 180.0, maxLon)
+
+	// and the points across it are drawn next to the edge
+	x, _ = first.Project(1, 179.9, DefaultExtent)
+	assert.True(t, x < 0 && x > -64, "%d", x)
+	x, _ = last.Project(1, -179.9, DefaultExtent)
+	assert.True(t, x > DefaultExtent && x < DefaultExtent+64, "%d", x)
+}
+
+func TestEncode(t *testing.T) {
+	layer := NewLayer("sensors", DefaultExtent)
+	layer.AddPoint(25, 17, Attribute{"name", "roof-1"}, Attribute{"tags", "a,b"})
+	layer.AddPoint(-3, 4100, Attribute{"name", "roof-2"}, Attribute{"tags", "a,b"})
+	assert.Equal(t, 2, layer.Len())
+
+	tile := decode(t, Encode(layer))
+	require.Len(t, tile[tileLayers], 1)
+	l := decode(t, tile[tileLayers][0].([]byte))
+	assert.Equal(t, []interface{}{uint64(2)}, l[layerVersion])
+	assert.Equal(t, "sensors", string(l[layerName][0].([]byte)))
+	assert.Equal(t, []interface{}{uint64(DefaultExtent)}, l[layerExtent])
+	require.Len(t, l[layerKeys], 2)
+	assert.Equal(t, "tags", string(l[layerKeys][1].([]byte)))
+	require.Len(t, l[layerValues], 3)
+	assert.Equal(t, "a,b", string(decode(t, l[layerValues][1].([]byte))[valueString][0].([]byte)))
+
+	require.Len(t, l[layerFeatures], 2)
+	f := decode(t, l[layerFeatures][1].([]byte))
+	assert.Equal(t, []uint64{0, 2, 1, 1}, varints(t, f[featureTags][0].([]byte)))
+	assert.Equal(t, []interface{}{uint64(geomTypePoint)}, f[featureType])
+	// MoveTo(1), zigzag(-3) = 5, zigzag(4100) = 8200
+	assert.Equal(t, []uint64{9, 5, 8200}, varints(t, f[featureGeometry][0].([]byte)))
+}
+
+// decode reads the fields of a protocol buffer message: varints as uint64 and
+// length delimited fields as []byte.
+func decode(t *testing.T, data []byte) map[int][]interface{} {
+	t.Helper()
+	fields := make(map[int][]interface{})
+	for len(data) > 0 {
+		key, n := uvarint(t, data)
+		data = data[n:]
+		switch key & 7 {
+		case wireVarint:
+			v, n := uvarint(t, data)
+			data = data[n:]
+			fields[int(key>>3)] = append(fields[int(key>>3)], v)
+		case wireBytes:
+			l, n := uvarint(t, data)
+			data = data[n:]
+			require.LessOrEqual(t, int(l), len(data))
+			fields[int(key>>3)] = append(fields[int(key>>3)], data[:l])
+			data = data[l:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
+
+func varints(t *testing.T, data []byte) []uint64 {
+	var vs []uint64
+	for len(data) > 0 {
+		v, n := uvarint(t, data)
+		vs = append(vs, v)
+		data = data[n:]
+	}
+	return vs
+}
+
+func uvarint(t *testing.T, data []byte) (uint64, int) {
+	var v uint64
+	for i, b := range data {
+		v |= uint64(b&0x7f) << (7 * i)
+		if b < 0x80 {
+			return v, i + 1
+		}
+	}
+	t.Fatal("truncated varint")
+	return 0, 0
+}
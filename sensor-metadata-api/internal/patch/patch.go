@@ -0,0 +1,321 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902)
+// documents to JSON values. Either the whole patch applies or an error is
+// returned and the input is left as it was.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types of the two patch formats.
+const (
+	MergePatchMediaType = "application/merge-patch+json"
+	JSONPatchMediaType  = "application/json-patch+json"
+)
+
+var (
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrTestFailed is returned when a "test" operation does not match, so the
+	// document is not in the state the patch was written for.
+	ErrTestFailed = errors.New("patch test failed")
+)
+
+// Operation is one operation of a JSON Patch. A nil Value is a missing member,
+// unlike the JSON null.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Merge applies the merge patch to doc: members of the patch replace those of
+// doc, objects are merged recursively and null members are removed.
+func Merge(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: body is not JSON", ErrInvalidPatch)
+	}
+	return json.Marshal(mergeValue(target, p))
+}
+
+func mergeValue(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergeValue(t[k], v)
+	}
+	return t
+}
+
+// Apply applies the operations of a JSON Patch to doc, in order.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var ops []Operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: body must be a JSON array of operations", ErrInvalidPatch)
+	}
+
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		var err error
+		if target, err = apply(target, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func apply(doc interface{}, op Operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: value is required", ErrInvalidPatch)
+		}
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: value is not JSON", ErrInvalidPatch)
+		}
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			if len(path) == 0 {
+				return value, nil
+			}
+			if doc, _, err = remove(doc, path); err != nil {
+				return nil, err
+			}
+			return add(doc, path, value)
+		}
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if op.Op == "move" {
+			if isPrefix(from, path) && len(from) < len(path) {
+				return nil, fmt.Errorf("%w: cannot move a value into itself", ErrInvalidPatch)
+			}
+			doc, value, err = remove(doc, from)
+		} else {
+			value, err = get(doc, from)
+			value = deepCopy(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	}
+	return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses an array index token. The "-" token, past the last
+// element, is only accepted when end is set.
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: %q is not an array index", ErrInvalidPatch, token)
+	}
+	if i > length || (i == length && !end) {
+		return 0, fmt.Errorf("%w: index %d is out of range", ErrInvalidPatch, i)
+	}
+	return i, nil
+}
+
+func missing(token string) error {
+	return fmt.Errorf("%w: %q does not exist", ErrInvalidPatch, token)
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch c := doc.(type) {
+		case map[string]interface{}:
+			v, ok := c[token]
+			if !ok {
+				return nil, missing(token)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = c[i]
+		default:
+			return nil, missing(token)
+		}
+	}
+	return doc, nil
+}
+
+// add sets the value at path and returns the updated document, which is a new
+// value when the root or an array is replaced.
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, last := path[0], len(path) == 1
+
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		if last {
+			c[token] = value
+			return c, nil
+		}
+		child, ok := c[token]
+		if !ok {
+			return nil, missing(token)
+		}
+		updated, err := add(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		c[token] = updated
+		return c, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(c), last)
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		}
+		if c[i], err = add(c[i], path[1:], value); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	return nil, missing(token)
+}
+
+// remove deletes the value at path and returns the updated document along with
+// the removed value.
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+	}
+	token, last := path[0], len(path) == 1
+
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		child, ok := c[token]
+		if !ok {
+			return nil, nil, missing(token)
+		}
+		if last {
+			delete(c, token)
+			return c, child, nil
+		}
+		updated, removed, err := remove(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		c[token] = updated
+		return c, removed, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(c), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if last {
+			removed := c[i]
+			return append(c[:i], c[i+1:]...), removed, nil
+		}
+		updated, removed, err := remove(c[i], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		c[i] = updated
+		return c, removed, nil
+	}
+	return nil, nil, missing(token)
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(c))
+		for k, e := range c {
+			m[k] = deepCopy(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(c))
+		for i, e := range c {
+			s[i] = deepCopy(e)
+		}
+		return s
+	}
+	return v
+}
@@ -0,0 +1,91 @@
+package patch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	// the example of RFC 7396, section 3
+	doc := `{"title": "Goodbye!", "author": {"givenName": "John", "familyName": "Doe"}, "tags": ["example", "sample"], "content": "This will be unchanged"}`
+	patch := `{"title": "Hello!", "phoneNumber": "+01-123-456-7890", "author": {"familyName": null}, "tags": ["example"]}`
+
+	out, err := Merge([]byte(doc), []byte(patch))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"title": "Hello!", "author": {"givenName": "John"}, "tags": ["example"], "content": "This will be unchanged", "phoneNumber": "+01-123-456-7890"}`, string(out))
+
+	_, err = Merge([]byte(doc), []byte(`{"title": `))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
+
+func TestApply(t *testing.T) {
+	doc := `{"name": "sensor-1", "location": {"latitude": 1, "longitude": 2}, "tags": ["a", "b"]}`
+
+	tests := map[string]struct {
+		patch string
+		want  string
+		err   error
+	}{
+		"Replace_And_Remove": {
+			patch: `[{"op": "replace", "path": "/location/latitude", "value": 0}, {"op": "remove", "path": "/tags/0"}]`,
+			want:  `{"name": "sensor-1", "location": {"latitude": 0, "longitude": 2}, "tags": ["b"]}`,
+		},
+		"Add_To_Array": {
+			patch: `[{"op": "add", "path": "/tags/-", "value": "c"}, {"op": "add", "path": "/tags/0", "value": "z"}]`,
+			want:  `{"name": "sensor-1", "location": {"latitude": 1, "longitude": 2}, "tags": ["z", "a", "b", "c"]}`,
+		},
+		"Test_Then_Replace": {
+			patch: `[{"op": "test", "path": "/tags", "value": ["a", "b"]}, {"op": "replace", "path": "/tags", "value": []}]`,
+			want:  `{"name": "sensor-1", "location": {"latitude": 1, "longitude": 2}, "tags": []}`,
+		},
+		"Move_And_Copy": {
+			patch: `[{"op": "copy", "from": "/tags/0", "path": "/tags/-"}, {"op": "move", "from": "/name", "path": "/description"}]`,
+			want:  `{"description": "sensor-1", "location": {"latitude": 1, "longitude": 2}, "tags": ["a", "b", "a"]}`,
+		},
+		"Escaped_Pointer": {
+			patch: `[{"op": "add", "path": "/a~1b~0c", "value": true}]`,
+			want:  `{"name": "sensor-1", "location": {"latitude": 1, "longitude": 2}, "tags": ["a", "b"], "a/b~c": true}`,
+		},
+		"Test_Fails": {
+			patch: `[{"op": "replace", "path": "/name", "value": "x"}, {"op": "test", "path": "/location/latitude", "value": 5}]`,
+			err:   ErrTestFailed,
+		},
+		"Missing_Path": {
+			patch: `[{"op": "remove", "path": "/tags/2"}]`,
+			err:   ErrInvalidPatch,
+		},
+		"Missing_Value": {
+			patch: `[{"op": "add", "path": "/tags/-"}]`,
+			err:   ErrInvalidPatch,
+		},
+		"Leading_Zero_Index": {
+			patch: `[{"op": "remove", "path": "/tags/01"}]`,
+			err:   ErrInvalidPatch,
+		},
+		"Move_Into_Child": {
+			patch: `[{"op": "move", "from": "/location", "path": "/location/inner"}]`,
+			err:   ErrInvalidPatch,
+		},
+		"Unknown_Operation": {
+			patch: `[{"op": "increment", "path": "/tags"}]`,
+			err:   ErrInvalidPatch,
+		},
+		"Not_An_Array": {
+			patch: `{"op": "remove", "path": "/tags"}`,
+			err:   ErrInvalidPatch,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			out, err := Apply([]byte(doc), []byte(tt.patch))
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(out))
+		})
+	}
+}
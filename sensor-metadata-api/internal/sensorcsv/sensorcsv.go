@@ -0,0 +1,255 @@
+// Package sensorcsv exports sensors as CSV, one row per sensor, and reads them
+// back from spreadsheets whose headers may be named differently.
+package sensorcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sensor-metadata-api/internal/db"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaType is the media type of CSV documents.
+const MediaType = "text/csv"
+
+// TagSeparator joins the tags of a sensor into a single cell.
+const TagSeparator = ";"
+
+// Fields that can be imported
+const (
+	FieldName        = "name"
+	FieldDescription = "description"
+	FieldLatitude    = "latitude"
+	FieldLongitude   = "longitude"
+	FieldTags        = "tags"
+)
+
+// Columns is the header of exported files. Import reads back the first five and
+// ignores the server managed ones.
+var Columns = []string{
+	FieldName, FieldDescription, FieldLatitude, FieldLongitude, FieldTags,
+	"created_at", "updated_at", "version",
+}
+
+// aliases are the headers recognised for each field without a mapping.
+var aliases = map[string][]string{
+	FieldName:        {"name"},
+	FieldDescription: {"description"},
+	FieldLatitude:    {"latitude", "lat"},
+	FieldLongitude:   {"longitude", "lon", "lng"},
+	FieldTags:        {"tags"},
+}
+
+var required = []string{FieldName, FieldLatitude, FieldLongitude}
+
+var ErrInvalidMapping = errors.New("mapping must be a comma separated list of field=header pairs")
+
+// Writer writes sensors as CSV rows.
+type Writer struct {
+	w *csv.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: csv.NewWriter(w)}
+}
+
+func (w *Writer) WriteHeader() error {
+	return w.w.Write(Columns)
+}
+
+// Write writes a sensor as a row. Text cells that a spreadsheet would read as a
+// formula are prefixed with a quote, which Read drops again.
+func (w *Writer) Write(sensor *db.SensorMetadata) error {
+	return w.w.Write([]string{
+		escapeFormula(sensor.Name),
+		escapeFormula(sensor.Description),
+		strconv.FormatFloat(sensor.Location.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(sensor.Location.Longitude, 'f', -1, 64),
+		escapeFormula(strings.Join(sensor.Tags, TagSeparator)),
+		sensor.CreatedAt.UTC().Format(time.RFC3339),
+		sensor.UpdatedAt.UTC().Format(time.RFC3339),
+		strconv.Itoa(sensor.Version),
+	})
+}
+
+// Flush writes any buffered rows and reports the first write error.
+func (w *Writer) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// formulaPrefixes are the characters spreadsheets start a formula with.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula keeps a spreadsheet from evaluating s as a formula.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeFormula undoes escapeFormula.
+func unescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// Mapping names the header holding each field, for files that do not use the
+// default column names.
+type Mapping map[string]string
+
+// ParseMapping reads a mapping written as "name=Sensor,latitude=Lat".
+func ParseMapping(s string) (Mapping, error) {
+	m := Mapping{}
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		field, header, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if _, known := aliases[field]; !ok || !known || strings.TrimSpace(header) == "" {
+			return nil, ErrInvalidMapping
+		}
+		m[field] = strings.TrimSpace(header)
+	}
+	return m, nil
+}
+
+// Row is one data row of an import, with the line it starts on. Err is set when
+// the row could not be read into a sensor. HasLocation tells a row at (0, 0)
+// from one whose coordinates are blank.
+type Row struct {
+	Line        int
+	Sensor      db.SensorMetadata
+	HasLocation bool
+	Err         error
+}
+
+// Read parses every data row of r. The returned error is only set when the file
+// itself is unusable, e.g. a required column is missing.
+func Read(r io.Reader, mapping Mapping, comma rune) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := resolveColumns(header, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		// a malformed row, e.g. an unterminated quote, cannot be skipped reliably
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		rows = append(rows, readRow(record, index, line))
+	}
+}
+
+// resolveColumns finds the position of every field in header.
+func resolveColumns(header []string, mapping Mapping) (map[string]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if _, dup := positions[h]; !dup {
+			positions[h] = i
+		}
+	}
+
+	index := make(map[string]int)
+	for field, names := range aliases {
+		if h, ok := mapping[field]; ok {
+			names = []string{strings.ToLower(h)}
+		}
+		for _, name := range names {
+			if i, ok := positions[name]; ok {
+				index[field] = i
+				break
+			}
+		}
+	}
+
+	var missing []string
+	for _, field := range required {
+		if _, ok := index[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing column for %s", strings.Join(missing, ", "))
+	}
+	return index, nil
+}
+
+func readRow(record []string, index map[string]int, line int) Row {
+	cell := func(field string) string {
+		i, ok := index[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := Row{Line: line}
+	row.Sensor.Name = unescapeFormula(cell(FieldName))
+	row.Sensor.Description = unescapeFormula(cell(FieldDescription))
+	for _, tag := range strings.Split(unescapeFormula(cell(FieldTags)), TagSeparator) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			row.Sensor.Tags = append(row.Sensor.Tags, tag)
+		}
+	}
+
+	var blank []string
+	for _, col := range []struct {
+		field string
+		dst   *float64
+	}{
+		{FieldLatitude, &row.Sensor.Location.Latitude},
+		{FieldLongitude, &row.Sensor.Location.Longitude},
+	} {
+		v := cell(col.field)
+		if v == "" {
+			blank = append(blank, col.field)
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			row.Err = fmt.Errorf("%s must be a number", col.field)
+			return row
+		}
+		*col.dst = f
+	}
+
+	// a half given location is an error, a blank one is left to validation
+	switch len(blank) {
+	case 0:
+		row.HasLocation = true
+	case 1:
+		row.Err = fmt.Errorf("%s is required", blank[0])
+	}
+	return row
+}
@@ -0,0 +1,103 @@
+package sensorcsv
+
+import (
+	"bytes"
+	"sensor-metadata-api/internal/db"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRead_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteHeader())
+	require.NoError(t, w.Write(&db.SensorMetadata{
+		Name:        "sensor-1",
+		Description: "roof, north side",
+		Location:    db.Location{Latitude: 40.4406, Longitude: -79.9959},
+		Tags:        []string{"temperature", "outdoor"},
+		CreatedAt:   time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:   time.Date(2023, 8, 2, 0, 0, 0, 0, time.UTC),
+		Version:     3,
+	}))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, "name,description,latitude,longitude,tags,created_at,updated_at,version\n"+
+		`sensor-1,"roof, north side",40.4406,-79.9959,temperature;outdoor,2023-08-01T00:00:00Z,2023-08-02T00:00:00Z,3`+"\n",
+		buf.String())
+
+	rows, err := Read(&buf, nil, ',')
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.NoError(t, rows[0].Err)
+	assert.Equal(t, 2, rows[0].Line)
+	assert.Equal(t, "roof, north side", rows[0].Sensor.Description)
+	assert.Equal(t, db.Location{Latitude: 40.4406, Longitude: -79.9959}, rows[0].Sensor.Location)
+	assert.Equal(t, []string{"temperature", "outdoor"}, []string(rows[0].Sensor.Tags))
+}
+
+func TestWriteRead_Formulas(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteHeader())
+	require.NoError(t, w.Write(&db.SensorMetadata{
+		Name:        "sensor-1",
+		Description: "=HYPERLINK(\"http://example.com\")",
+		Location:    db.Location{Latitude: -1.5, Longitude: 2},
+		Tags:        []string{"@admin", "+1"},
+	}))
+	require.NoError(t, w.Flush())
+
+	lines := strings.Split(buf.String(), "\n")
+	assert.True(t, strings.HasPrefix(lines[1], `sensor-1,"'=HYPERLINK(""http://example.com"")",-1.5,2,'@admin;+1,`), lines[1])
+
+	rows, err := Read(&buf, nil, ',')
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, `=HYPERLINK("http://example.com")`, rows[0].Sensor.Description)
+	assert.Equal(t, []string{"@admin", "+1"}, []string(rows[0].Sensor.Tags))
+}
+
+func TestRead_MappingAndLineNumbers(t *testing.T) {
+	mapping, err := ParseMapping("name=Sensor ID, latitude=Y, longitude=X")
+	require.NoError(t, err)
+
+	rows, err := Read(strings.NewReader(
+		"Sensor ID;Y;X;Notes\n"+
+			"a;1.5;2.5;\"multi\nline\"\n"+
+			"b;north;2\n"), mapping, ';')
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, db.Location{Latitude: 1.5, Longitude: 2.5}, rows[0].Sensor.Location)
+	assert.Equal(t, 4, rows[1].Line)
+	assert.EqualError(t, rows[1].Err, "latitude must be a number")
+}
+
+func TestRead_BlankLocation(t *testing.T) {
+	rows, err := Read(strings.NewReader("name,lat,lon\nnull-island,0,0\nnowhere,,\nhalf,1,\n"), nil, ',')
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	assert.True(t, rows[0].HasLocation)
+	assert.NoError(t, rows[0].Err)
+	assert.False(t, rows[1].HasLocation)
+	assert.NoError(t, rows[1].Err)
+	assert.EqualError(t, rows[2].Err, "longitude is required")
+}
+
+func TestRead_Invalid(t *testing.T) {
+	_, err := Read(strings.NewReader("name,lat\na,1\n"), nil, ',')
+	assert.EqualError(t, err, "missing column for longitude")
+
+	_, err = Read(strings.NewReader(""), nil, ',')
+	assert.Error(t, err)
+
+	for _, m := range []string{"name", "color=Colour", "name="} {
+		_, err = ParseMapping(m)
+		assert.ErrorIs(t, err, ErrInvalidMapping, m)
+	}
+}
@@ -1,17 +1,20 @@
 package server
 
 import (
+	"crypto/subtle"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/swagger"
 	"net/http"
+	"sensor-metadata-api/config"
 	"sensor-metadata-api/internal/db"
 	"sensor-metadata-api/internal/handlers"
 	"sensor-metadata-api/internal/logger"
 	"sensor-metadata-api/internal/version"
+	"time"
 )
 
-func (s *Server) SetupRoutes(database db.SensorMetadataDB) {
+func (s *Server) SetupRoutes(database db.SensorMetadataDB, cfg *config.ServerConfig) {
 
 	s.app.Use(cors.New())
 
@@ -24,6 +27,12 @@ func (s *Server) SetupRoutes(database db.SensorMetadataDB) {
 		logger.RequestId(),
 		logger.WrapLogger(),
 	)
+	// only POSTs carry an Idempotency-Key; errors are rendered behind it so
+	// that it keeps the responses of client errors
+	if store, ok := database.(db.IdempotencyStore); ok && cfg.IdempotencyWindowSec > 0 {
+		api.Use(handlers.Idempotency(store, time.Duration(cfg.IdempotencyWindowSec)*time.Second))
+	}
+	api.Use(handlers.RenderErrors())
 
 	// monitor endpoint - /api/v1/monitor
 	api.Get("/monitor", func(c *fiber.Ctx) error {
@@ -36,12 +45,94 @@ func (s *Server) SetupRoutes(database db.SensorMetadataDB) {
 		})
 	})
 
+	// GeoJSON and CSV renderings, also available through the Accept header
+	api.Get("/sensor-metadata.geojson", handlers.ListSensorMetadataHandler(database))
+	api.Get("/sensor-metadata.csv", handlers.ListSensorMetadataHandler(database))
+
 	// API V1 Group
 	v1 := api.Group(
 		"/sensor-metadata",
 	)
+	// guard sits in front of the routes that change a sensor; strict mode
+	// makes it reject requests without If-Match
+	guard := func(c *fiber.Ctx) error { return c.Next() }
+	if cfg.RequireIfMatch {
+		guard = handlers.RequireIfMatch()
+	}
 
+	v1.Get("", handlers.ListSensorMetadataHandler(database))
 	v1.Post("", handlers.CreateSensorMetadataHandler(database))
+	v1.Post("/bulk", handlers.BulkWriteSensorMetadataHandler(database))
+	v1.Post("/import", handlers.ImportSensorMetadataCSVHandler(database))
+	v1.Get("/search", handlers.SearchSensorMetadataHandler(database))
+	v1.Get("/suggest", handlers.SuggestSensorMetadataHandler(database))
+	v1.Get("/stats", handlers.GetSensorStatsHandler(database))
+	v1.Get("/events", handlers.SensorEventsHandler(database, handlers.EventStreamOptions{
+		Poll:         eventPollInterval,
+		Heartbeat:    eventHeartbeat,
+		Duration:     eventStreamDuration(cfg.EventStreamSec),
+		WriteTimeout: s.app.Config().WriteTimeout,
+	}))
+	v1.Get("/geo/bbox", handlers.FindSensorMetadataInBoxHandler(database))
+	v1.Get("/geo/clusters", handlers.FindSensorClustersHandler(database))
+	v1.Get("/geo/radius", handlers.FindSensorMetadataWithinRadiusHandler(database))
+	v1.Get("/geo/nearest", handlers.FindNearestSensorMetadataHandler(database))
+	v1.Get("/tiles/:z/:x/:y.mvt", handlers.SensorTileHandler(database))
+	v1.Get("/id/:uuid.geojson", handlers.GetSensorMetadataHandler(database))
+	v1.Get("/id/:uuid", handlers.GetSensorMetadataHandler(database))
+	v1.Put("/id/:uuid", guard, handlers.UpdateSensorMetadataHandler(database))
+	v1.Patch("/id/:uuid", guard, handlers.PatchSensorMetadataHandler(database))
+	v1.Delete("/id/:uuid", guard, handlers.DeleteSensorMetadataHandler(database))
+	v1.Get("/:name.geojson", handlers.GetSensorMetadataHandler(database))
 	v1.Get("/:name", handlers.GetSensorMetadataHandler(database))
-	v1.Put("/:name", handlers.UpdateSensorMetadataHandler(database))
+	v1.Put("/:name", guard, handlers.UpdateSensorMetadataHandler(database))
+	v1.Patch("/:name", guard, handlers.PatchSensorMetadataHandler(database))
+	v1.Delete("/:name", guard, handlers.DeleteSensorMetadataHandler(database))
+	v1.Post("/:name/restore", guard, handlers.RestoreSensorMetadataHandler(database))
+	v1.Post("/:name/rename", guard, handlers.RenameSensorMetadataHandler(database))
+	v1.Get("/:name/aliases", handlers.ListSensorAliasesHandler(database))
+	v1.Delete("/:name/aliases/:alias", handlers.ReleaseSensorAliasHandler(database))
+	v1.Get("/:name/revisions", handlers.ListSensorRevisionsHandler(database))
+	v1.Get("/:name/revisions/diff", handlers.DiffSensorRevisionsHandler(database))
+	v1.Get("/:name/revisions/:revision", handlers.GetSensorRevisionHandler(database))
+	v1.Post("/:name/revisions/:revision/rollback", guard, handlers.RollbackSensorMetadataHandler(database))
+
+	// admin routes are only served when an admin token is configured
+	if cfg.AdminToken != "" {
+		admin := api.Group(
+			"/admin",
+			adminAuth(cfg.AdminToken),
+		)
+		admin.Delete("/sensor-metadata/:name", handlers.PurgeSensorMetadataHandler(database))
+	}
+}
+
+const (
+	// eventPollInterval is how often event streams look for new revisions.
+	eventPollInterval = time.Second
+	// eventHeartbeat is how long a quiet event stream waits before sending a
+	// comment, so proxies do not close it as idle.
+	eventHeartbeat = 15 * time.Second
+	// defaultEventStream is how long event streams last when not configured.
+	defaultEventStream = 5 * time.Minute
+)
+
+// eventStreamDuration is the configured length of event streams. The server's
+// write timeout does not end them, as they extend it on every write.
+func eventStreamDuration(sec int) time.Duration {
+	if sec <= 0 {
+		return defaultEventStream
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// adminAuth rejects requests without the "Authorization: Bearer <token>" header.
+func adminAuth(token string) fiber.Handler {
+	expected := []byte("Bearer " + token)
+	return func(c *fiber.Ctx) error {
+		if subtle.ConstantTimeCompare([]byte(c.Get(fiber.HeaderAuthorization)), expected) != 1 {
+			return handlers.NewProblem(http.StatusUnauthorized, handlers.CodeUnauthorized, "admin token required")
+		}
+		return c.Next()
+	}
 }
@@ -0,0 +1,249 @@
+// Package suggest completes sensor names and tags from a prefix. Prefixes match
+// without regard to case, and a prefix with a typo still finds completions
+// whose start is within a small edit distance of it.
+package suggest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Completion is a name or tag starting with, or close to, the prefix asked for.
+type Completion struct {
+	Text string `json:"text"`
+	// Count is the number of sensors carrying a tag; it is 0 for names.
+	Count int `json:"count,omitempty"`
+	// Distance is the number of edits between the prefix and the start of
+	// Text, 0 for an exact prefix match.
+	Distance int `json:"distance"`
+}
+
+// entry is a completion with its lower-cased text, which prefixes are matched on.
+type entry struct {
+	key   string
+	text  string
+	count int
+}
+
+// Index holds the names and tags to complete, sorted by key. It is safe for
+// concurrent use, and follows the sensors through Add and Remove.
+type Index struct {
+	mu    sync.RWMutex
+	names []entry
+	tags  []entry
+}
+
+// Build indexes names and tags. tags holds every tag of every sensor, so a tag
+// carried by several sensors is listed as many times and counted.
+func Build(names []string, tags []string) *Index {
+	ix := &Index{names: make([]entry, 0, len(names))}
+	for _, name := range names {
+		ix.names = append(ix.names, entry{key: strings.ToLower(name), text: name})
+	}
+
+	counts := make(map[string]int)
+	for _, tag := range tags {
+		counts[tag]++
+	}
+	ix.tags = make([]entry, 0, len(counts))
+	for tag, n := range counts {
+		ix.tags = append(ix.tags, entry{key: strings.ToLower(tag), text: tag, count: n})
+	}
+
+	for _, entries := range [][]entry{ix.names, ix.tags} {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].key != entries[j].key {
+				return entries[i].key < entries[j].key
+			}
+			return entries[i].text < entries[j].text
+		})
+	}
+	return ix
+}
+
+// Add indexes the name and the tags of a sensor.
+func (ix *Index) Add(name string, tags []string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	i, _ := search(ix.names, name)
+	ix.names = insertAt(ix.names, i, entry{key: strings.ToLower(name), text: name})
+	for _, tag := range tags {
+		if i, ok := search(ix.tags, tag); ok {
+			ix.tags[i].count++
+		} else {
+			ix.tags = insertAt(ix.tags, i, entry{key: strings.ToLower(tag), text: tag, count: 1})
+		}
+	}
+}
+
+// Remove takes out the name and the tags of a sensor added before, dropping
+// the tags no other sensor carries.
+func (ix *Index) Remove(name string, tags []string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if i, ok := search(ix.names, name); ok {
+		ix.names = append(ix.names[:i], ix.names[i+1:]...)
+	}
+	for _, tag := range tags {
+		if i, ok := search(ix.tags, tag); ok {
+			if ix.tags[i].count--; ix.tags[i].count == 0 {
+				ix.tags = append(ix.tags[:i], ix.tags[i+1:]...)
+			}
+		}
+	}
+}
+
+// search finds where text is or belongs in entries, and whether it is there.
+func search(entries []entry, text string) (int, bool) {
+	key := strings.ToLower(text)
+	i := sort.Search(len(entries), func(i int) bool {
+		e := &entries[i]
+		return e.key > key || e.key == key && e.text >= text
+	})
+	return i, i < len(entries) && entries[i].text == text
+}
+
+func insertAt(entries []entry, i int, e entry) []entry {
+	entries = append(entries, entry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = e
+	return entries
+}
+
+// Names returns up to n names for prefix: the names starting with it, shortest
+// first, then names within the typo tolerance of it.
+func (ix *Index) Names(prefix string, n int) []Completion {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return complete(ix.names, prefix, n, func(a, b *entry) bool {
+		return len(a.key) < len(b.key)
+	})
+}
+
+// Tags returns up to n tags for prefix: the tags starting with it, most used
+// first, then tags within the typo tolerance of it.
+func (ix *Index) Tags(prefix string, n int) []Completion {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return complete(ix.tags, prefix, n, func(a, b *entry) bool {
+		return a.count > b.count
+	})
+}
+
+// MaxDistance is the number of typos tolerated in a prefix of the given length
+// in characters: none up to 2, one up to 5 and two beyond.
+func MaxDistance(length int) int {
+	switch {
+	case length <= 2:
+		return 0
+	case length <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// candidate is an entry matching a prefix, at some distance.
+type candidate struct {
+	*entry
+	distance int
+}
+
+// complete ranks the entries matching prefix by distance, then by better, then
+// by text.
+func complete(entries []entry, prefix string, n int, better func(a, b *entry) bool) []Completion {
+	prefix = strings.ToLower(prefix)
+	maxDistance := MaxDistance(utf8.RuneCountInString(prefix))
+
+	var candidates []candidate
+	if maxDistance == 0 {
+		// entries are sorted by key, so the exact matches are contiguous
+		i := sort.Search(len(entries), func(i int) bool { return entries[i].key >= prefix })
+		for ; i < len(entries) && strings.HasPrefix(entries[i].key, prefix); i++ {
+			candidates = append(candidates, candidate{entry: &entries[i]})
+		}
+	} else {
+		p := []rune(prefix)
+		for i := range entries {
+			if d := prefixDistance(p, []rune(entries[i].key), maxDistance); d <= maxDistance {
+				candidates = append(candidates, candidate{entry: &entries[i], distance: d})
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		switch {
+		case a.distance != b.distance:
+			return a.distance < b.distance
+		case better(a.entry, b.entry):
+			return true
+		case better(b.entry, a.entry):
+			return false
+		default:
+			return a.key < b.key || a.key == b.key && a.text < b.text
+		}
+	})
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	completions := make([]Completion, 0, len(candidates))
+	for _, c := range candidates {
+		completions = append(completions, Completion{Text: c.text, Count: c.count, Distance: c.distance})
+	}
+	return completions
+}
+
+// prefixDistance is the smallest edit distance between p and a prefix of s,
+// counting insertions, deletions, substitutions and transpositions of adjacent
+// characters. It gives up with max+1 once every prefix is further than max.
+func prefixDistance(p, s []rune, max int) int {
+	// rows of the optimal string alignment matrix, for p[:i-2], p[:i-1] and p[:i]
+	prev2 := make([]int, len(s)+1)
+	prev := make([]int, len(s)+1)
+	cur := make([]int, len(s)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(p); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(s); j++ {
+			cost := 1
+			if p[i-1] == s[j-1] {
+				cost = 0
+			}
+			d := smallest(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && p[i-1] == s[j-2] && p[i-2] == s[j-1] {
+				d = smallest(d, prev2[j-2]+1)
+			}
+			cur[j] = d
+			rowMin = smallest(rowMin, d)
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	best := prev[0]
+	for _, d := range prev {
+		best = smallest(best, d)
+	}
+	return best
+}
+
+func smallest(first int, rest ...int) int {
+	for _, v := range rest {
+		if v < first {
+			first = v
+		}
+	}
+	return first
+}
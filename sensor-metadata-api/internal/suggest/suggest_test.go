@@ -0,0 +1,81 @@
+package suggest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	ix := Build(
+		[]string{"Roof-Temperature", "roof-1", "cellar-1", "rooftop", "garden"},
+		[]string{"outdoor", "env=prod", "outdoor", "Outdoor", "env=staging", "outdoor", "env=prod", "indoor"},
+	)
+
+	assert.Equal(t, []Completion{{Text: "roof-1"}, {Text: "rooftop"}, {Text: "Roof-Temperature"}}, ix.Names("ro", 10))
+	assert.Equal(t, []Completion{{Text: "roof-1"}}, ix.Names("RO", 1))
+
+	// typos
+	assert.Equal(t, []Completion{
+		{Text: "roof-1"}, {Text: "rooftop"}, {Text: "Roof-Temperature"},
+	}, ix.Names("roof", 10))
+	assert.Equal(t, []Completion{
+		{Text: "roof-1", Distance: 1}, {Text: "rooftop", Distance: 1}, {Text: "Roof-Temperature", Distance: 1},
+	}, ix.Names("rofo", 10), "a transposition is one edit")
+	assert.Equal(t, []Completion{{Text: "cellar-1", Distance: 1}}, ix.Names("celalr-", 10))
+	assert.Equal(t, []Completion{{Text: "cellar-1", Distance: 2}}, ix.Names("cealr-1", 10))
+	assert.Empty(t, ix.Names("zz", 10))
+
+	assert.Equal(t, []Completion{
+		{Text: "outdoor", Count: 3}, {Text: "Outdoor", Count: 1},
+	}, ix.Tags("out", 10))
+	assert.Equal(t, []Completion{
+		{Text: "env=prod", Count: 2}, {Text: "env=staging", Count: 1},
+	}, ix.Tags("env=", 10))
+	assert.Equal(t, []Completion{
+		{Text: "outdoor", Count: 3}, {Text: "env=prod", Count: 2},
+	}, ix.Tags("", 2), "without a prefix the most used tags come first")
+	assert.Equal(t, []Completion{{Text: "indoor", Count: 1, Distance: 1}}, ix.Tags("indor", 10))
+}
+
+func TestIndex_AddRemove(t *testing.T) {
+	ix := Build([]string{"roof-1"}, []string{"outdoor", "env=prod"})
+
+	ix.Add("Roof-2", []string{"outdoor", "Outdoor"})
+	ix.Add("cellar-1", []string{"indoor"})
+	assert.Equal(t, []Completion{{Text: "roof-1"}, {Text: "Roof-2"}}, ix.Names("roof", 10))
+	assert.Equal(t, []Completion{{Text: "outdoor", Count: 2}, {Text: "Outdoor", Count: 1}}, ix.Tags("out", 10))
+
+	// a rename is the old entry taken out and the new one added
+	ix.Remove("roof-1", []string{"outdoor", "env=prod"})
+	ix.Add("attic-1", []string{"env=prod"})
+	assert.Equal(t, []Completion{{Text: "Roof-2"}}, ix.Names("roof", 10))
+	assert.Equal(t, []Completion{{Text: "attic-1"}}, ix.Names("att", 10))
+	assert.Equal(t, []Completion{{Text: "env=prod", Count: 1}}, ix.Tags("env", 10))
+
+	// tags no sensor carries any more are gone
+	ix.Remove("Roof-2", []string{"outdoor", "Outdoor"})
+	assert.Empty(t, ix.Tags("out", 10))
+	assert.Equal(t, []Completion{{Text: "env=prod", Count: 1}, {Text: "indoor", Count: 1}}, ix.Tags("", 10))
+
+	// taking out what is not there changes nothing
+	ix.Remove("garden", []string{"garden"})
+	assert.Equal(t, []Completion{{Text: "attic-1"}, {Text: "cellar-1"}}, ix.Names("", 10))
+}
+
+func TestPrefixDistance(t *testing.T) {
+	tests := []struct {
+		p, s string
+		want int
+	}{
+		{"roof", "roof-1", 0},
+		{"rof", "roof-1", 1},
+		{"rofo", "roof-1", 1},
+		{"ruuf", "roof-1", 2},
+		{"xyz", "roof-1", 3},
+		{"", "roof-1", 0},
+		{"roof-12", "roof-1", 1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, prefixDistance([]rune(tt.p), []rune(tt.s), 3), tt.p)
+	}
+}
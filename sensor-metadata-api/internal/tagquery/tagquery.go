@@ -0,0 +1,228 @@
+// Package tagquery parses label selectors over sensor tags. A tag of the form
+// key=value is a label; a tag without '=' is a label with no value. Selectors
+// follow the Kubernetes syntax, e.g. "env in (prod,staging), !deprecated, floor=3".
+package tagquery
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidSelector = errors.New("invalid selector")
+
+// Operator is how a requirement compares a label.
+type Operator string
+
+const (
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+)
+
+// Requirement is one comma separated term of a selector. Values holds one value
+// for Equals and NotEquals, at least one for In and NotIn, and none otherwise.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Selector matches the sensors meeting all of its requirements. The empty
+// selector matches every sensor.
+type Selector []Requirement
+
+// Tags returns the tags a requirement looks for: key=value for each of its values.
+func (r Requirement) Tags() []string {
+	tags := make([]string, len(r.Values))
+	for i, v := range r.Values {
+		tags[i] = r.Key + "=" + v
+	}
+	return tags
+}
+
+// Matches reports whether tags meet every requirement. As in Kubernetes, != and
+// notin also match when the label is missing.
+func (s Selector) Matches(tags []string) bool {
+	for _, r := range s {
+		var ok bool
+		switch r.Operator {
+		case Exists:
+			ok = hasKey(tags, r.Key)
+		case DoesNotExist:
+			ok = !hasKey(tags, r.Key)
+		case Equals, In:
+			ok = hasAny(tags, r.Tags())
+		case NotEquals, NotIn:
+			ok = !hasAny(tags, r.Tags())
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// hasKey reports whether a tag carries the label key, with or without a value.
+func hasKey(tags []string, key string) bool {
+	for _, tag := range tags {
+		if tag == key || strings.HasPrefix(tag, key+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAny(tags, want []string) bool {
+	for _, tag := range tags {
+		for _, w := range want {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Parse reads a selector. Keys and values are made of letters, digits and
+// ". _ : / -"; whitespace between tokens is ignored.
+func Parse(s string) (Selector, error) {
+	p := &parser{s: s}
+	var sel Selector
+	for {
+		r, err := p.requirement()
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, r)
+
+		p.skipSpace()
+		if p.done() {
+			return sel, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected ',' after %q", r.Key)
+		}
+	}
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) requirement() (Requirement, error) {
+	p.skipSpace()
+	if p.consume("!") {
+		key, err := p.word("key")
+		return Requirement{Key: key, Operator: DoesNotExist}, err
+	}
+
+	key, err := p.word("key")
+	if err != nil {
+		return Requirement{}, err
+	}
+
+	p.skipSpace()
+	switch {
+	case p.consume("=="), p.consume("="):
+		return p.value(key, Equals)
+	case p.consume("!="):
+		return p.value(key, NotEquals)
+	case p.done(), p.peek(","):
+		return Requirement{Key: key, Operator: Exists}, nil
+	}
+
+	op, err := p.word("operator")
+	if err != nil {
+		return Requirement{}, err
+	}
+	switch Operator(op) {
+	case In, NotIn:
+		return p.set(key, Operator(op))
+	default:
+		return Requirement{}, p.errorf("unknown operator %q", op)
+	}
+}
+
+func (p *parser) value(key string, op Operator) (Requirement, error) {
+	p.skipSpace()
+	v, err := p.word("value")
+	return Requirement{Key: key, Operator: op, Values: []string{v}}, err
+}
+
+func (p *parser) set(key string, op Operator) (Requirement, error) {
+	p.skipSpace()
+	if !p.consume("(") {
+		return Requirement{}, p.errorf("expected '(' after %s", op)
+	}
+
+	r := Requirement{Key: key, Operator: op}
+	for {
+		p.skipSpace()
+		v, err := p.word("value")
+		if err != nil {
+			return Requirement{}, err
+		}
+		r.Values = append(r.Values, v)
+
+		p.skipSpace()
+		if p.consume(")") {
+			return r, nil
+		}
+		if !p.consume(",") {
+			return Requirement{}, p.errorf("expected ',' or ')' in the values of %q", key)
+		}
+	}
+}
+
+// word reads a key, value or operator; what names it for the error message.
+func (p *parser) word(what string) (string, error) {
+	start := p.pos
+	for !p.done() {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		if !isWordRune(r) {
+			break
+		}
+		p.pos += size
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a %s", what)
+	}
+	return p.s[start:p.pos], nil
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("._:/-", r)
+}
+
+func (p *parser) skipSpace() {
+	for !p.done() && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *parser) consume(tok string) bool {
+	if p.peek(tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *parser) peek(tok string) bool {
+	return strings.HasPrefix(p.s[p.pos:], tok)
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s at offset %d", ErrInvalidSelector, fmt.Sprintf(format, args...), p.pos)
+}
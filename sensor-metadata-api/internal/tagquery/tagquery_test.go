@@ -0,0 +1,55 @@
+package tagquery
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	sel, err := Parse(" env in (prod, staging),!deprecated, floor==3,zone!=b,team notin(a),ha ")
+	require.NoError(t, err)
+	assert.Equal(t, Selector{
+		{Key: "env", Operator: In, Values: []string{"prod", "staging"}},
+		{Key: "deprecated", Operator: DoesNotExist},
+		{Key: "floor", Operator: Equals, Values: []string{"3"}},
+		{Key: "zone", Operator: NotEquals, Values: []string{"b"}},
+		{Key: "team", Operator: NotIn, Values: []string{"a"}},
+		{Key: "ha", Operator: Exists},
+	}, sel)
+
+	for _, s := range []string{"", "env=", "env in prod", "env in (a,", "env in ()", "env like x", "a b", "!", "a=b=c", "a,,b"} {
+		_, err := Parse(s)
+		assert.ErrorIs(t, err, ErrInvalidSelector, s)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tags := []string{"env=prod", "floor=3", "outdoor"}
+
+	tests := map[string]bool{
+		"env=prod":                 true,
+		"env=staging":              false,
+		"env in (staging,prod)":    true,
+		"env notin (staging,prod)": false,
+		"env!=staging":             true,
+		"team!=a":                  true,
+		"team notin (a)":           true,
+		"env":                      true,
+		"outdoor":                  true,
+		"!deprecated":              true,
+		"!outdoor":                 false,
+		"!env":                     false,
+		"en":                       false,
+		"env in (prod), floor=3":   true,
+		"env in (prod), floor=4":   false,
+		"env=prod, !outdoor":       false,
+	}
+	for s, want := range tests {
+		sel, err := Parse(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, sel.Matches(tags), s)
+	}
+
+	assert.True(t, Selector(nil).Matches(nil))
+}
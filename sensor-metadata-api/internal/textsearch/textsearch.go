@@ -0,0 +1,227 @@
+// Package textsearch is a small in-process full-text index. Text is split into
+// lower-cased words of letters and digits, like the "simple" configuration of
+// Postgres, and every word of a query matches as a prefix.
+package textsearch
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Weights of the fields of a document, the default weights of the Postgres
+// ts_rank function for the A, B and C labels.
+const (
+	WeightA = 1.0
+	WeightB = 0.4
+	WeightC = 0.2
+)
+
+// Highlight markers around the matched words of a snippet.
+const (
+	MarkStart = "<mark>"
+	MarkEnd   = "</mark>"
+)
+
+// Query is the distinct words of a search, all of which a document must hold.
+type Query []string
+
+// ParseQuery splits a search into words. The query is empty when q holds no
+// letters or digits.
+func ParseQuery(q string) Query {
+	var query Query
+	seen := make(map[string]bool)
+	for _, w := range words(q) {
+		if !seen[w.text] {
+			seen[w.text] = true
+			query = append(query, w.text)
+		}
+	}
+	return query
+}
+
+// TSQuery renders the query for the Postgres to_tsquery function, every word
+// matching as a prefix. Words only hold letters and digits, so need no quoting.
+func (q Query) TSQuery() string {
+	terms := make([]string, len(q))
+	for i, w := range q {
+		terms[i] = w + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// matches reports whether word starts with one of the query words.
+func (q Query) matches(word string) bool {
+	for _, w := range q {
+		if strings.HasPrefix(word, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hit is a document matching a query, with its score.
+type Hit struct {
+	Doc   int
+	Score float64
+}
+
+// Index maps words to the documents holding them. Documents are numbered by
+// the caller. An Index is not safe for concurrent use.
+type Index struct {
+	postings map[string]map[int]float64
+	// words is the sorted keys of postings, for prefix lookups
+	words []string
+}
+
+func NewIndex() *Index {
+	return &Index{postings: make(map[string]map[int]float64)}
+}
+
+// Add indexes the text of a field of doc. Each occurrence of a word adds weight
+// to the score of doc for that word.
+func (ix *Index) Add(doc int, text string, weight float64) {
+	for _, w := range words(text) {
+		docs, ok := ix.postings[w.text]
+		if !ok {
+			docs = make(map[int]float64)
+			ix.postings[w.text] = docs
+			ix.words = nil
+		}
+		docs[doc] += weight
+	}
+}
+
+// Search returns the documents holding every word of q, best scores first.
+// Documents of equal score are in no particular order.
+func (ix *Index) Search(q Query) []Hit {
+	if len(q) == 0 {
+		return nil
+	}
+	if ix.words == nil {
+		ix.words = make([]string, 0, len(ix.postings))
+		for w := range ix.postings {
+			ix.words = append(ix.words, w)
+		}
+		sort.Strings(ix.words)
+	}
+
+	var scores map[int]float64
+	for _, term := range q {
+		termScores := make(map[int]float64)
+		for i := sort.SearchStrings(ix.words, term); i < len(ix.words) && strings.HasPrefix(ix.words[i], term); i++ {
+			for doc, score := range ix.postings[ix.words[i]] {
+				termScores[doc] += score
+			}
+		}
+
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for doc := range scores {
+			if s, ok := termScores[doc]; ok {
+				scores[doc] += s
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for doc, score := range scores {
+		hits = append(hits, Hit{Doc: doc, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	return hits
+}
+
+// Highlight returns an HTML escaped excerpt of text of about width characters
+// around the first word matching q, with the matching words between MarkStart
+// and MarkEnd. It returns "" when no word of text matches.
+func Highlight(text string, q Query, width int) string {
+	ws := words(text)
+	first := -1
+	for i, w := range ws {
+		if q.matches(w.text) {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	// a long text starts a few words before the first match, and is cut at
+	// word boundaries
+	start, end := 0, len(text)
+	if i := first - 3; i > 0 && utf8.RuneCountInString(text) > width {
+		start = ws[i].start
+	}
+	if utf8.RuneCountInString(text[start:]) > width {
+		end = start
+		for _, w := range ws {
+			if w.start >= start && utf8.RuneCountInString(text[start:w.end]) > width {
+				break
+			}
+			if w.start >= start {
+				end = w.end
+			}
+		}
+		if end < ws[first].end {
+			end = ws[first].end
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, w := range ws {
+		if w.start < start || w.end > end || !q.matches(w.text) {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:w.start]))
+		b.WriteString(MarkStart)
+		b.WriteString(html.EscapeString(text[w.start:w.end]))
+		b.WriteString(MarkEnd)
+		pos = w.end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// word is a lower-cased word of a text, and its byte offsets in the text.
+type word struct {
+	text       string
+	start, end int
+}
+
+func words(text string) []word {
+	var ws []word
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			ws = append(ws, word{text: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		ws = append(ws, word{text: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return ws
+}
@@ -0,0 +1,40 @@
+package textsearch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	assert.Equal(t, Query{"roof", "sensor", "01"}, ParseQuery("Roof  sensor-01, roof!"))
+	assert.Empty(t, ParseQuery(" -- ! "))
+	assert.Equal(t, "roof:* & sensor:* & 01:*", ParseQuery("roof sensor-01").TSQuery())
+}
+
+func TestSearch(t *testing.T) {
+	ix := NewIndex()
+	ix.Add(0, "roof-1", WeightA)
+	ix.Add(0, "Temperature on the roof of the west building", WeightC)
+	ix.Add(1, "cellar-1", WeightA)
+	ix.Add(1, "Humidity in the cellar, below the roof terrace", WeightC)
+	ix.Add(2, "Ünterführung", WeightA)
+
+	hits := ix.Search(ParseQuery("roof"))
+	assert.Equal(t, []Hit{{Doc: 0, Score: WeightA + WeightC}, {Doc: 1, Score: WeightC}}, hits)
+
+	// every word must match, as a prefix
+	assert.Equal(t, []Hit{{Doc: 1, Score: 2 * WeightC}}, ix.Search(ParseQuery("ROOF hum")))
+	assert.Equal(t, []Hit{{Doc: 2, Score: WeightA}}, ix.Search(ParseQuery("ünter")))
+	assert.Empty(t, ix.Search(ParseQuery("roof basement")))
+	assert.Empty(t, ix.Search(nil))
+}
+
+func TestHighlight(t *testing.T) {
+	q := ParseQuery("roof")
+	assert.Equal(t, "Temperature on the <mark>roof</mark> &amp; <mark>Roofs</mark>", Highlight("Temperature on the roof & Roofs", q, 100))
+	assert.Equal(t, "", Highlight("Humidity in the cellar", q, 100))
+
+	text := "A long description of a sensor that sits in the basement until it was moved to the roof of the building last year"
+	assert.Equal(t, "…moved to the <mark>roof</mark> of the…", Highlight(text, q, 30))
+	assert.Equal(t, "…moved to the <mark>roof</mark>…", Highlight(text, q, 1))
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -9,6 +11,7 @@ import (
 	"sensor-metadata-api/config"
 	_ "sensor-metadata-api/docs"
 	db_config "sensor-metadata-api/internal/db"
+	"sensor-metadata-api/internal/handlers"
 	"sensor-metadata-api/internal/server"
 	"syscall"
 	"time"
@@ -27,10 +30,19 @@ import (
 // @license.url   http://www.apache.org/licenses/LICENSE-2.0.html
 // @BasePath       /api/v1/
 func main() {
+	schemaVersion := flag.Bool("schema-version", false, "print the applied schema migrations and exit")
+	rollback := flag.Int("rollback", 0, "roll back the given number of schema migrations and exit")
+	flag.Parse()
+
 	cfg := config.InitConfig(".")
 	logger := setupLogging()
 	defer logger.Sync()
 
+	if *schemaVersion || *rollback > 0 {
+		runMigrationCommand(cfg.DBConfig, *rollback, logger)
+		return
+	}
+
 	logger.Info("setting up db connection")
 	db, err := db_config.InitDb(cfg.DBConfig)
 	if err != nil {
@@ -43,9 +55,12 @@ func main() {
 		WriteTimeout:          10 * time.Second,
 		IdleTimeout:           10 * time.Second,
 		DisableStartupMessage: true,
+		ErrorHandler:          handlers.ErrorHandler,
+		// sensor names may hold spaces and non-ASCII letters, which arrive percent-encoded
+		UnescapePath: true,
 	})
 
-	s.SetupRoutes(db)
+	s.SetupRoutes(db, cfg.ServerConfig)
 
 	go func() {
 		logger.Info("server listener starting " + cfg.ServerConfig.Addr)
@@ -66,6 +81,39 @@ func main() {
 	}
 }
 
+// runMigrationCommand rolls back the requested number of migrations, if any,
+// then prints the schema version and migration status as JSON on stdout.
+func runMigrationCommand(cfg *config.DBConfig, rollback int, logger *zap.Logger) {
+	conn, err := db_config.Connect(cfg)
+	if err != nil {
+		logger.Fatal("error setting up db connection: " + err.Error())
+	}
+
+	m := db_config.NewMigrator(conn)
+	if rollback > 0 {
+		logger.Info("rolling back schema migrations", zap.Int("steps", rollback))
+		if err = m.Down(rollback); err != nil {
+			logger.Fatal("error rolling back schema migrations: " + err.Error())
+		}
+	}
+
+	version, err := m.Version()
+	if err != nil {
+		logger.Fatal("error reading schema version: " + err.Error())
+	}
+	status, err := m.Status()
+	if err != nil {
+		logger.Fatal("error reading schema migrations: " + err.Error())
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(map[string]any{
+		"version":    version,
+		"migrations": status,
+	})
+}
+
 func setupLogging() *zap.Logger {
 	encoderCfg := zap.NewProductionEncoderConfig()
 	encoderCfg.TimeKey = "timestamp"